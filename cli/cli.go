@@ -0,0 +1,229 @@
+// Package cli runs nectar without its screens, for shell scripts and cron
+// jobs. It works on the same saved connections and drivers the screens do;
+// results go to stdout and everything else to stderr.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"nectar/build"
+	"nectar/store"
+	"nectar/types"
+	"nectar/utils"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Exit codes, so scripts can tell what went wrong
+const (
+	ExitOK = 0
+	// ExitFailed means a statement or the command itself failed
+	ExitFailed = 1
+	// ExitUsage means the command line was wrong
+	ExitUsage = 2
+	// ExitConnect means the connection couldn't be found or opened
+	ExitConnect = 3
+	// ExitInterrupted means the run was stopped by a signal
+	ExitInterrupted = 130
+)
+
+// Environment variables that answer what the screens would prompt for
+const (
+	// EnvMasterPassword unlocks the credential vault
+	EnvMasterPassword = "NECTAR_MASTER_PASSWORD"
+	// EnvPassword is the password of a connection that asks for one on
+	// every connect
+	EnvPassword = "NECTAR_PASSWORD"
+)
+
+// command is a subcommand: what it's called, a line on what it does, and
+// what runs it with the arguments after its name
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, env *env, args []string) int
+}
+
+var commands = []command{
+	{name: "exec", summary: "run SQL against a connection and print the results", run: runExec},
+	{name: "conn", summary: "list, add, edit, test and share saved connections", run: runConn},
+}
+
+// env is what a command runs with
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// connections is opened on first use, so that commands which don't
+	// need it don't fail when the configuration directory can't be found
+	connections *store.Store
+	// queries is the query history, opened on first use likewise
+	queries *store.History
+}
+
+// Run runs the subcommand named by args[0] and returns the exit code
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		usage(stderr)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return ExitOK
+	case "version", "-version", "--version":
+		fmt.Fprintf(stdout, "nectar %s (%s)\n", build.Version, build.Date)
+		return ExitOK
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(ctx, e, args[1:])
+		}
+	}
+	fmt.Fprintf(stderr, "nectar: unknown command %q\n\n", args[0])
+	usage(stderr)
+	return ExitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: nectar [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Without a command nectar opens its screens. Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "  version  print the version")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run \"nectar <command> -h\" for a command's flags.")
+}
+
+// newFlags returns a flag set for a command that reports its errors to
+// stderr rather than exiting
+func newFlags(e *env, name, args string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: nectar %s %s\n\nFlags:\n", name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses args, returning the exit code to stop with when they
+// don't parse or help was asked for
+func parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	err := flags.Parse(args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return ExitOK, false
+	case err != nil:
+		return ExitUsage, false
+	}
+	return ExitOK, true
+}
+
+// store opens the saved connections
+func (e *env) store() *store.Store {
+	if e.connections == nil {
+		dir, err := utils.ConfigDir()
+		if err != nil {
+			dir = "."
+		}
+		e.connections = store.New(dir)
+	}
+	return e.connections
+}
+
+// history opens the query history the screens record to as well
+func (e *env) history() *store.History {
+	if e.queries == nil {
+		dir, err := utils.DataDir()
+		if err != nil {
+			if dir, err = utils.ConfigDir(); err != nil {
+				dir = "."
+			}
+		}
+		e.queries = store.NewHistory(dir)
+	}
+	return e.queries
+}
+
+// failf reports an error on stderr and returns code
+func (e *env) failf(code int, format string, args ...any) int {
+	fmt.Fprintf(e.stderr, "nectar: "+format+"\n", args...)
+	return code
+}
+
+// lookup finds the connection a --conn flag names: a saved connection, or a
+// connection URL such as postgres://user@host/db
+func (e *env) lookup(target string) (types.Connection, error) {
+	if strings.Contains(target, "://") {
+		conn, err := utils.ParseConnectionURL(target)
+		if err != nil {
+			return conn, err
+		}
+		if conn.Name == "" {
+			conn.Name = conn.Host
+			if conn.Type == types.SQLite {
+				conn.Name = conn.DatabaseFile
+			}
+		}
+		return conn, nil
+	}
+	return e.store().Get(target)
+}
+
+// resolve fills in conn's passwords the way opening a session does. What
+// the screens would prompt for is taken from the environment, or asked for
+// on the terminal when there is one.
+func (e *env) resolve(ctx context.Context, conn types.Connection) (types.Connection, error) {
+	connections := e.store()
+	for {
+		resolved, err := connections.Resolve(ctx, conn)
+		switch {
+		case errors.Is(err, store.ErrVaultLocked):
+			master, err := e.secret(EnvMasterPassword, "Master password: ")
+			if err != nil {
+				return conn, fmt.Errorf("the credential vault is locked: %w", err)
+			}
+			if err := connections.Unlock(master); err != nil {
+				return conn, err
+			}
+			continue
+		case errors.Is(err, store.ErrPasswordRequired):
+			password, err := e.secret(EnvPassword, "Password for "+conn.Name+": ")
+			if err != nil {
+				return conn, fmt.Errorf("%s asks for its password: %w", conn.Name, err)
+			}
+			if password == "" {
+				return conn, fmt.Errorf("%s asks for its password: %w", conn.Name, store.ErrPasswordRequired)
+			}
+			conn.Password = password
+			continue
+		}
+		return resolved, err
+	}
+}
+
+// secret reads a password from the environment variable, or else from the
+// terminal without echoing it
+func (e *env) secret(variable, prompt string) (string, error) {
+	if value, ok := os.LookupEnv(variable); ok {
+		return value, nil
+	}
+	in, ok := e.stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(in.Fd())) {
+		return "", fmt.Errorf("set %s or run from a terminal", variable)
+	}
+	fmt.Fprint(e.stderr, prompt)
+	secret, err := term.ReadPassword(int(in.Fd()))
+	fmt.Fprintln(e.stderr)
+	return string(secret), err
+}
@@ -0,0 +1,752 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"nectar/driver"
+	"nectar/store"
+	"nectar/types"
+	"nectar/utils"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var connCommands = []command{
+	{name: "list", summary: "list the saved connections", run: runConnList},
+	{name: "show", summary: "show a saved connection", run: runConnShow},
+	{name: "add", summary: "save a new connection", run: runConnAdd},
+	{name: "edit", summary: "change a saved connection", run: runConnEdit},
+	{name: "rm", summary: "delete saved connections", run: runConnRemove},
+	{name: "test", summary: "check a connection one layer at a time", run: runConnTest},
+	{name: "export", summary: "write saved connections as JSON, without passwords", run: runConnExport},
+	{name: "import", summary: "save the connections of an exported file", run: runConnImport},
+}
+
+// runConn manages the saved connections the screens list in their sidebar
+func runConn(ctx context.Context, e *env, args []string) int {
+	if len(args) == 0 {
+		connUsage(e.stderr)
+		return ExitUsage
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		connUsage(e.stdout)
+		return ExitOK
+	}
+	for _, c := range connCommands {
+		if c.name == args[0] {
+			return c.run(ctx, e, args[1:])
+		}
+	}
+	fmt.Fprintf(e.stderr, "nectar: unknown conn command %q\n\n", args[0])
+	connUsage(e.stderr)
+	return ExitUsage
+}
+
+func connUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: nectar conn <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range connCommands {
+		fmt.Fprintf(w, "  %-7s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run \"nectar conn <command> -h\" for a command's flags.")
+}
+
+// connField is a flag that sets one field of a connection
+type connField struct {
+	name    string
+	usage   string
+	boolean bool
+	set     func(conn *types.Connection, value string) error
+}
+
+var connFields = []connField{
+	{name: "name", usage: "connection name", set: func(c *types.Connection, v string) error {
+		c.Name = v
+		return nil
+	}},
+	{name: "type", usage: "postgresql, mysql or sqlite", set: func(c *types.Connection, v string) error {
+		t, err := types.ParseConnectionType(v)
+		c.Type = t
+		return err
+	}},
+	{name: "host", usage: "server host (default localhost)", set: func(c *types.Connection, v string) error {
+		c.Host = v
+		return nil
+	}},
+	{name: "port", usage: "server port (default the type's own)", set: func(c *types.Connection, v string) error {
+		c.Port = v
+		return nil
+	}},
+	{name: "user", usage: "user to log in as", set: func(c *types.Connection, v string) error {
+		c.User = v
+		return nil
+	}},
+	{name: "password", usage: "password to keep in the vault; prefer --password-stdin", set: func(c *types.Connection, v string) error {
+		c.Password, c.PasswordStorage = v, types.PasswordVault
+		return nil
+	}},
+	{name: "password-storage", usage: "where the password comes from: vault, prompt or command", set: func(c *types.Connection, v string) error {
+		s, err := types.ParsePasswordStorage(v)
+		c.PasswordStorage = s
+		return err
+	}},
+	{name: "password-command", usage: "command that prints the password, e.g. \"pass show db\"", set: func(c *types.Connection, v string) error {
+		c.PasswordCommand, c.PasswordStorage = v, types.PasswordCommand
+		return nil
+	}},
+	{name: "database", usage: "database to open", set: func(c *types.Connection, v string) error {
+		c.Database = v
+		return nil
+	}},
+	{name: "file", usage: "SQLite database file", set: func(c *types.Connection, v string) error {
+		c.DatabaseFile = v
+		return nil
+	}},
+	{name: "tls-mode", usage: "disable, prefer, require, verify-ca or verify-full", set: func(c *types.Connection, v string) error {
+		m, err := types.ParseTLSMode(v)
+		c.TLS.Mode = m
+		return err
+	}},
+	{name: "tls-ca", usage: "CA certificate file", set: func(c *types.Connection, v string) error {
+		c.TLS.CAFile = v
+		return nil
+	}},
+	{name: "tls-cert", usage: "client certificate file", set: func(c *types.Connection, v string) error {
+		c.TLS.CertFile = v
+		return nil
+	}},
+	{name: "tls-key", usage: "client key file", set: func(c *types.Connection, v string) error {
+		c.TLS.KeyFile = v
+		return nil
+	}},
+	{name: "tls-server-name", usage: "host name to verify the certificate against", set: func(c *types.Connection, v string) error {
+		c.TLS.ServerName = v
+		return nil
+	}},
+	{name: "ssh", usage: "reach the server through an SSH tunnel", boolean: true, set: func(c *types.Connection, v string) error {
+		on, err := strconv.ParseBool(v)
+		c.SSH.Enabled = on
+		return err
+	}},
+	{name: "ssh-host", usage: "SSH bastion host; implies --ssh", set: func(c *types.Connection, v string) error {
+		c.SSH.Host, c.SSH.Enabled = v, true
+		return nil
+	}},
+	{name: "ssh-port", usage: "SSH port (default " + utils.DefaultSSHPort + ")", set: func(c *types.Connection, v string) error {
+		c.SSH.Port = v
+		return nil
+	}},
+	{name: "ssh-user", usage: "SSH user", set: func(c *types.Connection, v string) error {
+		c.SSH.User = v
+		return nil
+	}},
+	{name: "ssh-key", usage: "SSH private key file", set: func(c *types.Connection, v string) error {
+		c.SSH.KeyFile = v
+		return nil
+	}},
+	{name: "ssh-agent", usage: "authenticate with the keys held by ssh-agent", boolean: true, set: func(c *types.Connection, v string) error {
+		on, err := strconv.ParseBool(v)
+		c.SSH.UseAgent = on
+		return err
+	}},
+	{name: "ssh-forward-agent", usage: "forward ssh-agent to the bastion; needs its host key checked", boolean: true, set: func(c *types.Connection, v string) error {
+		on, err := strconv.ParseBool(v)
+		c.SSH.ForwardAgent = on
+		return err
+	}},
+	{name: "ssh-known-hosts", usage: "known hosts file (default ~/.ssh/known_hosts)", set: func(c *types.Connection, v string) error {
+		c.SSH.KnownHostsFile = v
+		return nil
+	}},
+	{name: "ssh-skip-host-key-check", usage: "don't verify the bastion's host key", boolean: true, set: func(c *types.Connection, v string) error {
+		on, err := strconv.ParseBool(v)
+		c.SSH.SkipHostKeyCheck = on
+		return err
+	}},
+	{name: "color", usage: "color the sidebar shows the connection in", set: func(c *types.Connection, v string) error {
+		color, err := types.ParseColor(v)
+		c.Color = color
+		return err
+	}},
+	{name: "sensitive", usage: "keep the connection's statements out of the query history", boolean: true, set: func(c *types.Connection, v string) error {
+		on, err := strconv.ParseBool(v)
+		c.Sensitive = on
+		return err
+	}},
+}
+
+// connEdits collects the field flags given on the command line, to be
+// applied in the order they were given
+type connEdits struct {
+	url           string
+	passwordStdin bool
+	edits         []func(conn *types.Connection) error
+}
+
+func addConnFlags(flags *flag.FlagSet) *connEdits {
+	edits := &connEdits{}
+	flags.StringVar(&edits.url, "url", "", "fill the connection in from a URL, as the form's ^u does")
+	flags.BoolVar(&edits.passwordStdin, "password-stdin", false, "read the password to keep in the vault from stdin")
+	for _, field := range connFields {
+		set := func(value string) error {
+			edits.edits = append(edits.edits, func(conn *types.Connection) error {
+				return field.set(conn, value)
+			})
+			return nil
+		}
+		if field.boolean {
+			flags.BoolFunc(field.name, field.usage, set)
+		} else {
+			flags.Func(field.name, field.usage, set)
+		}
+	}
+	return edits
+}
+
+// apply makes the edits to conn. A URL replaces everything it describes
+// first, keeping the name, color and history setting; the fields then
+// apply on top of it.
+func (c *connEdits) apply(e *env, conn types.Connection) (types.Connection, error) {
+	if c.url != "" {
+		parsed, err := utils.ParseConnectionURL(c.url)
+		if err != nil {
+			return conn, err
+		}
+		parsed.Name, parsed.Color, parsed.Sensitive = conn.Name, conn.Color, conn.Sensitive
+		conn = parsed
+	}
+	for _, edit := range c.edits {
+		if err := edit(&conn); err != nil {
+			return conn, err
+		}
+	}
+	if c.passwordStdin {
+		password, err := io.ReadAll(e.stdin)
+		if err != nil {
+			return conn, err
+		}
+		conn.Password = strings.TrimRight(string(password), "\r\n")
+		conn.PasswordStorage = types.PasswordVault
+	}
+	return normalize(conn)
+}
+
+// normalize drops the fields that don't apply to conn's type and fills in
+// the defaults the connection form would, reporting what it can't save
+func normalize(conn types.Connection) (types.Connection, error) {
+	conn.Name = strings.TrimSpace(conn.Name)
+	if conn.Name == "" {
+		return conn, store.ErrEmptyName
+	}
+	if conn.Color == "" {
+		conn.Color = types.DefaultColor
+	}
+
+	if conn.Type == types.SQLite {
+		if conn.DatabaseFile == "" {
+			return conn, errors.New("an SQLite connection needs --file")
+		}
+		conn.Host, conn.Port, conn.User, conn.Password, conn.Database = "", "", "", "", ""
+		conn.PasswordStorage, conn.PasswordCommand = types.PasswordVault, ""
+		conn.TLS = types.TLSConfig{}
+		conn.SSH = types.SSHTunnel{}
+		return conn, nil
+	}
+
+	conn.DatabaseFile = ""
+	if conn.Host == "" {
+		conn.Host = "localhost"
+	}
+	if conn.Port == "" {
+		conn.Port = utils.GetDefaultPort(conn.Type)
+	}
+	if conn.PasswordStorage != types.PasswordVault {
+		conn.Password = ""
+	}
+	if conn.PasswordStorage != types.PasswordCommand {
+		conn.PasswordCommand = ""
+	}
+	if conn.PasswordStorage == types.PasswordCommand && conn.PasswordCommand == "" {
+		return conn, errors.New("password storage command needs --password-command")
+	}
+	if conn.SSH.Enabled && conn.SSH.Host == "" {
+		return conn, errors.New("an SSH tunnel needs --ssh-host")
+	}
+	if conn.SSH.ForwardAgent && conn.SSH.SkipHostKeyCheck {
+		return conn, errors.New("--ssh-forward-agent can't be combined with --ssh-skip-host-key-check")
+	}
+	return conn, nil
+}
+
+// unlocked runs fn, unlocking the vault and trying again when fn finds it
+// locked
+func (e *env) unlocked(fn func() error) error {
+	err := fn()
+	if !errors.Is(err, store.ErrVaultLocked) {
+		return err
+	}
+	master, err := e.secret(EnvMasterPassword, "Master password: ")
+	if err != nil {
+		return fmt.Errorf("the credential vault is locked: %w", err)
+	}
+	if err := e.store().Unlock(master); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// nameArg splits off the connection name given before the flags, as in
+// "nectar conn show prod --json"
+func nameArg(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "", args
+}
+
+// onlyName returns the one connection name a command takes, given before
+// or after its flags
+func onlyName(e *env, flags *flag.FlagSet, name string) (string, int, bool) {
+	switch {
+	case name == "" && flags.NArg() == 1:
+		return flags.Arg(0), ExitOK, true
+	case name != "" && flags.NArg() == 0:
+		return name, ExitOK, true
+	case name == "" && flags.NArg() == 0:
+		return "", e.failf(ExitUsage, "name a connection"), false
+	}
+	return "", e.failf(ExitUsage, "unexpected argument %q", flags.Arg(flags.NArg()-1)), false
+}
+
+func (e *env) printJSON(v any) int {
+	encoder := json.NewEncoder(e.stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return e.failf(ExitFailed, "%v", err)
+	}
+	return ExitOK
+}
+
+func runConnList(ctx context.Context, e *env, args []string) int {
+	flags := newFlags(e, "conn list", "[--json]")
+	asJSON := flags.Bool("json", false, "print the connections as JSON")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() > 0 {
+		return e.failf(ExitUsage, "unexpected argument %q", flags.Arg(0))
+	}
+
+	connections, err := e.store().Load()
+	if err != nil {
+		return e.failf(ExitFailed, "%v", err)
+	}
+	if *asJSON {
+		if connections == nil {
+			connections = []types.Connection{}
+		}
+		return e.printJSON(connections)
+	}
+
+	table := newTableWriter(e.stdout, true)
+	table.Columns([]string{"NAME", "TYPE", "TARGET"})
+	for _, conn := range connections {
+		table.Row([]any{conn.Name, conn.Type.String(), target(conn)})
+	}
+	if err := table.Close(); err != nil {
+		return e.failf(ExitFailed, "%v", err)
+	}
+	return ExitOK
+}
+
+// target says in one line where a connection goes
+func target(conn types.Connection) string {
+	if conn.Type == types.SQLite {
+		return conn.DatabaseFile
+	}
+	text := conn.Host
+	if conn.Port != "" {
+		text += ":" + conn.Port
+	}
+	if conn.User != "" {
+		text = conn.User + "@" + text
+	}
+	if conn.Database != "" {
+		text += "/" + conn.Database
+	}
+	if conn.SSH.Enabled {
+		text += " via " + conn.SSH.Host
+	}
+	return text
+}
+
+func runConnShow(ctx context.Context, e *env, args []string) int {
+	name, args := nameArg(args)
+	flags := newFlags(e, "conn show", "<name> [--json | --url]")
+	asJSON := flags.Bool("json", false, "print the connection as JSON")
+	asURL := flags.Bool("url", false, "print the connection as a URL, without its password")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	name, code, ok := onlyName(e, flags, name)
+	if !ok {
+		return code
+	}
+
+	conn, err := e.store().Get(name)
+	if err != nil {
+		return e.failf(ExitFailed, "%s: %v", name, err)
+	}
+	switch {
+	case *asJSON:
+		return e.printJSON(conn)
+	case *asURL:
+		fmt.Fprintln(e.stdout, utils.ConnectionURL(conn))
+		return ExitOK
+	}
+
+	var lines [][2]string
+	add := func(label, value string) {
+		if value != "" {
+			lines = append(lines, [2]string{label, value})
+		}
+	}
+	add("Name", conn.Name)
+	add("Type", conn.Type.String())
+	if conn.Type == types.SQLite {
+		add("File", conn.DatabaseFile)
+	} else {
+		add("Host", conn.Host)
+		add("Port", conn.Port)
+		add("User", conn.User)
+		switch conn.PasswordStorage {
+		case types.PasswordVault:
+			if conn.InVault {
+				add("Password", "kept in the vault")
+			}
+		case types.PasswordPrompt:
+			add("Password", "asked for on connect")
+		case types.PasswordCommand:
+			add("Password", "from "+conn.PasswordCommand)
+		}
+		add("Database", conn.Database)
+		add("TLS", conn.TLS.Mode.String())
+		add("CA file", conn.TLS.CAFile)
+		add("Cert file", conn.TLS.CertFile)
+		add("Key file", conn.TLS.KeyFile)
+		add("Server name", conn.TLS.ServerName)
+		if conn.SSH.Enabled {
+			add("SSH", target(types.Connection{User: conn.SSH.User, Host: conn.SSH.Host, Port: conn.SSH.Port}))
+			add("SSH key", conn.SSH.KeyFile)
+			if conn.SSH.UseAgent {
+				add("SSH agent", "yes")
+			}
+			if conn.SSH.ForwardAgent {
+				add("Agent forwarding", "yes")
+			}
+			if conn.SSH.SkipHostKeyCheck {
+				add("Host key", "not checked")
+			}
+		}
+	}
+	add("Color", conn.Color)
+	if conn.Sensitive {
+		add("History", "not kept")
+	}
+	add("URL", utils.ConnectionURL(conn))
+
+	for _, line := range lines {
+		fmt.Fprintf(e.stdout, "%-12s %s\n", line[0]+":", line[1])
+	}
+	return ExitOK
+}
+
+func runConnAdd(ctx context.Context, e *env, args []string) int {
+	name, args := nameArg(args)
+	flags := newFlags(e, "conn add", "<name> [flags]")
+	edits := addConnFlags(flags)
+	asJSON := flags.Bool("json", false, "print the saved connection as JSON")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() > 0 {
+		return e.failf(ExitUsage, "unexpected argument %q", flags.Arg(0))
+	}
+
+	conn, err := edits.apply(e, types.Connection{Name: name})
+	if err != nil {
+		return e.failf(ExitUsage, "%v", err)
+	}
+	if err := e.unlocked(func() error { return e.store().Save(conn) }); err != nil {
+		return e.failf(ExitFailed, "%s: %v", conn.Name, err)
+	}
+	return e.printSaved(conn.Name, *asJSON, "Saved")
+}
+
+func runConnEdit(ctx context.Context, e *env, args []string) int {
+	name, args := nameArg(args)
+	flags := newFlags(e, "conn edit", "<name> [flags]")
+	edits := addConnFlags(flags)
+	asJSON := flags.Bool("json", false, "print the saved connection as JSON")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	name, code, ok := onlyName(e, flags, name)
+	if !ok {
+		return code
+	}
+
+	conn, err := e.store().Get(name)
+	if err != nil {
+		return e.failf(ExitFailed, "%s: %v", name, err)
+	}
+	conn, err = edits.apply(e, conn)
+	if err != nil {
+		return e.failf(ExitUsage, "%v", err)
+	}
+	if err := e.unlocked(func() error { return e.store().Update(name, conn) }); err != nil {
+		return e.failf(ExitFailed, "%s: %v", name, err)
+	}
+	return e.printSaved(conn.Name, *asJSON, "Updated")
+}
+
+// printSaved reports a connection that was just saved, as JSON or as a line
+// on stderr
+func (e *env) printSaved(name string, asJSON bool, verb string) int {
+	if !asJSON {
+		fmt.Fprintf(e.stderr, "%s %s\n", verb, name)
+		return ExitOK
+	}
+	conn, err := e.store().Get(name)
+	if err != nil {
+		return e.failf(ExitFailed, "%s: %v", name, err)
+	}
+	return e.printJSON(conn)
+}
+
+func runConnRemove(ctx context.Context, e *env, args []string) int {
+	flags := newFlags(e, "conn rm", "<name>...")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() == 0 {
+		return e.failf(ExitUsage, "name a connection")
+	}
+
+	code := ExitOK
+	for _, name := range flags.Args() {
+		if err := e.store().Delete(name); err != nil {
+			code = e.failf(ExitFailed, "%s: %v", name, err)
+			continue
+		}
+		fmt.Fprintf(e.stderr, "Deleted %s\n", name)
+	}
+	return code
+}
+
+// stageReport is a stage of a connection test as JSON
+type stageReport struct {
+	Stage      string `json:"stage"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+func runConnTest(ctx context.Context, e *env, args []string) int {
+	name, args := nameArg(args)
+	flags := newFlags(e, "conn test", "<name|url> [--json]")
+	asJSON := flags.Bool("json", false, "print the stages as JSON")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	name, code, ok := onlyName(e, flags, name)
+	if !ok {
+		return code
+	}
+
+	conn, err := e.lookup(name)
+	if err != nil {
+		return e.failf(ExitConnect, "%s: %v", name, err)
+	}
+	// Resolve before starting the clock, since it may prompt for the password
+	conn, err = e.resolve(ctx, conn)
+	if err != nil {
+		return e.failf(exitCode(ctx, ExitConnect), "%s: %v", conn.Name, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	reports := []stageReport{}
+	failed := false
+	driver.Diagnose(ctx, conn, func(result driver.StageResult) {
+		if result.Status == driver.StatusRunning {
+			return
+		}
+		report := stageReport{
+			Stage:      result.Stage.String(),
+			Status:     result.Status.String(),
+			Detail:     result.Detail,
+			DurationMS: result.Duration.Milliseconds(),
+		}
+		if result.Err != nil {
+			report.Error = result.Err.Error()
+			failed = true
+		}
+		reports = append(reports, report)
+		if *asJSON {
+			return
+		}
+		switch result.Status {
+		case driver.StatusPassed:
+			fmt.Fprintf(e.stdout, "✓ %-15s %s %s\n", report.Stage, result.Detail, result.Duration.Round(time.Millisecond))
+		case driver.StatusFailed:
+			fmt.Fprintf(e.stdout, "✗ %-15s %s\n", report.Stage, report.Error)
+		case driver.StatusSkipped:
+			fmt.Fprintf(e.stdout, "- %-15s %s\n", report.Stage, result.Detail)
+		}
+	})
+
+	if *asJSON {
+		if code := e.printJSON(reports); code != ExitOK {
+			return code
+		}
+	}
+	if failed {
+		return exitCode(ctx, ExitConnect)
+	}
+	return ExitOK
+}
+
+// exported is the file conn export writes and conn import reads, shaped
+// like the connections file
+type exported struct {
+	Version     int                `json:"version"`
+	Connections []types.Connection `json:"connections"`
+}
+
+func runConnExport(ctx context.Context, e *env, args []string) int {
+	flags := newFlags(e, "conn export", "[-o file] [name...]")
+	output := flags.String("o", "", "file to write to (default stdout)")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	doc := exported{Version: store.FormatVersion, Connections: []types.Connection{}}
+	if flags.NArg() == 0 {
+		connections, err := e.store().Load()
+		if err != nil {
+			return e.failf(ExitFailed, "%v", err)
+		}
+		doc.Connections = append(doc.Connections, connections...)
+	}
+	for _, name := range flags.Args() {
+		conn, err := e.store().Get(name)
+		if err != nil {
+			return e.failf(ExitFailed, "%s: %v", name, err)
+		}
+		doc.Connections = append(doc.Connections, conn)
+	}
+	// Passwords don't travel with the file: neither the vault nor those an
+	// older version left in plain text, which it hasn't moved in yet
+	for i := range doc.Connections {
+		doc.Connections[i].InVault = false
+		doc.Connections[i].Password, doc.Connections[i].SSH.Password = "", ""
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return e.failf(ExitFailed, "%v", err)
+	}
+	data = append(data, '\n')
+	if *output == "" {
+		_, err = e.stdout.Write(data)
+	} else {
+		err = os.WriteFile(*output, data, 0o600)
+	}
+	if err != nil {
+		return e.failf(ExitFailed, "%v", err)
+	}
+	return ExitOK
+}
+
+func runConnImport(ctx context.Context, e *env, args []string) int {
+	flags := newFlags(e, "conn import", "[--replace] [file | -]")
+	replace := flags.Bool("replace", false, "overwrite saved connections of the same name rather than skipping them")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() > 1 {
+		return e.failf(ExitUsage, "unexpected argument %q", flags.Arg(1))
+	}
+
+	name := flags.Arg(0)
+	if name == "" {
+		name = "-"
+	}
+	data, err := readScript(e.stdin, name)
+	if err != nil {
+		return e.failf(ExitFailed, "%v", err)
+	}
+	connections, err := parseExported([]byte(data))
+	if err != nil {
+		return e.failf(ExitFailed, "%s: %v", name, err)
+	}
+
+	code := ExitOK
+	var saved, skipped int
+	for _, conn := range connections {
+		conn.InVault = false
+		conn, err := normalize(conn)
+		if err != nil {
+			code = e.failf(ExitFailed, "%s: %v", conn.Name, err)
+			continue
+		}
+		err = e.unlocked(func() error { return e.store().Save(conn) })
+		if errors.Is(err, store.ErrExists) && *replace {
+			err = e.unlocked(func() error { return e.store().Update(conn.Name, conn) })
+		} else if errors.Is(err, store.ErrExists) {
+			fmt.Fprintf(e.stderr, "Skipped %s, which is already saved\n", conn.Name)
+			skipped++
+			continue
+		}
+		if err != nil {
+			code = e.failf(ExitFailed, "%s: %v", conn.Name, err)
+			continue
+		}
+		saved++
+	}
+	fmt.Fprintf(e.stderr, "Imported %d %s", saved, plural(int64(saved), "connection"))
+	if skipped > 0 {
+		fmt.Fprintf(e.stderr, ", skipped %d", skipped)
+	}
+	fmt.Fprintln(e.stderr)
+	return code
+}
+
+// parseExported reads an exported file, or a bare list of connections. A
+// bare list carries no version, so it is taken to be from the first one and
+// migrated like any other.
+func parseExported(data []byte) ([]types.Connection, error) {
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err == nil {
+		return store.DecodeConnections(1, list)
+	}
+	var doc struct {
+		Version     int               `json:"version"`
+		Connections []json.RawMessage `json:"connections"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return store.DecodeConnections(doc.Version, doc.Connections)
+}
@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"nectar/driver"
+	"nectar/export"
+	"nectar/store"
+	"nectar/syntax"
+	"nectar/types"
+	"os"
+	"strings"
+	"time"
+)
+
+// connectTimeout bounds how long connecting may take, as it does for the
+// screens
+const connectTimeout = 15 * time.Second
+
+// formatTable is the --format that lines results up for reading; the rest
+// are the export formats
+const formatTable = "table"
+
+// runExec runs the statements of -e or -f in order, stopping at the first
+// that fails. Rows are printed to stdout in the chosen format; row counts of
+// other statements go to stderr so they don't mix with the results.
+func runExec(ctx context.Context, e *env, args []string) int {
+	flags := newFlags(e, "exec", "--conn <name|url> (-e SQL | -f file)")
+	var target, script, file, format, table string
+	var noHeader, quiet bool
+	var timeout time.Duration
+	flags.StringVar(&target, "conn", "", "saved connection name or connection URL")
+	flags.StringVar(&target, "c", "", "shorthand for --conn")
+	flags.StringVar(&script, "e", "", "SQL to run; several statements are separated by semicolons")
+	flags.StringVar(&file, "f", "", "file of SQL to run, or - for stdin")
+	flags.StringVar(&format, "format", formatTable, "output format: table, csv, tsv, json, ndjson, markdown or insert")
+	flags.StringVar(&table, "table", "", "table the statements of --format insert write to, as name or schema.name")
+	flags.BoolVar(&noHeader, "no-header", false, "leave out the header row of table, csv and tsv output")
+	flags.BoolVar(&quiet, "q", false, "don't report row counts on stderr")
+	flags.DurationVar(&timeout, "timeout", 0, "stop after this long, e.g. 30s (default no limit)")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	switch {
+	case flags.NArg() > 0:
+		return e.failf(ExitUsage, "unexpected argument %q", flags.Arg(0))
+	case target == "":
+		return e.failf(ExitUsage, "--conn is required")
+	case (script == "") == (file == ""):
+		return e.failf(ExitUsage, "give the SQL with either -e or -f")
+	}
+
+	opts := export.Options{NoHeader: noHeader}
+	if format != formatTable {
+		parsed, err := export.ParseFormat(format)
+		if err != nil {
+			return e.failf(ExitUsage, "%v", err)
+		}
+		opts.Format = parsed
+	}
+	switch {
+	case opts.Format == export.Insert && table == "":
+		return e.failf(ExitUsage, "--format insert needs --table")
+	case opts.Format != export.Insert && table != "":
+		return e.failf(ExitUsage, "--table only applies to --format insert")
+	}
+
+	if file != "" {
+		text, err := readScript(e.stdin, file)
+		if err != nil {
+			return e.failf(ExitFailed, "%v", err)
+		}
+		script = text
+	}
+
+	conn, err := e.lookup(target)
+	if err != nil {
+		return e.failf(ExitConnect, "%s: %v", target, err)
+	}
+	opts.Dialect = conn.Type
+	if table != "" {
+		// A schema-qualified name is quoted a part at a time
+		parts := strings.Split(table, ".")
+		for i, part := range parts {
+			parts[i] = syntax.QuoteIdentifier(part, conn.Type)
+		}
+		opts.Table = strings.Join(parts, ".")
+	}
+	statements := syntax.Split(script, conn.Type)
+	if len(statements) == 0 {
+		return e.failf(ExitUsage, "no statements to run")
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// The password may be typed at a prompt, which the connect timeout
+	// leaves alone
+	conn, err = e.resolve(ctx, conn)
+	if err != nil {
+		return e.failf(exitCode(ctx, ExitConnect), "%s: %v", conn.Name, err)
+	}
+	connectCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	d, err := driver.Connect(connectCtx, conn)
+	if err != nil {
+		return e.failf(exitCode(ctx, ExitConnect), "%s: %v", conn.Name, err)
+	}
+	defer d.Close()
+
+	for i, statement := range statements {
+		if i > 0 && syntax.ReturnsRows(statements[i-1].Text) && syntax.ReturnsRows(statement.Text) && format == formatTable {
+			fmt.Fprintln(e.stdout)
+		}
+		started := time.Now()
+		rows, err := e.execStatement(ctx, d, statement.Text, format, opts, quiet)
+		e.record(conn, statement.Text, started, rows, err)
+		if err != nil {
+			if len(statements) > 1 {
+				return e.failf(exitCode(ctx, ExitFailed), "statement %d: %v", i+1, err)
+			}
+			return e.failf(exitCode(ctx, ExitFailed), "%v", err)
+		}
+	}
+	return ExitOK
+}
+
+// execStatement runs one statement, printing its rows or its row count,
+// and returns how many rows it returned or changed
+func (e *env) execStatement(ctx context.Context, d driver.Driver, statement, format string, opts export.Options, quiet bool) (int64, error) {
+	if !syntax.ReturnsRows(statement) {
+		result, err := d.Exec(ctx, statement)
+		if err != nil {
+			return 0, err
+		}
+		if !quiet {
+			fmt.Fprintf(e.stderr, "%d %s affected\n", result.RowsAffected, plural(result.RowsAffected, "row"))
+		}
+		return result.RowsAffected, nil
+	}
+
+	var writer export.Writer
+	if format == formatTable {
+		writer = newTableWriter(e.stdout, !opts.NoHeader)
+	} else {
+		writer = export.NewWriter(e.stdout, opts)
+	}
+	var count int64
+	err := d.Stream(ctx, statement, writer.Columns, func(values []any) error {
+		count++
+		return writer.Row(values)
+	})
+	if err != nil {
+		return count, err
+	}
+	if err := writer.Close(); err != nil {
+		return count, err
+	}
+	if !quiet {
+		fmt.Fprintf(e.stderr, "(%d %s)\n", count, plural(count, "row"))
+	}
+	return count, nil
+}
+
+// record adds a statement to the query history, as the query editor does.
+// The history is a convenience, so failing to write it doesn't fail the run.
+func (e *env) record(conn types.Connection, statement string, started time.Time, rows int64, err error) {
+	entry := store.HistoryEntry{
+		Query:    strings.TrimSpace(statement),
+		Time:     started,
+		Duration: time.Since(started),
+		Rows:     rows,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	_ = e.history().Record(conn, entry)
+}
+
+// readScript reads the SQL of -f, from stdin when the name is -
+func readScript(stdin io.Reader, name string) (string, error) {
+	if name == "-" {
+		data, err := io.ReadAll(stdin)
+		return string(data), err
+	}
+	data, err := os.ReadFile(name)
+	return string(data), err
+}
+
+// exitCode is code, unless the run was interrupted. A --timeout running out
+// reports a deadline instead, so it fails like any other statement.
+func exitCode(ctx context.Context, code int) int {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ExitInterrupted
+	}
+	return code
+}
+
+func plural(n int64, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
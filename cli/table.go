@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"bufio"
+	"io"
+	"nectar/driver"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// tableWriter lines a result up in columns for reading in a terminal. The
+// widths depend on every row, so the rows are held until Close.
+type tableWriter struct {
+	out     *bufio.Writer
+	header  bool
+	columns []string
+	rows    [][]string
+	// numeric marks the columns whose values are all numbers, which are
+	// aligned to the right
+	numeric []bool
+}
+
+func newTableWriter(w io.Writer, header bool) *tableWriter {
+	return &tableWriter{out: bufio.NewWriter(w), header: header}
+}
+
+func (w *tableWriter) Columns(columns []string) error {
+	w.columns = columns
+	w.numeric = make([]bool, len(columns))
+	for i := range w.numeric {
+		w.numeric[i] = true
+	}
+	return nil
+}
+
+func (w *tableWriter) Row(values []any) error {
+	row := make([]string, len(values))
+	for i, value := range values {
+		text, null := driver.FormatValue(value)
+		// One value per line, whatever it holds
+		row[i] = strings.NewReplacer("\r\n", "↵", "\n", "↵", "\r", "↵", "\t", " ").Replace(text)
+		if !null && i < len(w.numeric) && !isNumber(value) {
+			w.numeric[i] = false
+		}
+	}
+	w.rows = append(w.rows, row)
+	return nil
+}
+
+func (w *tableWriter) Close() error {
+	widths := make([]int, len(w.columns))
+	if w.header {
+		for i, column := range w.columns {
+			widths[i] = ansi.StringWidth(column)
+		}
+	}
+	for _, row := range w.rows {
+		for i, cell := range row {
+			if i < len(widths) {
+				widths[i] = max(widths[i], ansi.StringWidth(cell))
+			}
+		}
+	}
+
+	if w.header {
+		w.line(w.columns, widths, func(int) bool { return false })
+		rules := make([]string, len(widths))
+		for i, width := range widths {
+			rules[i] = strings.Repeat("-", width)
+		}
+		w.out.WriteString(strings.Join(rules, "-+-") + "\n")
+	}
+	for _, row := range w.rows {
+		w.line(row, widths, func(i int) bool { return w.numeric[i] })
+	}
+	return w.out.Flush()
+}
+
+// line writes one row of cells padded to their column's width
+func (w *tableWriter) line(cells []string, widths []int, right func(int) bool) {
+	padded := make([]string, len(widths))
+	for i, width := range widths {
+		var cell string
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		pad := strings.Repeat(" ", width-ansi.StringWidth(cell))
+		if right(i) {
+			padded[i] = pad + cell
+		} else {
+			padded[i] = cell + pad
+		}
+	}
+	w.out.WriteString(strings.TrimRight(strings.Join(padded, " | "), " ") + "\n")
+}
+
+func isNumber(value any) bool {
+	switch value.(type) {
+	case int64, float64, float32:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,849 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"nectar/components/root"
+	"nectar/components/shared"
+	"nectar/driver"
+	"nectar/keymap"
+	"nectar/store"
+	"nectar/types"
+	"slices"
+	"strings"
+	"time"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	// Rows fetched per page
+	pageSize = 100
+	// Longest looking up a table's keys and committing its changes may take
+	keysTimeout   = 15 * time.Second
+	commitTimeout = time.Minute
+)
+
+// pageLoadedMsg carries one page of rows back to the model. total is only
+// set when the rows were counted as well.
+type pageLoadedMsg struct {
+	id       int
+	result   *driver.Result
+	total    int64
+	counted  bool
+	err      error
+	duration time.Duration
+}
+
+// DataModel pages through the rows of a table or view. Sorting and
+// filtering happen on the server, and the statement behind the page is
+// shown above the rows. Tables with a primary or unique key can be edited:
+// changes are staged, marked in the grid and written in one transaction
+// once their statements have been reviewed.
+type DataModel struct {
+	connection types.Connection
+	driver     driver.Driver
+	schema     *driver.Schema
+	object     driver.Object
+	keys       []string
+	history    *store.History
+
+	grid    shared.GridModel
+	form    filterFormModel
+	filters []Filter
+	sort    sortOrder
+	width   int
+	height  int
+
+	page int
+	// bounds[p] is the key of the last row before page p, when paging by key
+	bounds [][]any
+	total  int64
+
+	loading  bool
+	loadID   int
+	cancel   context.CancelFunc
+	busy     shared.BusyModel
+	result   *driver.Result
+	sql      string
+	err      error
+	duration time.Duration
+
+	// editKey is the key rows are updated and deleted by
+	editKey    []string
+	keysLoaded bool
+	keysErr    error
+	staged     staged
+	cell       cellEditorModel
+	preview    previewModel
+	committing bool
+	export     shared.ExportModel
+	// notice says why the last key did nothing, or how a commit went
+	notice string
+	// warned is set once esc has warned that leaving drops staged changes
+	warned bool
+}
+
+// NewData opens the rows of object. Committed changes are recorded in
+// history, which may be nil to keep none.
+func NewData(conn types.Connection, d driver.Driver, schema *driver.Schema, object driver.Object, history *store.History) DataModel {
+	return DataModel{
+		connection: conn,
+		driver:     d,
+		schema:     schema,
+		object:     object,
+		keys:       primaryKey(schema, object),
+		history:    history,
+		grid:       shared.NewGrid().Focus(),
+		form:       newFilterForm(),
+		cell:       newCellEditor(),
+		export:     shared.NewExport(),
+		busy:       shared.NewBusy(),
+		total:      -1,
+	}
+}
+
+// primaryKey finds the key columns of the object in the introspected
+// schema; views and tables outside it have none
+func primaryKey(schema *driver.Schema, object driver.Object) []string {
+	if schema == nil || object.Kind != driver.ObjectTable {
+		return nil
+	}
+	for _, table := range schema.Tables {
+		if table.Schema != object.Schema || table.Name != object.Name {
+			continue
+		}
+		var keys []string
+		for _, column := range table.Columns {
+			if column.PrimaryKey {
+				keys = append(keys, column.Name)
+			}
+		}
+		return keys
+	}
+	return nil
+}
+
+// SetSize sets the area the statement, filters and rows share
+func (m DataModel) SetSize(width, height int) DataModel {
+	m.width, m.height = width, height
+	m.grid = m.grid.SetSize(width, m.gridHeight())
+	m.export = m.export.SetSize(width, height)
+	return m
+}
+
+// The statement takes up to three lines, the rule and filter bar one each
+func (m DataModel) gridHeight() int {
+	return max(0, m.height-m.sqlHeight()-2)
+}
+
+func (m DataModel) sqlHeight() int {
+	return min(3, max(1, lipgloss.Height(m.renderSQL())))
+}
+
+// Capturing reports whether esc is handled here rather than leaving the
+// screen: it closes the filter form, cell editor, commit preview or export
+// dialog, cancels a load, or warns once that staged changes would be lost
+func (m DataModel) Capturing() bool {
+	return m.form.visible || m.export.Visible() || m.cell.visible || m.preview.visible || m.loading ||
+		!m.staged.empty() && !m.warned
+}
+
+// Bindings lists the keys the viewer answers to as it stands, besides the
+// moving about the grid does
+func (m DataModel) Bindings() []key.Binding {
+	switch {
+	case m.export.Visible(), m.cell.visible, m.form.visible:
+		return []key.Binding{keymap.Field.Done, keymap.Field.Cancel}
+	case m.preview.visible:
+		commit := keymap.Field.Done
+		commit.SetHelp("↵", "commit")
+		return []key.Binding{commit, keymap.Field.Cancel}
+	}
+
+	staged := !m.staged.empty()
+	return []key.Binding{
+		keymap.When(keymap.Data.NextPage, m.hasNext()),
+		keymap.When(keymap.Data.PrevPage, m.page > 0),
+		keymap.Data.Sort,
+		keymap.Data.Filter,
+		keymap.When(keymap.Data.ClearFilter, len(m.filters) > 0),
+		keymap.When(keymap.Data.ClearFilters, len(m.filters) > 0),
+		keymap.Data.Reload,
+		keymap.Data.EditCell,
+		keymap.Data.AddRow,
+		keymap.Data.DeleteRow,
+		keymap.When(keymap.Data.RevertRow, staged),
+		keymap.When(keymap.Data.RevertAll, staged),
+		keymap.When(keymap.Data.Commit, staged),
+		keymap.Data.EditSQL,
+		keymap.Data.Export,
+		keymap.When(keymap.Data.Cancel, m.Capturing()),
+	}
+}
+
+// Close cancels any load or export still running
+func (m DataModel) Close() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.export.Close()
+}
+
+// Connection returns the connection the table belongs to
+func (m DataModel) Connection() types.Connection {
+	return m.connection
+}
+
+// Object returns the table or view being shown
+func (m DataModel) Object() driver.Object {
+	return m.object
+}
+
+// Load fetches the first page, and the table's keys for editing
+func (m DataModel) Load() (DataModel, tea.Cmd) {
+	m, cmd := m.load(true)
+	return m, tea.Batch(cmd, m.loadKeys())
+}
+
+func (m DataModel) Update(msg tea.Msg) (DataModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case pageLoadedMsg:
+		// Pages from a cancelled or superseded load are dropped
+		if msg.id != m.loadID {
+			return m, nil
+		}
+		m.loading, m.cancel = false, nil
+		m.busy = m.busy.Stop()
+		m.err, m.duration = msg.err, msg.duration
+		if msg.err != nil {
+			return m, nil
+		}
+		if msg.counted {
+			m.total = msg.total
+		}
+		m.result = msg.result
+		m = m.rememberBound()
+		_, col := m.grid.Cursor()
+		m.grid = m.grid.SetResult(m.display()).SetCursor(0, col).SetMarks(m.marks())
+		return m, nil
+	case spinner.TickMsg:
+		// Each spinner only takes its own ticks
+		var busyCmd, exportCmd tea.Cmd
+		m.busy, busyCmd = m.busy.Update(msg)
+		m.export, exportCmd = m.export.Update(msg)
+		return m, tea.Batch(busyCmd, exportCmd)
+	case keysLoadedMsg:
+		m.keysLoaded, m.keysErr = true, msg.err
+		if len(msg.keys) > 0 {
+			m.editKey = msg.keys[0]
+		}
+		return m, nil
+	case cellEditedMsg:
+		m = m.stage(msg)
+		return m.refresh(), nil
+	case committedMsg:
+		m.committing = false
+		if msg.err != nil {
+			m.preview.err = msg.err
+			return m, nil
+		}
+		m.staged, m.preview = staged{}, previewModel{}
+		m.notice = "✓ Committed " + plural(msg.count, "change")
+		m.total = -1
+		return m.load(false)
+	case filterAppliedMsg:
+		m.filters = slices.DeleteFunc(m.filters, func(f Filter) bool { return f.Column == msg.filter.Column })
+		if !msg.filter.empty() {
+			m.filters = append(m.filters, msg.filter)
+		}
+		return m.load(true)
+	case tea.KeyMsg:
+		m.notice = ""
+		if !key.Matches(msg, keymap.Data.Cancel) {
+			m.warned = false
+		}
+		switch {
+		case m.export.Visible():
+			var cmd tea.Cmd
+			m.export, cmd = m.export.Update(msg)
+			return m, cmd
+		case m.preview.visible:
+			return m.updatePreview(msg)
+		case m.cell.visible:
+			var cmd tea.Cmd
+			m.cell, cmd = m.cell.Update(msg)
+			return m, cmd
+		case m.form.visible:
+			var cmd tea.Cmd
+			m.form, cmd = m.form.Update(msg)
+			m = m.SetSize(m.width, m.height)
+			return m, cmd
+		}
+
+		switch {
+		case key.Matches(msg, keymap.Data.Cancel):
+			if m.loading {
+				m.cancelLoad()
+				return m, nil
+			}
+			if !m.staged.empty() && !m.warned {
+				m.warned = true
+				m.notice = "Unsaved changes: ^s to review, esc again to drop them"
+				return m, nil
+			}
+		case key.Matches(msg, keymap.Data.EditCell):
+			return m.editCell()
+		case key.Matches(msg, keymap.Data.AddRow):
+			return m.addRow(), nil
+		case key.Matches(msg, keymap.Data.DeleteRow, keymap.Data.RevertRow):
+			ref, reason := m.current()
+			if reason != "" {
+				m.notice = reason
+				return m, nil
+			}
+			if key.Matches(msg, keymap.Data.DeleteRow) {
+				m = m.toggleDelete(ref)
+			} else {
+				m = m.revert(ref)
+			}
+			return m.refresh(), nil
+		case key.Matches(msg, keymap.Data.RevertAll):
+			m.staged = staged{}
+			return m.refresh(), nil
+		case key.Matches(msg, keymap.Data.Commit):
+			if m.staged.empty() {
+				m.notice = "Nothing to commit"
+				return m, nil
+			}
+			m.preview = previewModel{visible: true}
+			return m, nil
+		case key.Matches(msg, keymap.Data.NextPage):
+			if m.hasNext() {
+				m.page++
+				return m.load(false)
+			}
+			return m, nil
+		case key.Matches(msg, keymap.Data.PrevPage):
+			if m.page > 0 {
+				m.page--
+				return m.load(false)
+			}
+			return m, nil
+		case key.Matches(msg, keymap.Data.Sort):
+			if column, ok := m.column(); ok {
+				// The count still holds, but the pages start over
+				m.sort, m.page = nextSort(m.sort, column), 0
+				return m.load(false)
+			}
+			return m, nil
+		case key.Matches(msg, keymap.Data.Filter):
+			if column, ok := m.column(); ok {
+				current, found := m.filter(column)
+				var cmd tea.Cmd
+				m.form, cmd = m.form.open(column, current, found)
+				m = m.SetSize(m.width, m.height)
+				return m, cmd
+			}
+			return m, nil
+		case key.Matches(msg, keymap.Data.ClearFilter):
+			if column, ok := m.column(); ok {
+				if _, found := m.filter(column); found {
+					m.filters = slices.DeleteFunc(m.filters, func(f Filter) bool { return f.Column == column })
+					return m.load(true)
+				}
+			}
+			return m, nil
+		case key.Matches(msg, keymap.Data.ClearFilters):
+			if len(m.filters) > 0 {
+				m.filters = nil
+				return m.load(true)
+			}
+			return m, nil
+		case key.Matches(msg, keymap.Data.Reload):
+			return m.load(true)
+		case key.Matches(msg, keymap.Data.EditSQL):
+			return m, m.openEditor()
+		case key.Matches(msg, keymap.Data.Export):
+			return m.openExport(), nil
+		}
+	}
+
+	var cmd tea.Cmd
+	if m.export.Visible() {
+		m.export, cmd = m.export.Update(msg)
+		return m, cmd
+	}
+	if m.form.visible {
+		m.form, cmd = m.form.Update(msg)
+		return m, cmd
+	}
+	m.grid, cmd = m.grid.Update(msg)
+	return m, cmd
+}
+
+// editCell opens the cell editor on the cell under the cursor
+func (m DataModel) editCell() (DataModel, tea.Cmd) {
+	ref, reason := m.current()
+	if reason != "" {
+		m.notice = reason
+		return m, nil
+	}
+	if ref.insert < 0 {
+		if edit := m.staged.edit(ref.key, false); edit != nil && edit.deleted {
+			m.notice = "This row is marked for deletion; u keeps it"
+			return m, nil
+		}
+	}
+	column, ok := m.column()
+	if !ok {
+		return m, nil
+	}
+	value, _ := m.grid.Value()
+	var cmd tea.Cmd
+	m.cell, cmd = m.cell.open(ref, column, value)
+	return m, cmd
+}
+
+// addRow stages a new row, with every column left to its default, and
+// moves to it
+func (m DataModel) addRow() DataModel {
+	if reason := m.readOnly(); reason != "" {
+		m.notice = reason
+		return m
+	}
+	if m.result == nil {
+		return m
+	}
+	m.staged.inserts = append(slices.Clone(m.staged.inserts), map[string]any{})
+	m = m.refresh()
+	_, col := m.grid.Cursor()
+	m.grid = m.grid.SetCursor(len(m.result.Rows)+len(m.staged.inserts)-1, col)
+	return m
+}
+
+// updatePreview scrolls the statements about to be committed, and commits
+// them on enter
+func (m DataModel) updatePreview(msg tea.KeyMsg) (DataModel, tea.Cmd) {
+	if m.committing {
+		return m, nil
+	}
+	switch msg.String() {
+	case "esc":
+		m.preview = previewModel{}
+	case "up", "k":
+		m.preview.top = max(0, m.preview.top-1)
+	case "down", "j":
+		m.preview.top++
+	case "enter", "y":
+		return m.commit()
+	}
+	return m, nil
+}
+
+// refresh redraws the grid after the staged changes move on, keeping the
+// cursor where it was
+func (m DataModel) refresh() DataModel {
+	if m.result == nil {
+		return m
+	}
+	m.grid = m.grid.ReplaceResult(m.display()).SetMarks(m.marks())
+	return m
+}
+
+// nextSort cycles a column through ascending, descending and unsorted
+func nextSort(current sortOrder, column string) sortOrder {
+	switch {
+	case current.column != column:
+		return sortOrder{column: column}
+	case !current.descending:
+		return sortOrder{column: column, descending: true}
+	default:
+		return sortOrder{}
+	}
+}
+
+// column names the column under the grid's cursor
+func (m DataModel) column() (string, bool) {
+	if m.result == nil {
+		return "", false
+	}
+	_, col := m.grid.Cursor()
+	if col >= len(m.result.Columns) {
+		return "", false
+	}
+	return m.result.Columns[col], true
+}
+
+func (m DataModel) filter(column string) (Filter, bool) {
+	for _, f := range m.filters {
+		if f.Column == column {
+			return f, true
+		}
+	}
+	return Filter{}, false
+}
+
+// hasNext reports whether there are rows after the current page. Without
+// a count, a full page is taken to mean there may be more.
+func (m DataModel) hasNext() bool {
+	if m.result == nil {
+		return false
+	}
+	if m.total >= 0 {
+		return int64((m.page+1)*pageSize) < m.total
+	}
+	return len(m.result.Rows) == pageSize
+}
+
+// table names the object in the session's dialect
+func (m DataModel) table() tableName {
+	return tableName{dialect: m.connection.Type, schema: m.object.Schema, name: m.object.Name}
+}
+
+// query describes the current page
+func (m DataModel) query() pageQuery {
+	q := pageQuery{
+		tableName: m.table(),
+		filters:   m.filters,
+		sort:      m.sort,
+		keys:      m.keys,
+		offset:    m.page * pageSize,
+		limit:     pageSize,
+	}
+	if q.keyset() && m.page > 0 && m.page < len(m.bounds) {
+		q.after = m.bounds[m.page]
+	}
+	return q
+}
+
+// load fetches the current page in the background. A fresh load goes back
+// to the first page and counts the rows again, as after a filter changes.
+func (m DataModel) load(fresh bool) (DataModel, tea.Cmd) {
+	if fresh {
+		m.page, m.total = 0, -1
+	}
+	if m.page == 0 || !m.query().keyset() {
+		m.bounds = m.bounds[:0]
+	}
+	if m.cancel != nil {
+		m.cancel()
+	}
+
+	q := m.query()
+	m.sql = q.build(literals(q.dialect))
+	bind, args := parameters(q.dialect)
+	sql := q.build(bind)
+	count, countArgs := "", &[]any{}
+	if m.total < 0 {
+		var countBind binder
+		countBind, countArgs = parameters(q.dialect)
+		count = q.count(countBind)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.loadID++
+	m.loading, m.cancel = true, cancel
+	m.err = nil
+	label := fmt.Sprintf("Loading page %d", m.page+1)
+	if count != "" {
+		label += " and counting rows"
+	}
+	var tick tea.Cmd
+	m.busy, tick = m.busy.Start(label, nil)
+
+	id, d := m.loadID, m.driver
+	return m, tea.Batch(tick, func() tea.Msg {
+		defer cancel()
+		started := time.Now()
+
+		result, err := d.Query(ctx, sql, *args...)
+		if err != nil {
+			return pageLoadedMsg{id: id, err: err, duration: time.Since(started)}
+		}
+		msg := pageLoadedMsg{id: id, result: result}
+		if count != "" {
+			counted, err := d.Query(ctx, count, *countArgs...)
+			if err != nil {
+				return pageLoadedMsg{id: id, err: fmt.Errorf("counting rows: %w", err), duration: time.Since(started)}
+			}
+			msg.total, msg.counted = countOf(counted), true
+		}
+		msg.duration = time.Since(started)
+		return msg
+	})
+}
+
+// countOf reads the single number a COUNT(*) returns
+func countOf(result *driver.Result) int64 {
+	if len(result.Rows) == 0 || len(result.Rows[0]) == 0 {
+		return 0
+	}
+	switch n := result.Rows[0][0].(type) {
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	default:
+		var count int64
+		text, _ := driver.FormatValue(n)
+		fmt.Sscan(text, &count)
+		return count
+	}
+}
+
+// rememberBound keeps the key of the page's last row, where the next page
+// starts when paging by key
+func (m DataModel) rememberBound() DataModel {
+	if !m.query().keyset() || len(m.result.Rows) == 0 {
+		return m
+	}
+	last := m.result.Rows[len(m.result.Rows)-1]
+	key := make([]any, len(m.keys))
+	for i, name := range m.keys {
+		col := slices.Index(m.result.Columns, name)
+		if col < 0 {
+			// The key isn't in the rows after all; fall back to offsets
+			m.keys = nil
+			return m
+		}
+		key[i] = last[col]
+	}
+	for len(m.bounds) <= m.page {
+		m.bounds = append(m.bounds, nil)
+	}
+	m.bounds = append(m.bounds[:m.page+1], key)
+	return m
+}
+
+// display is the result as the grid shows it: the sort and filters are
+// marked in the column headers, staged values replace those read and new
+// rows follow the page
+func (m DataModel) display() *driver.Result {
+	columns := make([]string, len(m.result.Columns))
+	for i, name := range m.result.Columns {
+		columns[i] = name
+		if m.sort.column == name {
+			if m.sort.descending {
+				columns[i] += " ↓"
+			} else {
+				columns[i] += " ↑"
+			}
+		}
+		if _, ok := m.filter(name); ok {
+			columns[i] += " ⧩"
+		}
+	}
+	if m.staged.empty() {
+		return &driver.Result{Columns: columns, Rows: m.result.Rows}
+	}
+
+	rows := make([][]any, 0, len(m.result.Rows)+len(m.staged.inserts))
+	for i, row := range m.result.Rows {
+		if ref, ok := m.rowAt(i); ok {
+			if edit := m.staged.edit(ref.key, false); edit != nil && len(edit.values) > 0 {
+				row = slices.Clone(row)
+				for col, name := range m.result.Columns {
+					if value, changed := edit.values[name]; changed {
+						row[col] = value
+					}
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	for _, values := range m.staged.inserts {
+		row := make([]any, len(m.result.Columns))
+		for col, name := range m.result.Columns {
+			row[col] = values[name]
+		}
+		rows = append(rows, row)
+	}
+	return &driver.Result{Columns: columns, Rows: rows}
+}
+
+func (m *DataModel) cancelLoad() {
+	m.cancel()
+	m.loadID++
+	m.loading, m.cancel = false, nil
+	m.busy = m.busy.Stop()
+	m.err = context.Canceled
+}
+
+// openEditor hands the statement behind the page to the query editor
+func (m DataModel) openEditor() tea.Cmd {
+	conn, d, schema, sql := m.connection, m.driver, m.schema, m.sql+";"
+	return func() tea.Msg {
+		return root.OpenEditorMsg{Connection: conn, Driver: d, Schema: schema, Query: sql}
+	}
+}
+
+// openExport offers every row that passes the filters for export, in the
+// order shown. Staged changes aren't written, since they aren't committed.
+func (m DataModel) openExport() DataModel {
+	q := m.query()
+	bind, args := parameters(q.dialect)
+	sql := q.all(bind)
+	m.export = m.export.Open(m.driver, m.connection.Type, sql, *args, m.object.Name, q.from())
+	return m
+}
+
+func (m DataModel) View() string {
+	if m.export.Visible() {
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, m.export.View())
+	}
+
+	ruleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Surface2().Hex,
+			Dark:  catppuccin.Mocha.Surface2().Hex,
+		})
+
+	summary := m.summary()
+	rule := ruleStyle.Render(strings.Repeat("─", max(0, m.width-lipgloss.Width(summary)-3)))
+	sqlHeight, gridHeight := m.sqlHeight(), m.gridHeight()
+
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		lipgloss.NewStyle().Height(sqlHeight).MaxHeight(sqlHeight).Render(m.renderSQL()),
+		ruleStyle.Render("── ")+summary+" "+rule,
+		m.renderFilters(),
+		lipgloss.NewStyle().Height(gridHeight).MaxHeight(gridHeight).Render(m.renderRows()),
+	)
+}
+
+// renderSQL shows the statement behind the page, highlighted like the
+// editor and wrapped to the width
+func (m DataModel) renderSQL() string {
+	wrapped := ansi.Wrap(shared.HighlightSQL(m.sql, m.connection.Type), max(1, m.width-2), " ")
+	return " " + strings.ReplaceAll(wrapped, "\n", "\n ")
+}
+
+// renderFilters shows the cell editor or filter form while one is open,
+// otherwise the filters in force
+func (m DataModel) renderFilters() string {
+	if m.cell.visible {
+		return ansi.Truncate(m.cell.View(), m.width, "…")
+	}
+	if m.form.visible {
+		return ansi.Truncate(m.form.View(), m.width, "…")
+	}
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	filterStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Sky().Hex,
+			Dark:  catppuccin.Mocha.Sky().Hex,
+		})
+
+	if len(m.filters) == 0 {
+		return mutedStyle.Render(" No filters. Press f to filter the selected column.")
+	}
+	parts := make([]string, len(m.filters))
+	for i, f := range m.filters {
+		parts[i] = filterStyle.Render(f.Short())
+	}
+	line := " " + strings.Join(parts, mutedStyle.Render("  ·  ")) + mutedStyle.Render("  x: clear column  X: clear all")
+	return ansi.Truncate(line, m.width, "…")
+}
+
+// summary describes the page in the rule above the rows
+func (m DataModel) summary() string {
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Red().Hex,
+			Dark:  catppuccin.Mocha.Red().Hex,
+		})
+
+	successStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Green().Hex,
+			Dark:  catppuccin.Mocha.Green().Hex,
+		})
+
+	stagedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Peach().Hex,
+			Dark:  catppuccin.Mocha.Peach().Hex,
+		})
+
+	switch {
+	case m.notice != "":
+		return stagedStyle.Render(m.notice)
+	case m.loading:
+		return mutedStyle.Render("Loading… (esc to cancel)")
+	case m.err != nil:
+		return errorStyle.Render("✗ Failed")
+	case m.result == nil:
+		return ""
+	}
+
+	first := m.page * pageSize
+	var text string
+	switch {
+	case len(m.result.Rows) == 0:
+		text = "no rows"
+	default:
+		text = fmt.Sprintf("rows %d–%d", first+1, first+len(m.result.Rows))
+	}
+	if m.total >= 0 {
+		text += fmt.Sprintf(" of %d", m.total)
+	}
+
+	paging := "offset"
+	if m.query().keyset() {
+		paging = "keyset"
+	}
+	detail := fmt.Sprintf(" · page %d · %s · %s", m.page+1, paging, m.duration.Round(time.Millisecond))
+	summary := successStyle.Render("✓ "+text) + mutedStyle.Render(detail)
+	if !m.staged.empty() {
+		summary += mutedStyle.Render(" · ") + stagedStyle.Render(plural(m.staged.count(), "staged change"))
+	}
+	return summary
+}
+
+// renderRows shows the error of the last load, the statements waiting to
+// be committed, or the page in the grid
+func (m DataModel) renderRows() string {
+	if m.err != nil {
+		errorStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{
+				Light: catppuccin.Latte.Red().Hex,
+				Dark:  catppuccin.Mocha.Red().Hex,
+			})
+		return errorStyle.Width(m.width).Render(m.err.Error())
+	}
+	if m.preview.visible {
+		return m.renderPreview()
+	}
+	if m.busy.Shown() {
+		return lipgloss.Place(m.width, m.gridHeight(), lipgloss.Center, lipgloss.Center, m.busy.View(m.width))
+	}
+	if m.result == nil || m.gridHeight() < 3 {
+		return ""
+	}
+	return m.grid.View()
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,523 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"nectar/components/shared"
+	"nectar/driver"
+	"nectar/store"
+	"nectar/types"
+	"slices"
+	"strings"
+	"time"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// keysLoadedMsg carries the columns that identify the table's rows
+type keysLoadedMsg struct {
+	keys [][]string
+	err  error
+}
+
+// cellEditedMsg is sent by the cell editor when a value is staged. A nil
+// value is NULL.
+type cellEditedMsg struct {
+	row    rowRef
+	column string
+	value  any
+}
+
+// committedMsg reports how writing the staged changes went
+type committedMsg struct {
+	count int
+	err   error
+}
+
+// rowRef points at a row being edited: an existing one by its key, or a
+// new one by its place among the staged inserts
+type rowRef struct {
+	key    []any
+	insert int
+}
+
+// rowEdit is what is staged against an existing row
+type rowEdit struct {
+	key     []any
+	values  map[string]any
+	deleted bool
+}
+
+// staged holds the changes not yet written, edits in the order they were
+// first made and new rows after them
+type staged struct {
+	edits   map[string]*rowEdit
+	order   []string
+	inserts []map[string]any
+}
+
+func (s staged) empty() bool {
+	return len(s.order) == 0 && len(s.inserts) == 0
+}
+
+func (s staged) count() int {
+	return len(s.order) + len(s.inserts)
+}
+
+// edit returns the edit staged against the row with the given key,
+// creating it when asked to
+func (s *staged) edit(key []any, create bool) *rowEdit {
+	id := keyString(key)
+	if e, ok := s.edits[id]; ok || !create {
+		return e
+	}
+	if s.edits == nil {
+		s.edits = map[string]*rowEdit{}
+	}
+	e := &rowEdit{key: key, values: map[string]any{}}
+	s.edits[id] = e
+	s.order = append(s.order, id)
+	return e
+}
+
+// drop forgets everything staged against the row with the given key
+func (s *staged) drop(key []any) {
+	id := keyString(key)
+	delete(s.edits, id)
+	s.order = slices.DeleteFunc(slices.Clone(s.order), func(o string) bool { return o == id })
+}
+
+// keyString turns a key into a map key
+func keyString(key []any) string {
+	parts := make([]string, len(key))
+	for i, value := range key {
+		parts[i] = fmt.Sprintf("%T:%v", value, value)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// sameValue reports whether a staged value is what the row already holds
+func sameValue(a, b any) bool {
+	textA, nullA := driver.FormatValue(a)
+	textB, nullB := driver.FormatValue(b)
+	return nullA == nullB && textA == textB
+}
+
+// where matches a row by its key. A unique key may hold NULLs, which = never
+// matches; should several rows share them, the keyed statement affects more
+// than one row and the commit is rolled back.
+func (t tableName) where(columns []string, key []any, bind binder) string {
+	conditions := make([]string, len(columns))
+	for i, column := range columns {
+		if key[i] == nil {
+			conditions[i] = t.quote(column) + " IS NULL"
+			continue
+		}
+		conditions[i] = t.quote(column) + " = " + bind(key[i])
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// update writes the UPDATE for a row's changed values, in column order
+func (t tableName) update(columns, keyColumns []string, edit *rowEdit, bind binder) string {
+	var set []string
+	for _, column := range columns {
+		if value, ok := edit.values[column]; ok {
+			set = append(set, t.quote(column)+" = "+bind(value))
+		}
+	}
+	return "UPDATE " + t.from() + " SET " + strings.Join(set, ", ") + t.where(keyColumns, edit.key, bind)
+}
+
+func (t tableName) delete(keyColumns []string, key []any, bind binder) string {
+	return "DELETE FROM " + t.from() + t.where(keyColumns, key, bind)
+}
+
+// insert writes the INSERT for a new row. Columns left alone take their
+// defaults, which MySQL spells differently when none are given.
+func (t tableName) insert(columns []string, values map[string]any, bind binder) string {
+	var names, placeholders []string
+	for _, column := range columns {
+		if value, ok := values[column]; ok {
+			names = append(names, t.quote(column))
+			placeholders = append(placeholders, bind(value))
+		}
+	}
+	if len(names) == 0 {
+		if t.dialect == types.MySQL {
+			return "INSERT INTO " + t.from() + " () VALUES ()"
+		}
+		return "INSERT INTO " + t.from() + " DEFAULT VALUES"
+	}
+	return "INSERT INTO " + t.from() + " (" + strings.Join(names, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+}
+
+// statements writes the staged changes out, once with parameters to run
+// and once with the values inline to preview
+func (m DataModel) statements() ([]driver.Statement, []string) {
+	t := m.table()
+	var run []driver.Statement
+	var shown []string
+	add := func(keyed bool, write func(bind binder) string) {
+		bind, args := parameters(t.dialect)
+		run = append(run, driver.Statement{Query: write(bind), Args: *args, Keyed: keyed})
+		shown = append(shown, write(literals(t.dialect))+";")
+	}
+
+	for _, id := range m.staged.order {
+		edit := m.staged.edits[id]
+		switch {
+		case edit.deleted:
+			add(true, func(bind binder) string { return t.delete(m.editKey, edit.key, bind) })
+		case len(edit.values) > 0:
+			add(true, func(bind binder) string { return t.update(m.columns(), m.editKey, edit, bind) })
+		}
+	}
+	for _, values := range m.staged.inserts {
+		add(false, func(bind binder) string { return t.insert(m.columns(), values, bind) })
+	}
+	return run, shown
+}
+
+// loadKeys finds the columns that identify the table's rows, without which
+// it can't be edited
+func (m DataModel) loadKeys() tea.Cmd {
+	if m.object.Kind != driver.ObjectTable {
+		return nil
+	}
+	d, object := m.driver, m.object
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), keysTimeout)
+		defer cancel()
+		keys, err := d.Keys(ctx, object.Schema, object.Name)
+		return keysLoadedMsg{keys: keys, err: err}
+	}
+}
+
+// readOnly explains why the table can't be edited, if it can't
+func (m DataModel) readOnly() string {
+	switch {
+	case m.object.Kind != driver.ObjectTable:
+		return "Only tables can be edited, not a " + m.object.Kind.String()
+	case !m.keysLoaded:
+		return "Still looking up the table's keys"
+	case m.keysErr != nil:
+		return "Read only: finding the table's keys failed: " + m.keysErr.Error()
+	case m.editKey == nil:
+		return "Read only: " + m.object.Name + " has no primary or unique key"
+	default:
+		return ""
+	}
+}
+
+// columns lists the columns of the rows being shown
+func (m DataModel) columns() []string {
+	if m.result == nil {
+		return nil
+	}
+	return m.result.Columns
+}
+
+// rowAt says which row sits at a place in the grid, or false for a row
+// that can't be told apart from others because its key has a NULL
+func (m DataModel) rowAt(row int) (rowRef, bool) {
+	if m.result == nil || row < 0 {
+		return rowRef{}, false
+	}
+	if row >= len(m.result.Rows) {
+		insert := row - len(m.result.Rows)
+		return rowRef{insert: insert}, insert < len(m.staged.inserts)
+	}
+
+	key := make([]any, len(m.editKey))
+	for i, name := range m.editKey {
+		col := slices.Index(m.result.Columns, name)
+		if col < 0 || m.result.Rows[row][col] == nil {
+			return rowRef{}, false
+		}
+		key[i] = m.result.Rows[row][col]
+	}
+	return rowRef{key: key, insert: -1}, true
+}
+
+// current finds the row under the cursor for an edit, or explains why it
+// can't be edited
+func (m DataModel) current() (rowRef, string) {
+	if reason := m.readOnly(); reason != "" {
+		return rowRef{}, reason
+	}
+	row, _ := m.grid.Cursor()
+	ref, ok := m.rowAt(row)
+	if !ok {
+		return rowRef{}, "This row has a NULL in its key, so it can't be edited"
+	}
+	return ref, ""
+}
+
+// stage records a cell's new value, dropping the change again when it
+// puts back what the row held
+func (m DataModel) stage(msg cellEditedMsg) DataModel {
+	if msg.row.insert >= 0 {
+		if msg.row.insert < len(m.staged.inserts) {
+			m.staged.inserts[msg.row.insert][msg.column] = msg.value
+		}
+		return m
+	}
+
+	edit := m.staged.edit(msg.row.key, true)
+	if original, ok := m.original(msg.row.key, msg.column); ok && sameValue(original, msg.value) {
+		delete(edit.values, msg.column)
+	} else {
+		edit.values[msg.column] = msg.value
+	}
+	if len(edit.values) == 0 && !edit.deleted {
+		m.staged.drop(msg.row.key)
+	}
+	return m
+}
+
+// original looks up a column of a row on the current page as it was read
+func (m DataModel) original(key []any, column string) (any, bool) {
+	col := slices.Index(m.result.Columns, column)
+	if col < 0 {
+		return nil, false
+	}
+	id := keyString(key)
+	for row := range m.result.Rows {
+		if ref, ok := m.rowAt(row); ok && ref.insert < 0 && keyString(ref.key) == id {
+			return m.result.Rows[row][col], true
+		}
+	}
+	return nil, false
+}
+
+// toggleDelete marks the row under the cursor for deletion, or unmarks it.
+// A new row is simply dropped.
+func (m DataModel) toggleDelete(ref rowRef) DataModel {
+	if ref.insert >= 0 {
+		m.staged.inserts = slices.Delete(slices.Clone(m.staged.inserts), ref.insert, ref.insert+1)
+		return m
+	}
+	edit := m.staged.edit(ref.key, true)
+	edit.deleted = !edit.deleted
+	if !edit.deleted && len(edit.values) == 0 {
+		m.staged.drop(ref.key)
+	}
+	return m
+}
+
+// revert forgets what is staged against the row under the cursor
+func (m DataModel) revert(ref rowRef) DataModel {
+	if ref.insert >= 0 {
+		m.staged.inserts = slices.Delete(slices.Clone(m.staged.inserts), ref.insert, ref.insert+1)
+		return m
+	}
+	m.staged.drop(ref.key)
+	return m
+}
+
+// commit writes the staged changes in one transaction. The transaction
+// goes into the query history as one entry of the statements as previewed.
+func (m DataModel) commit() (DataModel, tea.Cmd) {
+	run, shown := m.statements()
+	if len(run) == 0 || m.committing {
+		return m, nil
+	}
+	m.committing = true
+	m.preview.err = nil
+	d, count := m.driver, m.staged.count()
+	conn, history := m.connection, m.history
+	return m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+		defer cancel()
+		started := time.Now()
+		err := d.Transact(ctx, run)
+		if history != nil {
+			entry := store.HistoryEntry{
+				Query:    strings.Join(shown, "\n"),
+				Time:     started,
+				Duration: time.Since(started),
+			}
+			if err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.Rows = int64(len(run))
+			}
+			// As in the query editor, a history that can't be written
+			// doesn't fail the commit
+			_ = history.Record(conn, entry)
+		}
+		return committedMsg{count: count, err: err}
+	}
+}
+
+// marks highlights the staged changes in the grid's rows
+func (m DataModel) marks() func(row, col int) shared.CellMark {
+	if m.staged.empty() || m.result == nil {
+		return nil
+	}
+	rows := make([][]shared.CellMark, len(m.result.Rows)+len(m.staged.inserts))
+	for row := range rows {
+		marks := make([]shared.CellMark, len(m.result.Columns))
+		ref, ok := m.rowAt(row)
+		switch {
+		case !ok:
+		case ref.insert >= 0:
+			for col := range marks {
+				marks[col] = shared.MarkInserted
+			}
+		default:
+			edit := m.staged.edit(ref.key, false)
+			if edit == nil {
+				break
+			}
+			for col, name := range m.result.Columns {
+				if edit.deleted {
+					marks[col] = shared.MarkDeleted
+				} else if _, changed := edit.values[name]; changed {
+					marks[col] = shared.MarkChanged
+				}
+			}
+		}
+		rows[row] = marks
+	}
+	return func(row, col int) shared.CellMark {
+		if row >= len(rows) || col >= len(rows[row]) {
+			return shared.MarkNone
+		}
+		return rows[row][col]
+	}
+}
+
+// cellEditorModel edits one cell's value on a single line
+type cellEditorModel struct {
+	visible bool
+	row     rowRef
+	column  string
+	input   textinput.Model
+}
+
+func newCellEditor() cellEditorModel {
+	input := textinput.New()
+	input.Prompt = ""
+	input.Placeholder = "NULL"
+	input.CharLimit = 0
+	input.Width = 40
+	return cellEditorModel{input: input}
+}
+
+// open starts editing a cell from its current value
+func (m cellEditorModel) open(row rowRef, column string, value any) (cellEditorModel, tea.Cmd) {
+	m.visible, m.row, m.column = true, row, column
+	// NULL starts out empty, with the placeholder saying so
+	text, null := driver.FormatValue(value)
+	if null {
+		text = ""
+	}
+	m.input.SetValue(text)
+	m.input.CursorEnd()
+	return m, m.input.Focus()
+}
+
+func (m cellEditorModel) close() cellEditorModel {
+	m.visible = false
+	m.input.Blur()
+	return m
+}
+
+func (m cellEditorModel) Update(msg tea.Msg) (cellEditorModel, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			return m.close(), nil
+		case "enter", "ctrl+n":
+			edited := cellEditedMsg{row: m.row, column: m.column, value: m.input.Value()}
+			if msg.String() == "ctrl+n" {
+				edited.value = nil
+			}
+			return m.close(), func() tea.Msg { return edited }
+		}
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m cellEditorModel) View() string {
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Peach().Hex,
+			Dark:  catppuccin.Mocha.Peach().Hex,
+		}).
+		Bold(true)
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	return " " + labelStyle.Render("Edit "+m.column) + " " + m.input.View() +
+		mutedStyle.Render("  enter stage  ^n NULL  esc cancel")
+}
+
+// previewModel is the list of statements a commit will run, shown for a
+// last look before they do
+type previewModel struct {
+	visible bool
+	top     int
+	err     error
+}
+
+// renderPreview draws the statements a commit will run in place of the rows
+func (m DataModel) renderPreview() string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Bold(true)
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Red().Hex,
+			Dark:  catppuccin.Mocha.Red().Hex,
+		})
+
+	_, shown := m.statements()
+	height := m.gridHeight()
+
+	header := []string{" " + titleStyle.Render(fmt.Sprintf("Commit %s in one transaction?", plural(len(shown), "statement")))}
+	switch {
+	case m.committing:
+		header = append(header, mutedStyle.Render(" Committing…"))
+	case m.preview.err != nil:
+		header = append(header, errorStyle.Width(m.width).Render(" ✗ Rolled back: "+m.preview.err.Error()))
+	default:
+		header = append(header, mutedStyle.Render(" enter commit  ↑↓ scroll  esc keep editing"))
+	}
+	header = append(header, "")
+
+	var lines []string
+	for _, stmt := range shown {
+		wrapped := ansi.Wrap(shared.HighlightSQL(stmt, m.connection.Type), max(1, m.width-2), " ")
+		for _, line := range strings.Split(wrapped, "\n") {
+			lines = append(lines, " "+line)
+		}
+	}
+
+	room := max(0, height-len(header))
+	top := max(0, min(m.preview.top, len(lines)-room))
+	lines = lines[top:min(len(lines), top+room)]
+	return strings.Join(append(header, lines...), "\n")
+}
@@ -0,0 +1,158 @@
+package data
+
+import (
+	"slices"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// filterAppliedMsg is sent by the form when a filter is confirmed
+type filterAppliedMsg struct {
+	filter Filter
+}
+
+// filterFormModel asks for one column's filter on a single line: up and
+// down pick the operator, tab moves between the bounds of a range
+type filterFormModel struct {
+	visible bool
+	column  string
+	op      FilterOp
+	value   textinput.Model
+	to      textinput.Model
+	onTo    bool
+}
+
+func newFilterForm() filterFormModel {
+	value := textinput.New()
+	value.Prompt = ""
+	value.Placeholder = "value"
+	value.CharLimit = 1024
+	value.Width = 24
+
+	to := textinput.New()
+	to.Prompt = ""
+	to.Placeholder = "to"
+	to.CharLimit = 1024
+	to.Width = 24
+
+	return filterFormModel{value: value, to: to}
+}
+
+// open shows the form for a column, starting from its current filter
+func (m filterFormModel) open(column string, current Filter, ok bool) (filterFormModel, tea.Cmd) {
+	m.visible, m.column, m.onTo = true, column, false
+	m.op = FilterEquals
+	m.value.SetValue("")
+	m.to.SetValue("")
+	if ok {
+		m.op = current.Op
+		m.value.SetValue(current.Value)
+		m.to.SetValue(current.To)
+	}
+	m.to.Blur()
+	return m, m.value.Focus()
+}
+
+func (m filterFormModel) close() filterFormModel {
+	m.visible = false
+	m.value.Blur()
+	m.to.Blur()
+	return m
+}
+
+// takesValue reports whether the operator compares against typed text
+func (m filterFormModel) takesValue() bool {
+	return m.op != FilterNull && m.op != FilterNotNull
+}
+
+func (m filterFormModel) Update(msg tea.Msg) (filterFormModel, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			return m.close(), nil
+		case "enter":
+			filter := Filter{Column: m.column, Op: m.op}
+			if m.takesValue() {
+				filter.Value = m.value.Value()
+			}
+			if m.op == FilterRange {
+				filter.To = m.to.Value()
+			}
+			return m.close(), func() tea.Msg { return filterAppliedMsg{filter: filter} }
+		case "up", "down":
+			i := slices.Index(filterOps, m.op)
+			if msg.String() == "up" {
+				i--
+			} else {
+				i++
+			}
+			m.op = filterOps[(i+len(filterOps))%len(filterOps)]
+			if m.op != FilterRange && m.onTo {
+				m.onTo = false
+				m.to.Blur()
+				return m, m.value.Focus()
+			}
+			return m, nil
+		case "tab", "shift+tab":
+			if m.op != FilterRange {
+				return m, nil
+			}
+			m.onTo = !m.onTo
+			if m.onTo {
+				m.value.Blur()
+				return m, m.to.Focus()
+			}
+			m.to.Blur()
+			return m, m.value.Focus()
+		}
+	}
+
+	if !m.takesValue() {
+		return m, nil
+	}
+	var cmd tea.Cmd
+	if m.onTo {
+		m.to, cmd = m.to.Update(msg)
+	} else {
+		m.value, cmd = m.value.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m filterFormModel) View() string {
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Bold(true)
+
+	opStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Sky().Hex,
+			Dark:  catppuccin.Mocha.Sky().Hex,
+		})
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	line := " " + labelStyle.Render("Filter "+m.column) + " " + opStyle.Render("‹"+m.op.String()+"›")
+	switch {
+	case m.op == FilterRange:
+		line += " " + m.value.View() + mutedStyle.Render(" to ") + m.to.View()
+	case m.takesValue():
+		line += " " + m.value.View()
+	}
+
+	hints := "  ↑↓ operator  enter apply  esc cancel"
+	if m.op == FilterRange {
+		hints = "  ↑↓ operator  tab from/to  enter apply  esc cancel"
+	}
+	return line + mutedStyle.Render(hints)
+}
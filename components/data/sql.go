@@ -0,0 +1,294 @@
+package data
+
+import (
+	"fmt"
+	"nectar/driver"
+	"nectar/syntax"
+	"nectar/types"
+	"strings"
+)
+
+// FilterOp is how a filter compares a column's values
+type FilterOp int
+
+const (
+	FilterEquals FilterOp = iota
+	FilterContains
+	FilterRange
+	FilterNull
+	FilterNotNull
+)
+
+// filterOps lists the operators in the order the filter form cycles them
+var filterOps = []FilterOp{FilterEquals, FilterContains, FilterRange, FilterNull, FilterNotNull}
+
+func (op FilterOp) String() string {
+	switch op {
+	case FilterEquals:
+		return "equals"
+	case FilterContains:
+		return "contains"
+	case FilterRange:
+		return "between"
+	case FilterNull:
+		return "is null"
+	case FilterNotNull:
+		return "is not null"
+	default:
+		return "unknown"
+	}
+}
+
+// Filter keeps the rows whose column matches. A range may leave either
+// bound empty to leave that side open.
+type Filter struct {
+	Column string
+	Op     FilterOp
+	Value  string
+	To     string
+}
+
+// Short describes the filter for the filter bar, e.g. name ~ "bob"
+func (f Filter) Short() string {
+	switch f.Op {
+	case FilterEquals:
+		return fmt.Sprintf("%s = %q", f.Column, f.Value)
+	case FilterContains:
+		return fmt.Sprintf("%s ~ %q", f.Column, f.Value)
+	case FilterRange:
+		switch {
+		case f.Value == "":
+			return fmt.Sprintf("%s ≤ %q", f.Column, f.To)
+		case f.To == "":
+			return fmt.Sprintf("%s ≥ %q", f.Column, f.Value)
+		default:
+			return fmt.Sprintf("%s %q‥%q", f.Column, f.Value, f.To)
+		}
+	default:
+		return f.Column + " " + f.Op.String()
+	}
+}
+
+// empty reports whether the filter would not narrow anything, such as a
+// contains with nothing to look for
+func (f Filter) empty() bool {
+	switch f.Op {
+	case FilterContains:
+		return f.Value == ""
+	case FilterRange:
+		return f.Value == "" && f.To == ""
+	default:
+		return false
+	}
+}
+
+// sortOrder is the column the rows are sorted by, if any
+type sortOrder struct {
+	column     string
+	descending bool
+}
+
+// tableName is a table as statements in a dialect refer to it
+type tableName struct {
+	dialect types.ConnectionType
+	schema  string
+	name    string
+}
+
+// quote always quotes a name: a reserved word left bare breaks the query,
+// or on PostgreSQL may even read something else, as user does
+func (t tableName) quote(name string) string {
+	return syntax.QuoteIdentifier(name, t.dialect)
+}
+
+// from names the table, qualified with its schema
+func (t tableName) from() string {
+	if t.schema == "" {
+		return t.quote(t.name)
+	}
+	return t.quote(t.schema) + "." + t.quote(t.name)
+}
+
+// pageQuery describes one page of a table's rows
+type pageQuery struct {
+	tableName
+	filters []Filter
+	sort    sortOrder
+	// keys are the primary key columns; with them, pages after the first
+	// start after the key in after instead of skipping offset rows
+	keys   []string
+	after  []any
+	offset int
+	limit  int
+}
+
+// keyset reports whether the page is found by key rather than by offset,
+// which needs a primary key and rows in its order
+func (q pageQuery) keyset() bool {
+	if len(q.keys) == 0 {
+		return false
+	}
+	return q.sort.column == "" || len(q.keys) == 1 && q.sort.column == q.keys[0]
+}
+
+// binder writes a value into a statement, as a parameter or a literal
+type binder func(value any) string
+
+// parameters returns a binder writing the dialect's placeholders and the
+// arguments it collects
+func parameters(dialect types.ConnectionType) (binder, *[]any) {
+	args := &[]any{}
+	return func(value any) string {
+		*args = append(*args, value)
+		if dialect == types.PostgreSQL {
+			return fmt.Sprintf("$%d", len(*args))
+		}
+		return "?"
+	}, args
+}
+
+// literals returns a binder writing values inline, for statements that are
+// shown rather than run. The statements run bind their values, so one with
+// no literal in the dialect is only shown as text.
+func literals(dialect types.ConnectionType) binder {
+	return func(value any) string {
+		literal, err := driver.Literal(value, dialect)
+		if err != nil {
+			text, _ := driver.FormatValue(value)
+			return driver.QuoteString(text, dialect)
+		}
+		return literal
+	}
+}
+
+// text casts a column to text so any type can be searched with LIKE
+func (q pageQuery) text(column string) string {
+	switch q.dialect {
+	case types.PostgreSQL:
+		return "CAST(" + column + " AS text)"
+	case types.MySQL:
+		return "CAST(" + column + " AS CHAR)"
+	default:
+		return "CAST(" + column + " AS TEXT)"
+	}
+}
+
+// like is the case-insensitive LIKE; MySQL and SQLite compare without case
+// already, PostgreSQL needs ILIKE
+func (q pageQuery) like() string {
+	if q.dialect == types.PostgreSQL {
+		return "ILIKE"
+	}
+	return "LIKE"
+}
+
+// escapeLike makes % and _ in text match themselves, using ! as the escape
+// since backslash means something else in MySQL strings
+func escapeLike(text string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(text)
+}
+
+// conditions writes the filters, and the keyset bound when there is one,
+// as the parts of a WHERE clause
+func (q pageQuery) conditions(bind binder, paging bool) []string {
+	var where []string
+	for _, f := range q.filters {
+		column := q.quote(f.Column)
+		switch f.Op {
+		case FilterEquals:
+			where = append(where, column+" = "+bind(f.Value))
+		case FilterContains:
+			where = append(where, fmt.Sprintf("%s %s %s ESCAPE '!'", q.text(column), q.like(), bind("%"+escapeLike(f.Value)+"%")))
+		case FilterRange:
+			if f.Value != "" {
+				where = append(where, column+" >= "+bind(f.Value))
+			}
+			if f.To != "" {
+				where = append(where, column+" <= "+bind(f.To))
+			}
+		case FilterNull:
+			where = append(where, column+" IS NULL")
+		case FilterNotNull:
+			where = append(where, column+" IS NOT NULL")
+		}
+	}
+
+	if paging && q.keyset() && q.after != nil {
+		op := ">"
+		if q.sort.descending {
+			op = "<"
+		}
+		keys := make([]string, len(q.keys))
+		values := make([]string, len(q.after))
+		for i, key := range q.keys {
+			keys[i] = q.quote(key)
+			values[i] = bind(q.after[i])
+		}
+		if len(keys) == 1 {
+			where = append(where, keys[0]+" "+op+" "+values[0])
+		} else {
+			where = append(where, "("+strings.Join(keys, ", ")+") "+op+" ("+strings.Join(values, ", ")+")")
+		}
+	}
+	return where
+}
+
+// orderBy sorts by the chosen column and then by the key, so rows that tie
+// still come in the same order from one page to the next
+func (q pageQuery) orderBy() string {
+	direction := ""
+	if q.sort.descending {
+		direction = " DESC"
+	}
+	var order []string
+	if q.sort.column != "" {
+		order = append(order, q.quote(q.sort.column)+direction)
+	}
+	for _, key := range q.keys {
+		if key == q.sort.column {
+			continue
+		}
+		if q.keyset() {
+			order = append(order, q.quote(key)+direction)
+		} else {
+			order = append(order, q.quote(key))
+		}
+	}
+	if len(order) == 0 {
+		return ""
+	}
+	return " ORDER BY " + strings.Join(order, ", ")
+}
+
+// build writes the SELECT for the page
+func (q pageQuery) build(bind binder) string {
+	sql := "SELECT * FROM " + q.from()
+	if where := q.conditions(bind, true); len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	sql += q.orderBy()
+	sql += fmt.Sprintf(" LIMIT %d", q.limit)
+	if !q.keyset() && q.offset > 0 {
+		sql += fmt.Sprintf(" OFFSET %d", q.offset)
+	}
+	return sql
+}
+
+// all writes the SELECT for every row that passes the filters, in the
+// page's order, as an export reads them
+func (q pageQuery) all(bind binder) string {
+	sql := "SELECT * FROM " + q.from()
+	if where := q.conditions(bind, false); len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	return sql + q.orderBy()
+}
+
+// count writes the statement counting every row that passes the filters
+func (q pageQuery) count(bind binder) string {
+	sql := "SELECT COUNT(*) FROM " + q.from()
+	if where := q.conditions(bind, false); len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	return sql
+}
@@ -0,0 +1,345 @@
+package query
+
+import (
+	"nectar/driver"
+	"nectar/syntax"
+	"nectar/types"
+	"slices"
+	"strings"
+	"unicode"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Most completions the popup offers, and shows at once
+const (
+	maxCandidates = 50
+	popupHeight   = 8
+)
+
+type candidateKind int
+
+const (
+	candidateColumn candidateKind = iota
+	candidateTable
+	candidateView
+	candidateSchema
+	candidateFunction
+	candidateKeyword
+)
+
+// candidate is one completion, written as text
+type candidate struct {
+	kind   candidateKind
+	text   string
+	detail string
+}
+
+// catalog indexes a session's schema for completion. It is built once per
+// session and again whenever the schema is refreshed.
+type catalog struct {
+	dialect  types.ConnectionType
+	schema   *driver.Schema
+	keywords []string
+	// Tables by lower case name, several when schemas share a name
+	tables map[string][]*driver.Table
+}
+
+func newCatalog(dialect types.ConnectionType, schema *driver.Schema) catalog {
+	c := catalog{
+		dialect:  dialect,
+		schema:   schema,
+		keywords: syntax.Keywords(dialect),
+		tables:   map[string][]*driver.Table{},
+	}
+	if schema == nil {
+		c.schema = &driver.Schema{}
+	}
+	for i := range c.schema.Tables {
+		table := &c.schema.Tables[i]
+		key := strings.ToLower(table.Name)
+		c.tables[key] = append(c.tables[key], table)
+	}
+	return c
+}
+
+// table finds a table by its (optional) schema and name, ignoring case
+func (c catalog) table(schema, name string) *driver.Table {
+	for _, table := range c.tables[strings.ToLower(name)] {
+		if schema == "" || strings.EqualFold(table.Schema, schema) {
+			return table
+		}
+	}
+	return nil
+}
+
+// candidates lists what fits the completion context, best match first
+func (c catalog) candidates(completion syntax.Completion) []candidate {
+	var all []candidate
+	addColumns := func(table *driver.Table, qualified bool) {
+		for _, column := range table.Columns {
+			detail := column.Type
+			if qualified {
+				detail = table.Name + " · " + column.Type
+			}
+			all = append(all, candidate{kind: candidateColumn, text: column.Name, detail: detail})
+		}
+	}
+	addTables := func(schema string) {
+		for _, table := range c.schema.Tables {
+			if schema != "" && !strings.EqualFold(table.Schema, schema) {
+				continue
+			}
+			kind := candidateTable
+			if table.Kind == driver.KindView {
+				kind = candidateView
+			}
+			all = append(all, candidate{kind: kind, text: table.Name, detail: table.Schema + " · " + table.Kind.String()})
+		}
+	}
+
+	switch completion.Kind {
+	case syntax.ContextNone:
+		return nil
+	case syntax.ContextMember:
+		// An alias or table name gives its columns, a schema its tables
+		qualifier := completion.Qualifier
+		var table *driver.Table
+		for _, ref := range completion.Tables {
+			if strings.EqualFold(ref.Alias, qualifier) {
+				table = c.table(ref.Schema, ref.Name)
+				break
+			}
+		}
+		if table == nil {
+			table = c.table("", qualifier)
+		}
+		if table != nil {
+			addColumns(table, false)
+		}
+		if slices.ContainsFunc(c.schema.Schemas, func(s string) bool { return strings.EqualFold(s, qualifier) }) {
+			addTables(qualifier)
+			for _, function := range c.schema.Functions {
+				if strings.EqualFold(function.Schema, qualifier) {
+					all = append(all, candidate{kind: candidateFunction, text: function.Name, detail: "function"})
+				}
+			}
+		}
+	case syntax.ContextTable:
+		addTables("")
+		if c.dialect == types.PostgreSQL {
+			for _, schema := range c.schema.Schemas {
+				all = append(all, candidate{kind: candidateSchema, text: schema, detail: "schema"})
+			}
+		}
+	case syntax.ContextGeneral:
+		seen := map[*driver.Table]bool{}
+		for _, ref := range completion.Tables {
+			if table := c.table(ref.Schema, ref.Name); table != nil && !seen[table] {
+				seen[table] = true
+				addColumns(table, len(completion.Tables) > 1)
+			}
+		}
+		for _, function := range c.schema.Functions {
+			all = append(all, candidate{kind: candidateFunction, text: function.Name, detail: "function"})
+		}
+		for _, keyword := range c.keywords {
+			all = append(all, candidate{kind: candidateKeyword, text: keyword, detail: "keyword"})
+		}
+	}
+
+	type scored struct {
+		candidate
+		score int
+	}
+	var matches []scored
+	for _, cand := range all {
+		if score, ok := fuzzyMatch(cand.text, completion.Prefix); ok {
+			matches = append(matches, scored{cand, score})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b scored) int {
+		if a.score != b.score {
+			return b.score - a.score
+		}
+		if a.kind != b.kind {
+			return int(a.kind) - int(b.kind)
+		}
+		return strings.Compare(a.text, b.text)
+	})
+
+	candidates := make([]candidate, 0, min(len(matches), maxCandidates))
+	for i, match := range matches {
+		// A column can appear once per table; offer it once
+		if i > 0 && match.text == matches[i-1].text && match.kind == matches[i-1].kind {
+			continue
+		}
+		if len(candidates) == maxCandidates {
+			break
+		}
+		candidates = append(candidates, match.candidate)
+	}
+	return candidates
+}
+
+// fuzzyMatch reports whether the letters of pattern appear in text in
+// order, ignoring case. Prefixes score highest, then substrings, then
+// scattered matches, with fewer gaps scoring better.
+func fuzzyMatch(text, pattern string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+	lowerText, lowerPattern := strings.ToLower(text), strings.ToLower(pattern)
+	if strings.HasPrefix(lowerText, lowerPattern) {
+		return 3000 - len(text), true
+	}
+	if i := strings.Index(lowerText, lowerPattern); i >= 0 {
+		return 2000 - i, true
+	}
+
+	want := []rune(lowerPattern)
+	gaps, last := 0, -1
+	for i, r := range []rune(lowerText) {
+		if len(want) == 0 {
+			break
+		}
+		if r == want[0] {
+			if last >= 0 && i > last+1 {
+				gaps++
+			}
+			last = i
+			want = want[1:]
+		}
+	}
+	if len(want) > 0 {
+		return 0, false
+	}
+	return 1000 - gaps*10 - len(text), true
+}
+
+// completionModel is the popup of completions under the cursor
+type completionModel struct {
+	visible    bool
+	candidates []candidate
+	selected   int
+	// prefix is how many runes before the cursor the chosen completion
+	// replaces
+	prefix int
+	// explicit is set when asked for with ctrl+space, which keeps the popup
+	// up even before anything is typed
+	explicit bool
+}
+
+// update refreshes the popup for the editor's text and cursor. It opens
+// by itself after a dot or two letters of a word.
+func (m completionModel) update(c catalog, editor EditorModel, explicit bool) completionModel {
+	completion := syntax.CompletionAt(editor.Value(), editor.Offset(), c.dialect)
+	prefix := []rune(completion.Prefix)
+	explicit = explicit || m.visible && m.explicit
+	if !explicit && completion.Kind != syntax.ContextMember && len(prefix) < 2 {
+		return completionModel{}
+	}
+
+	candidates := c.candidates(completion)
+	if len(candidates) == 0 || len(candidates) == 1 && candidates[0].text == completion.Prefix {
+		return completionModel{}
+	}
+
+	// Keep the same entry selected while it still matches
+	selected := 0
+	if m.visible && m.selected < len(m.candidates) {
+		current := m.candidates[m.selected]
+		if i := slices.Index(candidates, current); i >= 0 {
+			selected = i
+		}
+	}
+	return completionModel{
+		visible:    true,
+		candidates: candidates,
+		selected:   selected,
+		prefix:     len(prefix),
+		explicit:   explicit,
+	}
+}
+
+func (m completionModel) move(delta int) completionModel {
+	m.selected = (m.selected + delta + len(m.candidates)) % len(m.candidates)
+	return m
+}
+
+// accept writes the selected completion over the word being typed
+func (m completionModel) accept(c catalog, editor EditorModel) EditorModel {
+	cand := m.candidates[m.selected]
+	text := cand.text
+	switch cand.kind {
+	case candidateKeyword:
+		// Follow the case the keyword was started in
+		typed := []rune(editor.Value())[editor.Offset()-m.prefix : editor.Offset()]
+		if len(typed) > 0 && unicode.IsLower(typed[0]) {
+			text = strings.ToLower(text)
+		}
+	case candidateFunction:
+		text = syntax.Quote(text, c.dialect) + "("
+	default:
+		text = syntax.Quote(text, c.dialect)
+	}
+	return editor.Complete(m.prefix, text)
+}
+
+// view draws the popup as a block of lines no wider than width
+func (m completionModel) view(width int) []string {
+	itemStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Text().Hex,
+			Dark:  catppuccin.Mocha.Text().Hex,
+		}).
+		Background(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Surface0().Hex,
+			Dark:  catppuccin.Mocha.Surface0().Hex,
+		})
+
+	detailStyle := itemStyle.
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	selectedStyle := itemStyle.
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Base().Hex,
+			Dark:  catppuccin.Mocha.Base().Hex,
+		}).
+		Background(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		})
+
+	// Scroll the list so the selected entry is in view
+	top := max(0, m.selected-popupHeight+1)
+	shown := m.candidates[top:min(len(m.candidates), top+popupHeight)]
+
+	textWidth, detailWidth := 0, 0
+	for _, cand := range shown {
+		textWidth = max(textWidth, ansi.StringWidth(cand.text))
+		detailWidth = max(detailWidth, ansi.StringWidth(cand.detail))
+	}
+	textWidth = min(textWidth, 30)
+	detailWidth = min(detailWidth, max(0, width-textWidth-4), 30)
+
+	lines := make([]string, len(shown))
+	for i, cand := range shown {
+		text := ansi.Truncate(cand.text, textWidth, "…")
+		text += strings.Repeat(" ", textWidth-ansi.StringWidth(text))
+		detail := ansi.Truncate(cand.detail, detailWidth, "…")
+		detail = strings.Repeat(" ", detailWidth-ansi.StringWidth(detail)) + detail
+
+		if top+i == m.selected {
+			lines[i] = selectedStyle.Render(" " + text + "  " + detail + " ")
+		} else {
+			lines[i] = itemStyle.Render(" "+text+" ") + detailStyle.Render(" "+detail+" ")
+		}
+	}
+	return lines
+}
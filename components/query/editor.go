@@ -0,0 +1,680 @@
+package query
+
+import (
+	"fmt"
+	"nectar/styles"
+	"nectar/syntax"
+	"nectar/types"
+	"strings"
+	"unicode"
+
+	catppuccin "github.com/catppuccin/go"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Undo history is capped so long sessions don't grow without bound
+const maxUndo = 500
+
+// Spaces inserted by tab
+const tabWidth = 4
+
+// position is a rune offset within a line
+type position struct {
+	row, col int
+}
+
+func (p position) before(q position) bool {
+	return p.row < q.row || p.row == q.row && p.col < q.col
+}
+
+// snapshot is a copy of the buffer taken before an edit
+type snapshot struct {
+	lines  [][]rune
+	cursor position
+}
+
+// editKind groups consecutive edits so undo reverts a word, not a letter
+type editKind int
+
+const (
+	editNone editKind = iota
+	editInsert
+	editDelete
+	editOther
+)
+
+// EditorModel is a multi-line text editor with line numbers, soft wrap,
+// undo/redo, shift-selection and SQL highlighting for its dialect
+type EditorModel struct {
+	dialect   types.ConnectionType
+	lines     [][]rune
+	cursor    position
+	anchor    position
+	selecting bool
+	// goal is the visual column up/down try to keep, -1 when unset
+	goal     int
+	undo     []snapshot
+	redo     []snapshot
+	lastEdit editKind
+	width    int
+	height   int
+	top      int
+	focused  bool
+}
+
+func NewEditor(dialect types.ConnectionType) EditorModel {
+	return EditorModel{
+		dialect: dialect,
+		lines:   [][]rune{{}},
+		goal:    -1,
+		width:   80,
+		height:  10,
+		focused: true,
+	}
+}
+
+// SetSize sets the area the editor renders into, line numbers included
+func (m EditorModel) SetSize(width, height int) EditorModel {
+	m.width, m.height = max(width, 10), max(height, 1)
+	m.scrollToCursor()
+	return m
+}
+
+func (m EditorModel) Focus() EditorModel {
+	m.focused = true
+	return m
+}
+
+func (m EditorModel) Blur() EditorModel {
+	m.focused = false
+	return m
+}
+
+// Value returns the whole buffer
+func (m EditorModel) Value() string {
+	lines := make([]string, len(m.lines))
+	for i, line := range m.lines {
+		lines[i] = string(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SetValue replaces the buffer, keeping the change undoable
+func (m EditorModel) SetValue(text string) EditorModel {
+	m.pushUndo(editOther)
+	m.selecting = false
+	m.lines = splitLines(text)
+	m.cursor = position{row: len(m.lines) - 1, col: len(m.lines[len(m.lines)-1])}
+	m.scrollToCursor()
+	return m
+}
+
+// Complete replaces the n runes before the cursor with text, as a single
+// undo step
+func (m EditorModel) Complete(n int, text string) EditorModel {
+	from := position{row: m.cursor.row, col: max(0, m.cursor.col-n)}
+	m.anchor, m.selecting = from, from != m.cursor
+	m.insert(text)
+	m.lastEdit = editOther
+	m.scrollToCursor()
+	return m
+}
+
+// CursorPosition returns the column and line the cursor is drawn at in View
+func (m EditorModel) CursorPosition() (x, y int) {
+	return m.gutterWidth() + m.cursor.col%m.textWidth(), m.visualRow(m.cursor) - m.top
+}
+
+// Offset returns the cursor as a rune offset into Value
+func (m EditorModel) Offset() int {
+	return m.offsetOf(m.cursor)
+}
+
+// Selection returns the selected text, if anything is selected
+func (m EditorModel) Selection() (string, bool) {
+	start, end, ok := m.selection()
+	if !ok {
+		return "", false
+	}
+	return string([]rune(m.Value())[m.offsetOf(start):m.offsetOf(end)]), true
+}
+
+// HasSelection reports whether some text is selected
+func (m EditorModel) HasSelection() bool {
+	_, _, ok := m.selection()
+	return ok
+}
+
+// ClearSelection drops the selection, leaving the cursor where it is
+func (m EditorModel) ClearSelection() EditorModel {
+	m.selecting = false
+	return m
+}
+
+func (m EditorModel) offsetOf(p position) int {
+	offset := 0
+	for _, line := range m.lines[:p.row] {
+		offset += len(line) + 1
+	}
+	return offset + p.col
+}
+
+func (m EditorModel) selection() (start, end position, ok bool) {
+	if !m.selecting || m.anchor == m.cursor {
+		return position{}, position{}, false
+	}
+	if m.anchor.before(m.cursor) {
+		return m.anchor, m.cursor, true
+	}
+	return m.cursor, m.anchor, true
+}
+
+func (m EditorModel) Update(msg tea.Msg) (EditorModel, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok || !m.focused {
+		return m, nil
+	}
+
+	switch keyMsg.String() {
+	case "ctrl+z":
+		m.restore(&m.undo, &m.redo)
+	case "ctrl+y":
+		m.restore(&m.redo, &m.undo)
+	case "ctrl+a":
+		m.anchor, m.selecting = position{}, true
+		m.cursor = position{row: len(m.lines) - 1, col: len(m.lines[len(m.lines)-1])}
+	case "esc":
+		m.selecting = false
+	case "enter":
+		m.insert("\n" + m.indentOf(m.cursor.row))
+	case "tab":
+		m.insert(strings.Repeat(" ", tabWidth-m.cursor.col%tabWidth))
+	case "backspace":
+		m.deleteBackward(false)
+	case "ctrl+w", "alt+backspace":
+		m.deleteBackward(true)
+	case "delete":
+		m.deleteForward()
+	default:
+		if keyMsg.Type == tea.KeyRunes || keyMsg.Type == tea.KeySpace {
+			m.insert(string(keyMsg.Runes))
+			break
+		}
+		if !m.move(keyMsg.String()) {
+			return m, nil
+		}
+	}
+
+	m.scrollToCursor()
+	return m, nil
+}
+
+// move handles cursor movement, extending the selection when shift is held.
+// It reports whether the key was a movement key.
+func (m *EditorModel) move(key string) bool {
+	extend := strings.Contains(key, "shift+")
+	key = strings.Replace(key, "shift+", "", 1)
+
+	from := m.cursor
+	keepGoal := false
+	switch key {
+	case "left":
+		m.cursor = m.prevPosition(m.cursor)
+	case "right":
+		m.cursor = m.nextPosition(m.cursor)
+	case "up":
+		m.cursor, keepGoal = m.verticalMove(-1), true
+	case "down":
+		m.cursor, keepGoal = m.verticalMove(1), true
+	case "pgup":
+		for range m.height {
+			m.cursor = m.verticalMove(-1)
+		}
+		keepGoal = true
+	case "pgdown":
+		for range m.height {
+			m.cursor = m.verticalMove(1)
+		}
+		keepGoal = true
+	case "home":
+		// Toggle between the first non-blank character and column zero
+		indent := len(m.indentOf(m.cursor.row))
+		if m.cursor.col == indent {
+			indent = 0
+		}
+		m.cursor.col = indent
+	case "end":
+		m.cursor.col = len(m.lines[m.cursor.row])
+	case "ctrl+left", "alt+left", "alt+b":
+		m.cursor = m.wordLeft(m.cursor)
+	case "ctrl+right", "alt+right", "alt+f":
+		m.cursor = m.wordRight(m.cursor)
+	case "ctrl+home":
+		m.cursor = position{}
+	case "ctrl+end":
+		m.cursor = position{row: len(m.lines) - 1, col: len(m.lines[len(m.lines)-1])}
+	default:
+		return false
+	}
+
+	if !keepGoal {
+		m.goal = -1
+	}
+	if extend {
+		if !m.selecting {
+			m.anchor, m.selecting = from, true
+		}
+	} else {
+		m.selecting = false
+	}
+	m.lastEdit = editNone
+	return true
+}
+
+// insert replaces the selection, if any, with text at the cursor
+func (m *EditorModel) insert(text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	kind := editInsert
+	if strings.ContainsAny(text, "\n ") || len([]rune(text)) > 1 {
+		// Word boundaries and pastes get their own undo step
+		kind = editOther
+	}
+	m.pushUndo(kind)
+	m.deleteSelection()
+
+	inserted := splitLines(text)
+	line := m.lines[m.cursor.row]
+	head := append([]rune{}, line[:m.cursor.col]...)
+	tail := append([]rune{}, line[m.cursor.col:]...)
+
+	last := len(inserted) - 1
+	rows := make([][]rune, len(inserted))
+	for i, part := range inserted {
+		rows[i] = append([]rune{}, part...)
+	}
+	rows[0] = append(head, rows[0]...)
+	col := len(rows[last])
+	rows[last] = append(rows[last], tail...)
+
+	m.lines = append(m.lines[:m.cursor.row], append(rows, m.lines[m.cursor.row+1:]...)...)
+	m.cursor = position{row: m.cursor.row + last, col: col}
+	m.goal = -1
+}
+
+func (m *EditorModel) deleteBackward(word bool) {
+	m.pushUndo(editDelete)
+	if m.deleteSelection() {
+		return
+	}
+	from := m.prevPosition(m.cursor)
+	if word {
+		from = m.wordLeft(m.cursor)
+	}
+	m.deleteRange(from, m.cursor)
+}
+
+func (m *EditorModel) deleteForward() {
+	m.pushUndo(editDelete)
+	if m.deleteSelection() {
+		return
+	}
+	m.deleteRange(m.cursor, m.nextPosition(m.cursor))
+}
+
+// deleteSelection removes the selected text and reports whether there was any
+func (m *EditorModel) deleteSelection() bool {
+	start, end, ok := m.selection()
+	m.selecting = false
+	if !ok {
+		return false
+	}
+	m.deleteRange(start, end)
+	return true
+}
+
+func (m *EditorModel) deleteRange(start, end position) {
+	if !start.before(end) {
+		return
+	}
+	joined := append(append([]rune{}, m.lines[start.row][:start.col]...), m.lines[end.row][end.col:]...)
+	m.lines = append(m.lines[:start.row], append([][]rune{joined}, m.lines[end.row+1:]...)...)
+	m.cursor = start
+	m.goal = -1
+}
+
+// pushUndo records the buffer before an edit, folding runs of the same kind
+// of edit into one step
+func (m *EditorModel) pushUndo(kind editKind) {
+	m.redo = nil
+	if kind != editOther && kind == m.lastEdit && !m.selecting {
+		return
+	}
+	m.lastEdit = kind
+	m.undo = append(m.undo, m.snapshot())
+	if len(m.undo) > maxUndo {
+		m.undo = m.undo[len(m.undo)-maxUndo:]
+	}
+}
+
+// restore pops a snapshot from one history onto the buffer, saving the
+// current state on the other
+func (m *EditorModel) restore(from, to *[]snapshot) {
+	if len(*from) == 0 {
+		return
+	}
+	state := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, m.snapshot())
+
+	m.lines, m.cursor = state.lines, state.cursor
+	m.selecting, m.lastEdit, m.goal = false, editNone, -1
+}
+
+func (m EditorModel) snapshot() snapshot {
+	lines := make([][]rune, len(m.lines))
+	for i, line := range m.lines {
+		lines[i] = append([]rune{}, line...)
+	}
+	return snapshot{lines: lines, cursor: m.cursor}
+}
+
+func (m EditorModel) indentOf(row int) string {
+	line := m.lines[row]
+	n := 0
+	for n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+		n++
+	}
+	return string(line[:n])
+}
+
+func (m EditorModel) prevPosition(p position) position {
+	if p.col > 0 {
+		return position{p.row, p.col - 1}
+	}
+	if p.row > 0 {
+		return position{p.row - 1, len(m.lines[p.row-1])}
+	}
+	return p
+}
+
+func (m EditorModel) nextPosition(p position) position {
+	if p.col < len(m.lines[p.row]) {
+		return position{p.row, p.col + 1}
+	}
+	if p.row < len(m.lines)-1 {
+		return position{p.row + 1, 0}
+	}
+	return p
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordLeft skips back over spaces and punctuation, then over a word
+func (m EditorModel) wordLeft(p position) position {
+	if p.col == 0 {
+		return m.prevPosition(p)
+	}
+	line := m.lines[p.row]
+	col := p.col
+	for col > 0 && !isWordRune(line[col-1]) {
+		col--
+	}
+	for col > 0 && isWordRune(line[col-1]) {
+		col--
+	}
+	return position{p.row, col}
+}
+
+func (m EditorModel) wordRight(p position) position {
+	line := m.lines[p.row]
+	if p.col == len(line) {
+		return m.nextPosition(p)
+	}
+	col := p.col
+	for col < len(line) && !isWordRune(line[col]) {
+		col++
+	}
+	for col < len(line) && isWordRune(line[col]) {
+		col++
+	}
+	return position{p.row, col}
+}
+
+// Soft wrap: each line is cut into chunks of textWidth runes. A line always
+// has room for the cursor after its last rune, so a line that exactly fills
+// its last chunk gets an extra, empty one.
+
+func (m EditorModel) gutterWidth() int {
+	return max(3, len(fmt.Sprint(len(m.lines)))) + 1
+}
+
+func (m EditorModel) textWidth() int {
+	return max(1, m.width-m.gutterWidth()-1)
+}
+
+func (m EditorModel) chunks(row int) int {
+	return len(m.lines[row])/m.textWidth() + 1
+}
+
+// visualRow returns the wrapped row the position is drawn on
+func (m EditorModel) visualRow(p position) int {
+	visual := 0
+	for row := range p.row {
+		visual += m.chunks(row)
+	}
+	return visual + p.col/m.textWidth()
+}
+
+// verticalMove moves the cursor one wrapped row up or down, keeping the
+// column it started from
+func (m *EditorModel) verticalMove(dir int) position {
+	width := m.textWidth()
+	p := m.cursor
+	if m.goal < 0 {
+		m.goal = p.col % width
+	}
+	chunk := p.col / width
+
+	switch {
+	case dir < 0 && chunk > 0:
+		chunk--
+	case dir < 0 && p.row > 0:
+		p.row--
+		chunk = m.chunks(p.row) - 1
+	case dir > 0 && chunk < m.chunks(p.row)-1:
+		chunk++
+	case dir > 0 && p.row < len(m.lines)-1:
+		p.row++
+		chunk = 0
+	default:
+		return p
+	}
+	p.col = min(chunk*width+m.goal, len(m.lines[p.row]))
+	return p
+}
+
+// scrollToCursor keeps the cursor's row within the visible window
+func (m *EditorModel) scrollToCursor() {
+	row := m.visualRow(m.cursor)
+	if row < m.top {
+		m.top = row
+	}
+	if row >= m.top+m.height {
+		m.top = row - m.height + 1
+	}
+}
+
+func (m EditorModel) View() string {
+	gutterStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay0().Hex,
+			Dark:  catppuccin.Mocha.Overlay0().Hex,
+		})
+
+	currentStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		})
+
+	selectedBackground := lipgloss.AdaptiveColor{
+		Light: catppuccin.Latte.Surface2().Hex,
+		Dark:  catppuccin.Mocha.Surface2().Hex,
+	}
+
+	cursorStyle := styles.SQLText.Reverse(true)
+
+	const (
+		styleText = iota
+		styleCursor
+		styleSelected
+	)
+
+	// Styles combine a token's colour with the cursor or selection, built
+	// once per view for each combination seen
+	type runKey struct {
+		mark
+		state int
+	}
+	built := map[runKey]lipgloss.Style{}
+	styleFor := func(key runKey) lipgloss.Style {
+		if style, ok := built[key]; ok {
+			return style
+		}
+		style := styles.SQLToken(key.kind)
+		if key.unterminated {
+			style = style.Underline(true)
+		}
+		switch key.state {
+		case styleCursor:
+			style = style.Reverse(true)
+		case styleSelected:
+			style = style.Background(selectedBackground)
+		}
+		built[key] = style
+		return style
+	}
+	marks := m.highlight()
+
+	start, end, selected := m.selection()
+	inSelection := func(p position) bool {
+		return selected && !p.before(start) && p.before(end)
+	}
+
+	gutter, width := m.gutterWidth(), m.textWidth()
+	var rows []string
+	visual := 0
+	for row, line := range m.lines {
+		chunks := m.chunks(row)
+		if visual+chunks <= m.top {
+			visual += chunks
+			continue
+		}
+
+		for chunk := range chunks {
+			if visual >= m.top+m.height {
+				break
+			}
+			if visual < m.top {
+				visual++
+				continue
+			}
+
+			number := ""
+			if chunk == 0 {
+				number = fmt.Sprint(row + 1)
+			}
+			number = fmt.Sprintf("%*s ", gutter-1, number)
+			if row == m.cursor.row {
+				number = currentStyle.Render(number)
+			} else {
+				number = gutterStyle.Render(number)
+			}
+
+			// Group runes into runs that share a style
+			var text strings.Builder
+			from, to := chunk*width, min((chunk+1)*width, len(line))
+			runStart, runStyle, styled := from, runKey{}, false
+			flush := func(until int) {
+				if until > runStart && styled {
+					text.WriteString(styleFor(runStyle).Render(string(line[runStart:until])))
+				}
+				runStart = until
+			}
+			for col := from; col < to; col++ {
+				p := position{row, col}
+				style := runKey{mark: marks[row][col], state: styleText}
+				switch {
+				case m.focused && p == m.cursor:
+					style.state = styleCursor
+				case inSelection(p):
+					style.state = styleSelected
+				}
+				if !styled || style != runStyle {
+					flush(col)
+					runStyle, styled = style, true
+				}
+			}
+			flush(to)
+			if m.focused && m.cursor.row == row && m.cursor.col == to && m.cursor.col/width == chunk {
+				text.WriteString(cursorStyle.Render(" "))
+			}
+
+			rows = append(rows, number+text.String())
+			visual++
+		}
+		if visual >= m.top+m.height {
+			break
+		}
+	}
+
+	for len(rows) < m.height {
+		rows = append(rows, gutterStyle.Render(fmt.Sprintf("%*s ", gutter-1, "~")))
+	}
+	return strings.Join(rows, "\n")
+}
+
+// mark is how a rune is highlighted: the kind of token it is part of, and
+// whether that token is left open
+type mark struct {
+	kind         syntax.TokenKind
+	unterminated bool
+}
+
+// highlight tokenizes the buffer and marks every rune with its token, line
+// by line. The whole buffer is read since a quote or comment opened on one
+// line carries on to the next.
+func (m EditorModel) highlight() [][]mark {
+	tokens := syntax.Tokenize(m.Value(), m.dialect)
+	marks := make([][]mark, len(m.lines))
+	offset, t := 0, 0
+	for row, line := range m.lines {
+		marks[row] = make([]mark, len(line))
+		for col := range line {
+			for t < len(tokens) && tokens[t].End <= offset+col {
+				t++
+			}
+			if t < len(tokens) {
+				marks[row][col] = mark{tokens[t].Kind, tokens[t].Unterminated}
+			}
+		}
+		// Skip past the line break
+		offset += len(line) + 1
+	}
+	return marks
+}
+
+// splitLines breaks text into rune lines, always returning at least one
+func splitLines(text string) [][]rune {
+	parts := strings.Split(text, "\n")
+	lines := make([][]rune, len(parts))
+	for i, part := range parts {
+		lines[i] = []rune(part)
+	}
+	return lines
+}
@@ -0,0 +1,247 @@
+package query
+
+import (
+	"fmt"
+	"nectar/store"
+	"slices"
+	"strings"
+	"time"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Most past queries the history search lists at once
+const historyHeight = 10
+
+// historyLoadedMsg carries the query history, newest entry first
+type historyLoadedMsg struct {
+	entries []store.HistoryEntry
+	err     error
+}
+
+// historyModel is the ctrl+r search over past queries. It lists the
+// session's connection by default; tab widens it to every connection.
+type historyModel struct {
+	visible    bool
+	loading    bool
+	all        bool
+	connection string
+	entries    []store.HistoryEntry
+	matches    []int
+	selected   int
+	input      textinput.Model
+	err        error
+}
+
+func newHistoryModel(connection string) historyModel {
+	input := textinput.New()
+	input.Prompt = "⌕ "
+	input.Placeholder = "search past queries"
+	input.CharLimit = 256
+	return historyModel{connection: connection, input: input}
+}
+
+// open shows the search and reads the history in the background
+func (m historyModel) open(history *store.History) (historyModel, tea.Cmd) {
+	m.visible, m.loading, m.err = true, true, nil
+	m.selected = 0
+	m.input.SetValue("")
+	return m, tea.Batch(m.input.Focus(), func() tea.Msg {
+		if history == nil {
+			return historyLoadedMsg{}
+		}
+		entries, err := history.Load()
+		slices.Reverse(entries)
+		return historyLoadedMsg{entries: entries, err: err}
+	})
+}
+
+func (m historyModel) close() historyModel {
+	m.visible = false
+	m.input.Blur()
+	return m
+}
+
+// loaded takes the entries read for the search
+func (m historyModel) loaded(msg historyLoadedMsg) historyModel {
+	m.loading, m.err = false, msg.err
+	m.entries = msg.entries
+	return m.search()
+}
+
+// search lists the entries matching what is typed, best first and newest
+// first among equals. A query run many times is listed once.
+func (m historyModel) search() historyModel {
+	type scored struct {
+		index int
+		score int
+	}
+	pattern := m.input.Value()
+	seen := map[string]bool{}
+	var matches []scored
+	for i, entry := range m.entries {
+		if !m.all && entry.Connection != m.connection || seen[entry.Query] {
+			continue
+		}
+		if score, ok := fuzzyMatch(entry.Query, pattern); ok {
+			seen[entry.Query] = true
+			matches = append(matches, scored{i, score})
+		}
+	}
+	if pattern != "" {
+		slices.SortStableFunc(matches, func(a, b scored) int { return b.score - a.score })
+	}
+
+	m.matches = m.matches[:0]
+	for _, match := range matches {
+		m.matches = append(m.matches, match.index)
+	}
+	m.selected = max(0, min(m.selected, len(m.matches)-1))
+	return m
+}
+
+// choice returns the query picked from the list
+func (m historyModel) choice() (string, bool) {
+	if m.selected >= len(m.matches) {
+		return "", false
+	}
+	return m.entries[m.matches[m.selected]].Query, true
+}
+
+func (m historyModel) Update(msg tea.KeyMsg) (historyModel, tea.Cmd) {
+	switch msg.String() {
+	case "up", "ctrl+p":
+		if m.selected > 0 {
+			m.selected--
+		}
+		return m, nil
+	case "down", "ctrl+n", "ctrl+r":
+		if m.selected < len(m.matches)-1 {
+			m.selected++
+		}
+		return m, nil
+	case "tab":
+		m.all = !m.all
+		m.selected = 0
+		return m.search(), nil
+	}
+
+	before := m.input.Value()
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if m.input.Value() != before {
+		m.selected = 0
+		m = m.search()
+	}
+	return m, cmd
+}
+
+// view draws the search as a framed block of lines no wider than width
+func (m historyModel) view(width int) []string {
+	borderStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Padding(0, 1)
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Red().Hex,
+			Dark:  catppuccin.Mocha.Red().Hex,
+		})
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Base().Hex,
+			Dark:  catppuccin.Mocha.Base().Hex,
+		}).
+		Background(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		})
+
+	inner := max(20, width-4)
+	m.input.Width = inner - 4
+
+	scope, other := "this connection", "all"
+	if m.all {
+		scope, other = "all connections", "this connection"
+	}
+	hint := fmt.Sprintf("%d in %s · tab: %s · enter: insert · esc: close", len(m.matches), scope, other)
+	lines := []string{
+		m.input.View(),
+		mutedStyle.Render(ansi.Truncate(hint, inner, "…")),
+	}
+
+	switch {
+	case m.loading:
+		lines = append(lines, mutedStyle.Render("Reading history…"))
+	case m.err != nil:
+		lines = append(lines, errorStyle.Render(ansi.Truncate("✗ "+m.err.Error(), inner, "…")))
+	case len(m.matches) == 0:
+		lines = append(lines, mutedStyle.Render("No past queries"))
+	}
+
+	top := max(0, m.selected-historyHeight+1)
+	for i := top; i < min(len(m.matches), top+historyHeight); i++ {
+		entry := m.entries[m.matches[i]]
+		detail := historyDetail(entry, m.all)
+		text := strings.Join(strings.Fields(entry.Query), " ")
+		room := max(0, inner-ansi.StringWidth(detail)-2)
+		text = ansi.Truncate(text, room, "…")
+		text += strings.Repeat(" ", room-ansi.StringWidth(text))
+
+		if i == m.selected {
+			lines = append(lines, selectedStyle.Render(text+"  "+detail))
+		} else if entry.Error != "" {
+			lines = append(lines, text+"  "+errorStyle.Render(detail))
+		} else {
+			lines = append(lines, text+"  "+mutedStyle.Render(detail))
+		}
+	}
+
+	return strings.Split(borderStyle.Width(inner+2).Render(strings.Join(lines, "\n")), "\n")
+}
+
+// historyDetail sums up how a past query went: when, for how long, and
+// with how many rows or what error
+func historyDetail(entry store.HistoryEntry, withConnection bool) string {
+	outcome := plural(int(entry.Rows), "row")
+	if entry.Error != "" {
+		outcome = "✗ failed"
+	}
+	detail := ago(entry.Time) + " · " + entry.Duration.Round(time.Millisecond).String() + " · " + outcome
+	if withConnection {
+		detail = entry.Connection + " · " + detail
+	}
+	return detail
+}
+
+// ago says roughly how long ago a time was
+func ago(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	default:
+		return t.Format("2006-01-02")
+	}
+}
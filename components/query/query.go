@@ -0,0 +1,614 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"nectar/components/shared"
+	"nectar/driver"
+	"nectar/keymap"
+	"nectar/store"
+	"nectar/syntax"
+	"nectar/types"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Longest a schema refresh may take
+const refreshTimeout = 30 * time.Second
+
+// queryDoneMsg carries the outcome of a run back to the model
+type queryDoneMsg struct {
+	id         int
+	result     *driver.Result
+	err        error
+	statements int
+	duration   time.Duration
+	// query is the statement the result came from
+	query string
+}
+
+// schemaRefreshedMsg carries a freshly introspected schema
+type schemaRefreshedMsg struct {
+	schema *driver.Schema
+	err    error
+}
+
+// QueryModel is an SQL editor over an open session with the result of the
+// last run shown below it. F6 moves focus between the editor and the
+// result grid.
+type QueryModel struct {
+	connection types.Connection
+	driver     driver.Driver
+	schema     *driver.Schema
+	editor     EditorModel
+	grid       shared.GridModel
+	width      int
+	height     int
+
+	catalog    catalog
+	completion completionModel
+	refreshing bool
+
+	history *store.History
+	recall  historyModel
+	export  shared.ExportModel
+
+	running    bool
+	runID      int
+	cancel     context.CancelFunc
+	busy       shared.BusyModel
+	result     *driver.Result
+	err        error
+	statements int
+	duration   time.Duration
+	// resultQuery is the statement behind the result, kept when a lone
+	// statement that only reads made it, so exporting can run it again
+	resultQuery string
+}
+
+// NewQuery opens the editor on a session. Statements run are recorded in
+// history, which may be nil to keep none.
+func NewQuery(conn types.Connection, d driver.Driver, schema *driver.Schema, history *store.History) QueryModel {
+	return QueryModel{
+		connection: conn,
+		driver:     d,
+		schema:     schema,
+		editor:     NewEditor(conn.Type),
+		grid:       shared.NewGrid(),
+		catalog:    newCatalog(conn.Type, schema),
+		history:    history,
+		recall:     newHistoryModel(conn.Name),
+		export:     shared.NewExport(),
+		busy:       shared.NewBusy(),
+	}
+}
+
+// SetSize sets the area the editor and result share
+func (m QueryModel) SetSize(width, height int) QueryModel {
+	m.width, m.height = width, height
+	m.editor = m.editor.SetSize(width, m.editorHeight())
+	m.grid = m.grid.SetSize(width, m.resultHeight())
+	m.export = m.export.SetSize(width, height)
+	return m
+}
+
+// The editor takes a little under half the height, the result the rest
+func (m QueryModel) editorHeight() int {
+	return max(3, (m.height-1)*2/5)
+}
+
+// The result fills what is left below the editor and the rule
+func (m QueryModel) resultHeight() int {
+	return max(0, m.height-m.editorHeight()-1)
+}
+
+// Capturing reports whether esc is handled here rather than leaving the
+// screen: it closes the export dialog, the history search or the completion
+// popup, clears a selection, cancels a running query or returns focus from
+// the result grid to the editor
+func (m QueryModel) Capturing() bool {
+	return m.running || m.export.Visible() || m.recall.visible || m.completion.visible || m.editor.HasSelection() || m.grid.Focused()
+}
+
+// Bindings lists the keys the editor answers to as it stands, besides the
+// typing and moving about that any editor does
+func (m QueryModel) Bindings() []key.Binding {
+	switch {
+	case m.export.Visible():
+		return []key.Binding{keymap.Field.Done, keymap.Field.Cancel}
+	case m.recall.visible:
+		insert := keymap.Field.Done
+		insert.SetHelp("↵", "insert")
+		return []key.Binding{insert, keymap.Query.History, keymap.Field.Cancel}
+	case m.running:
+		return []key.Binding{keymap.Query.Cancel}
+	}
+
+	focus := keymap.When(keymap.Query.Focus, m.grid.Focused() || !m.grid.Empty())
+	export := keymap.When(keymap.Query.Export, m.hasRows())
+	if m.grid.Focused() {
+		editor := keymap.Query.Cancel
+		editor.SetHelp("esc", "editor")
+		return []key.Binding{keymap.Query.Run, keymap.Query.RunAll, focus, export, editor}
+	}
+	return []key.Binding{
+		keymap.Query.Run,
+		keymap.Query.RunAll,
+		focus,
+		export,
+		keymap.Query.Complete,
+		keymap.Query.History,
+		keymap.When(keymap.Query.Refresh, !m.refreshing),
+		keymap.Query.Select,
+		keymap.Query.Undo,
+	}
+}
+
+// Close cancels any query or export still running
+func (m QueryModel) Close() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.export.Close()
+}
+
+func (m QueryModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m QueryModel) Update(msg tea.Msg) (QueryModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case queryDoneMsg:
+		// Results of a cancelled run are dropped
+		if msg.id != m.runID {
+			return m, nil
+		}
+		m.running, m.cancel = false, nil
+		m.busy = m.busy.Stop()
+		m.result, m.err = msg.result, msg.err
+		m.statements, m.duration = msg.statements, msg.duration
+		m.resultQuery = ""
+		if m.hasRows() {
+			if msg.statements == 1 && syntax.ReadOnly(msg.query) {
+				m.resultQuery = msg.query
+			}
+			m.grid = m.grid.SetResult(m.result)
+		} else {
+			m.grid = m.grid.SetResult(nil)
+			m = m.focusEditor()
+		}
+		return m, nil
+	case schemaRefreshedMsg:
+		m.refreshing = false
+		if msg.err != nil {
+			m.err = fmt.Errorf("refreshing schema: %w", msg.err)
+			return m, nil
+		}
+		// The session shares the schema, so it sees the refresh too
+		if m.schema == nil {
+			m.schema = msg.schema
+		} else {
+			*m.schema = *msg.schema
+		}
+		m.catalog = newCatalog(m.connection.Type, m.schema)
+		return m, nil
+	case historyLoadedMsg:
+		m.recall = m.recall.loaded(msg)
+		return m, nil
+	case spinner.TickMsg:
+		// Each spinner only takes its own ticks
+		var busyCmd, exportCmd tea.Cmd
+		m.busy, busyCmd = m.busy.Update(msg)
+		m.export, exportCmd = m.export.Update(msg)
+		return m, tea.Batch(busyCmd, exportCmd)
+	case tea.KeyMsg:
+		if m.export.Visible() {
+			var cmd tea.Cmd
+			m.export, cmd = m.export.Update(msg)
+			return m, cmd
+		}
+		if m.recall.visible {
+			return m.updateRecall(msg)
+		}
+		if !m.grid.Focused() {
+			if handled, next := m.updateCompletion(msg); handled {
+				return next, nil
+			}
+		}
+
+		switch {
+		case key.Matches(msg, keymap.Query.Refresh):
+			return m.refreshSchema()
+		case key.Matches(msg, keymap.Query.History):
+			m.completion = completionModel{}
+			var cmd tea.Cmd
+			m.recall, cmd = m.recall.open(m.history)
+			return m, cmd
+		case key.Matches(msg, keymap.Query.Run):
+			return m.runCurrent()
+		case key.Matches(msg, keymap.Query.Export):
+			if m.hasRows() && !m.running {
+				m.completion = completionModel{}
+				// ReadOnly only goes by keywords, so a SELECT calling nextval()
+				// or a function that writes passes it. Only a SELECT ... FROM
+				// calling nothing but read-only built-ins is run again without
+				// asking; for anything else the rows already read are exported
+				// unless the user chooses to run the statement again.
+				switch {
+				case m.resultQuery != "" && syntax.PlainSelect(m.resultQuery, m.connection.Type):
+					m.export = m.export.Open(m.driver, m.connection.Type, m.resultQuery, nil, "result", "")
+				case m.resultQuery != "":
+					m.export = m.export.OpenResult(m.result, m.connection.Type, "result", "").
+						OfferRerun(m.driver, m.resultQuery, nil)
+				default:
+					m.export = m.export.OpenResult(m.result, m.connection.Type, "result", "")
+				}
+			}
+			return m, nil
+		case key.Matches(msg, keymap.Query.RunAll):
+			return m.RunAll()
+		case key.Matches(msg, keymap.Query.Focus):
+			if m.grid.Focused() {
+				return m.focusEditor(), nil
+			}
+			if !m.grid.Empty() {
+				m.grid = m.grid.Focus()
+				m.editor = m.editor.Blur()
+			}
+			return m, nil
+		case key.Matches(msg, keymap.Query.Cancel):
+			if m.running {
+				m.cancelRun()
+				return m, nil
+			}
+			if m.grid.Focused() {
+				return m.focusEditor(), nil
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	if m.export.Visible() {
+		m.export, cmd = m.export.Update(msg)
+		return m, cmd
+	}
+	if m.grid.Focused() {
+		m.grid, cmd = m.grid.Update(msg)
+		return m, cmd
+	}
+	m.editor, cmd = m.editor.Update(msg)
+
+	// Typing keeps the popup in step; anything else closes it
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case keyMsg.Type == tea.KeyRunes, keyMsg.Type == tea.KeyBackspace && m.completion.visible:
+			m.completion = m.completion.update(m.catalog, m.editor, false)
+		default:
+			m.completion = completionModel{}
+		}
+	}
+	return m, cmd
+}
+
+// updateCompletion handles the keys that open and drive the completion
+// popup, reporting whether the key was used
+func (m QueryModel) updateCompletion(msg tea.KeyMsg) (bool, QueryModel) {
+	if key.Matches(msg, keymap.Query.Complete) {
+		m.completion = m.completion.update(m.catalog, m.editor, true)
+		return true, m
+	}
+	if !m.completion.visible {
+		return false, m
+	}
+
+	switch msg.String() {
+	case "up", "ctrl+p":
+		m.completion = m.completion.move(-1)
+	case "down", "ctrl+n":
+		m.completion = m.completion.move(1)
+	case "tab", "enter":
+		m.editor = m.completion.accept(m.catalog, m.editor)
+		m.completion = completionModel{}
+	case "esc":
+		m.completion = completionModel{}
+	default:
+		return false, m
+	}
+	return true, m
+}
+
+// updateRecall drives the history search while it is open. Enter puts the
+// chosen query into the editor at the cursor.
+func (m QueryModel) updateRecall(msg tea.KeyMsg) (QueryModel, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keymap.Field.Cancel):
+		m.recall = m.recall.close()
+		return m, nil
+	case key.Matches(msg, keymap.Field.Done):
+		query, ok := m.recall.choice()
+		m.recall = m.recall.close()
+		if ok {
+			m = m.focusEditor()
+			m.editor = m.editor.Complete(0, query)
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.recall, cmd = m.recall.Update(msg)
+	return m, cmd
+}
+
+// refreshSchema introspects the database again in the background, for
+// tables and columns created since the session was opened
+func (m QueryModel) refreshSchema() (QueryModel, tea.Cmd) {
+	if m.refreshing {
+		return m, nil
+	}
+	m.refreshing = true
+	d := m.driver
+	return m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		defer cancel()
+		schema, err := d.Introspect(ctx)
+		return schemaRefreshedMsg{schema: schema, err: err}
+	}
+}
+
+func (m QueryModel) focusEditor() QueryModel {
+	m.grid = m.grid.Blur()
+	m.editor = m.editor.Focus()
+	return m
+}
+
+// Run the selection, or else the statement under the cursor
+func (m QueryModel) runCurrent() (QueryModel, tea.Cmd) {
+	if text, ok := m.editor.Selection(); ok {
+		return m.run(syntax.Split(text, m.connection.Type))
+	}
+	stmt, ok := syntax.StatementAt(m.editor.Value(), m.editor.Offset(), m.connection.Type)
+	if !ok {
+		return m, nil
+	}
+	return m.run([]syntax.Statement{stmt})
+}
+
+// SetScript replaces what is in the editor
+func (m QueryModel) SetScript(script string) QueryModel {
+	m.editor = m.editor.SetValue(script)
+	return m
+}
+
+// RunAll runs every statement in the editor, in order
+func (m QueryModel) RunAll() (QueryModel, tea.Cmd) {
+	return m.run(syntax.Split(m.editor.Value(), m.connection.Type))
+}
+
+// run executes statements one after another in the background, stopping at
+// the first error. The result of the last one is kept.
+func (m QueryModel) run(statements []syntax.Statement) (QueryModel, tea.Cmd) {
+	if m.running || len(statements) == 0 {
+		return m, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.runID++
+	m.running, m.cancel = true, cancel
+	m.err = nil
+
+	label := "Running statement"
+	if len(statements) > 1 {
+		label = fmt.Sprintf("Running %d statements", len(statements))
+	}
+	fetched := &atomic.Int64{}
+	var tick tea.Cmd
+	m.busy, tick = m.busy.Start(label, fetched.Load)
+
+	id, d := m.runID, m.driver
+	conn, history := m.connection, m.history
+	return m, tea.Batch(tick, func() tea.Msg {
+		defer cancel()
+		started := time.Now()
+
+		var result *driver.Result
+		var err error
+		var query string
+		var entries []store.HistoryEntry
+		for i, stmt := range statements {
+			query = stmt.Text
+			begun := time.Now()
+			if syntax.ReturnsRows(stmt.Text) {
+				result, err = collect(ctx, d, stmt.Text, fetched)
+			} else {
+				result, err = d.Exec(ctx, stmt.Text)
+			}
+			entries = append(entries, historyEntry(stmt.Text, begun, result, err))
+			if err != nil {
+				if len(statements) > 1 {
+					err = fmt.Errorf("statement %d: %w", i+1, err)
+				}
+				break
+			}
+		}
+		// The history is a convenience, so failing to write it doesn't
+		// fail the run
+		if history != nil {
+			_ = history.Record(conn, entries...)
+		}
+		return queryDoneMsg{id: id, result: result, err: err, statements: len(statements), duration: time.Since(started), query: query}
+	})
+}
+
+// collect runs a query and reads its full result, counting the rows in
+// fetched as they arrive
+func collect(ctx context.Context, d driver.Driver, query string, fetched *atomic.Int64) (*driver.Result, error) {
+	fetched.Store(0)
+	result := &driver.Result{}
+	err := d.Stream(ctx, query, func(columns []string) error {
+		result.Columns = columns
+		return nil
+	}, func(row []any) error {
+		result.Rows = append(result.Rows, row)
+		fetched.Add(1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.RowsAffected = int64(len(result.Rows))
+	return result, nil
+}
+
+// hasRows reports whether the last run left a result set to show
+func (m QueryModel) hasRows() bool {
+	return m.result != nil && m.result.Columns != nil
+}
+
+// historyEntry describes a statement that was run, for the history
+func historyEntry(query string, started time.Time, result *driver.Result, err error) store.HistoryEntry {
+	entry := store.HistoryEntry{
+		Query:    strings.TrimSpace(query),
+		Time:     started,
+		Duration: time.Since(started),
+	}
+	switch {
+	case err != nil:
+		entry.Error = err.Error()
+	case result != nil && result.Columns != nil:
+		entry.Rows = int64(len(result.Rows))
+	case result != nil:
+		entry.Rows = result.RowsAffected
+	}
+	return entry
+}
+
+func (m *QueryModel) cancelRun() {
+	m.cancel()
+	m.runID++
+	m.running, m.cancel = false, nil
+	m.busy = m.busy.Stop()
+	m.err = context.Canceled
+}
+
+func (m QueryModel) View() string {
+	if m.export.Visible() {
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, m.export.View())
+	}
+
+	ruleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Surface2().Hex,
+			Dark:  catppuccin.Mocha.Surface2().Hex,
+		})
+
+	summary := m.summary()
+	rule := ruleStyle.Render(strings.Repeat("─", max(0, m.width-lipgloss.Width(summary)-3)))
+	resultHeight := m.resultHeight()
+
+	view := lipgloss.JoinVertical(
+		lipgloss.Left,
+		m.editor.View(),
+		ruleStyle.Render("── ")+summary+" "+rule,
+		lipgloss.NewStyle().Height(resultHeight).MaxHeight(resultHeight).Render(m.renderResult()),
+	)
+	if m.recall.visible {
+		// The search sits over the top of the editor, across its width
+		return shared.Overlay(view, m.recall.view(m.width), 0, 0)
+	}
+	if !m.completion.visible {
+		return view
+	}
+
+	// The popup opens under the word being completed, or above it when
+	// there is no room below
+	popup := m.completion.view(m.width)
+	x, y := m.editor.CursorPosition()
+	x = max(0, min(x-m.completion.prefix, m.width-lipgloss.Width(popup[0])))
+	y++
+	if y+len(popup) > m.height && y-1-len(popup) >= 0 {
+		y -= len(popup) + 1
+	}
+	return shared.Overlay(view, popup, x, y)
+}
+
+// summary describes the state of the last run in the rule above the result
+func (m QueryModel) summary() string {
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Red().Hex,
+			Dark:  catppuccin.Mocha.Red().Hex,
+		})
+
+	successStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Green().Hex,
+			Dark:  catppuccin.Mocha.Green().Hex,
+		})
+
+	switch {
+	case m.running:
+		return mutedStyle.Render("Running… (esc to cancel)")
+	case m.refreshing:
+		return mutedStyle.Render("Refreshing schema…")
+	case m.err != nil:
+		return errorStyle.Render("✗ Failed")
+	case m.result == nil:
+		return mutedStyle.Render("^e: run statement  F5: run all")
+	}
+
+	var text string
+	if m.result.Columns != nil {
+		text = plural(len(m.result.Rows), "row")
+	} else {
+		text = plural(int(m.result.RowsAffected), "row") + " affected"
+	}
+	if m.statements > 1 {
+		text = plural(m.statements, "statement") + ", last: " + text
+	}
+	return successStyle.Render("✓ "+text) + mutedStyle.Render(" in "+m.duration.Round(time.Millisecond).String())
+}
+
+// renderResult shows how a run is going, the error of the last one, or its
+// rows in the grid
+func (m QueryModel) renderResult() string {
+	if m.busy.Shown() {
+		return lipgloss.Place(m.width, m.resultHeight(), lipgloss.Center, lipgloss.Center, m.busy.View(m.width))
+	}
+	if m.err != nil {
+		errorStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{
+				Light: catppuccin.Latte.Red().Hex,
+				Dark:  catppuccin.Mocha.Red().Hex,
+			})
+		return errorStyle.Width(m.width).Render(m.err.Error())
+	}
+	if m.resultHeight() < 3 {
+		return ""
+	}
+	return m.grid.View()
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// Connection returns the connection the queries run against
+func (m QueryModel) Connection() types.Connection {
+	return m.connection
+}
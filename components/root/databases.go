@@ -0,0 +1,134 @@
+package root
+
+import (
+	"context"
+	"nectar/driver"
+	"nectar/types"
+	"strings"
+
+	catppuccin "github.com/catppuccin/go"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const maxVisibleDatabases = 8
+
+type databasesLoadedMsg struct {
+	databases []string
+	err       error
+}
+
+// DatabasePickerModel lists the databases on the connected server so the
+// session can be reopened against a different one
+type DatabasePickerModel struct {
+	connection   types.Connection
+	databases    []string
+	selected     int
+	scrollOffset int
+	visible      bool
+	loading      bool
+	err          error
+}
+
+// Open shows the picker and starts listing the databases available to d
+func (m DatabasePickerModel) Open(conn types.Connection, d driver.Driver) (DatabasePickerModel, tea.Cmd) {
+	m = DatabasePickerModel{connection: conn, visible: true, loading: true}
+	return m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), ConnectTimeout)
+		defer cancel()
+		databases, err := d.Databases(ctx)
+		return databasesLoadedMsg{databases: databases, err: err}
+	}
+}
+
+func (m DatabasePickerModel) Visible() bool {
+	return m.visible
+}
+
+func (m DatabasePickerModel) Update(msg tea.Msg) (DatabasePickerModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case databasesLoadedMsg:
+		m.loading, m.databases, m.err = false, msg.databases, msg.err
+		// Start on the database the session is already using
+		for i, name := range m.databases {
+			if name == m.connection.Database {
+				m.selected = i
+				m.scrollOffset = max(0, i-maxVisibleDatabases+1)
+			}
+		}
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc":
+			m.visible = false
+		case "up", "k":
+			if m.selected > 0 {
+				m.selected--
+				if m.selected < m.scrollOffset {
+					m.scrollOffset = m.selected
+				}
+			}
+		case "down", "j":
+			if m.selected < len(m.databases)-1 {
+				m.selected++
+				if m.selected >= m.scrollOffset+maxVisibleDatabases {
+					m.scrollOffset = m.selected - maxVisibleDatabases + 1
+				}
+			}
+		case "enter":
+			if m.selected >= len(m.databases) {
+				break
+			}
+			m.visible = false
+			conn := m.connection
+			conn.Database = m.databases[m.selected]
+			return m, func() tea.Msg { return ConnectMsg{Connection: conn} }
+		}
+	}
+	return m, nil
+}
+
+func (m DatabasePickerModel) View() string {
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	var content strings.Builder
+	content.WriteString(lipgloss.NewStyle().Bold(true).Render("Switch database") + "\n")
+
+	switch {
+	case m.loading:
+		content.WriteString(mutedStyle.Render("  Loading databases…") + "\n")
+	case m.err != nil:
+		errorStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{
+				Light: catppuccin.Latte.Red().Hex,
+				Dark:  catppuccin.Mocha.Red().Hex,
+			})
+		content.WriteString(errorStyle.Render("  ✗ "+m.err.Error()) + "\n")
+	case len(m.databases) == 0:
+		content.WriteString(mutedStyle.Render("  No databases visible to this user") + "\n")
+	default:
+		selectedStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{
+				Light: catppuccin.Latte.Mauve().Hex,
+				Dark:  catppuccin.Mocha.Mauve().Hex,
+			})
+		end := min(m.scrollOffset+maxVisibleDatabases, len(m.databases))
+		for i := m.scrollOffset; i < end; i++ {
+			name := m.databases[i]
+			if name == m.connection.Database {
+				name += " (current)"
+			}
+			if i == m.selected {
+				content.WriteString(selectedStyle.Render("> "+name) + "\n")
+			} else {
+				content.WriteString("  " + name + "\n")
+			}
+		}
+	}
+
+	content.WriteString(mutedStyle.Render("↑/↓: navigate, Enter: switch, Esc: close"))
+	return content.String()
+}
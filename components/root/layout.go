@@ -0,0 +1,45 @@
+package root
+
+import "nectar/types"
+
+const (
+	// DefaultSidebarWidth is how wide the sidebar starts out
+	DefaultSidebarWidth = 30
+	// MinSidebarWidth is as narrow as resizing takes the sidebar
+	MinSidebarWidth = 20
+	// SidebarStep is how many columns resizing moves the sidebar's edge
+	SidebarStep = 2
+	// NarrowWidth is the terminal width below which the panes take turns:
+	// whichever has focus fills the screen
+	NarrowWidth = 80
+)
+
+// Layout shares the connections screen between its panes. A pane given no
+// columns isn't drawn; the sidebar's border is counted in neither.
+type Layout struct {
+	Sidebar int
+	Main    int
+	Height  int
+}
+
+// NewLayout lays the panes out in the terminal, the status bar left below
+// them
+func NewLayout(globals *types.Globals, sidebar SidebarModel) Layout {
+	layout := Layout{Height: max(0, globals.Height-1)}
+	switch {
+	case globals.Width < NarrowWidth && sidebar.Focused():
+		layout.Sidebar = globals.Width - 1
+	case globals.Width < NarrowWidth || sidebar.Collapsed():
+		layout.Main = globals.Width
+	default:
+		// The sidebar never takes more than half
+		layout.Sidebar = min(sidebar.width, globals.Width/2)
+		layout.Main = globals.Width - layout.Sidebar - 1
+	}
+	return layout
+}
+
+// Narrow reports whether the panes take turns rather than sit side by side
+func (l Layout) Narrow() bool {
+	return l.Sidebar == 0 || l.Main == 0
+}
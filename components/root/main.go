@@ -1,19 +1,31 @@
 package root
 
 import (
+	"nectar/components/shared"
+	"nectar/keymap"
+	"nectar/store"
 	"nectar/types"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 type MainAreaModel struct {
+	store          *store.Store
 	connectionForm ConnectionFormModel
+	session        *session
+	showSession    bool
+	databasePicker DatabasePickerModel
+	prompt         SecretPromptModel
+	width          int
+	height         int
 }
 
-func NewMainArea() MainAreaModel {
+func NewMainArea(connections *store.Store) MainAreaModel {
 	return MainAreaModel{
-		connectionForm: NewConnectionForm(),
+		store:          connections,
+		connectionForm: NewConnectionForm(connections),
 	}
 }
 
@@ -22,20 +34,139 @@ func (m MainAreaModel) Init() tea.Cmd {
 }
 
 func (m MainAreaModel) Update(msg tea.Msg) (MainAreaModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case NewConnectionMsg:
+		m.connectionForm = NewConnectionForm(m.store).SetSize(m.width, m.height)
+		m.showSession = false
+		return m, m.connectionForm.Init()
+	case EditConnectionMsg:
+		m.connectionForm = EditConnectionForm(m.store, msg.Connection).SetSize(m.width, m.height)
+		m.showSession = false
+		return m, m.connectionForm.Init()
+	case SessionOpenedMsg:
+		m.session.close()
+		m.session = &session{connection: msg.Connection, driver: msg.Driver, schema: msg.Schema}
+		m.showSession = true
+		m.databasePicker = DatabasePickerModel{}
+		return m, nil
+	case databasesLoadedMsg:
+		var cmd tea.Cmd
+		m.databasePicker, cmd = m.databasePicker.Update(msg)
+		return m, cmd
+	case UnlockVaultMsg:
+		var cmd tea.Cmd
+		m.prompt, cmd = UnlockVaultPrompt(m.store, msg.Retry)
+		m.prompt = m.prompt.SetSize(m.width, m.height)
+		return m, cmd
+	case PasswordRequiredMsg:
+		var cmd tea.Cmd
+		m.prompt, cmd = ConnectionPasswordPrompt(msg.Connection)
+		m.prompt = m.prompt.SetSize(m.width, m.height)
+		return m, cmd
+	case promptDoneMsg, promptFailedMsg:
+		var cmd tea.Cmd
+		m.prompt, cmd = m.prompt.Update(msg)
+		return m, cmd
+	}
+
+	if msg, ok := msg.(tea.KeyMsg); ok && m.prompt.Visible() {
+		var cmd tea.Cmd
+		m.prompt, cmd = m.prompt.Update(msg)
+		return m, cmd
+	}
+
+	if msg, ok := msg.(tea.KeyMsg); ok && m.showSession {
+		return m.handleSessionKeys(msg)
+	}
+
 	var cmd tea.Cmd
 	formModel, cmd := m.connectionForm.Update(msg)
 	m.connectionForm = formModel.(ConnectionFormModel)
 	return m, cmd
 }
 
-func MainArea(globals *types.Globals, mainArea MainAreaModel) string {
+// Handle keys while the session card is shown
+func (m MainAreaModel) handleSessionKeys(msg tea.KeyMsg) (MainAreaModel, tea.Cmd) {
+	var cmd tea.Cmd
+	if m.databasePicker.Visible() {
+		m.databasePicker, cmd = m.databasePicker.Update(msg)
+		return m, cmd
+	}
+
+	switch {
+	case key.Matches(msg, keymap.Session.Editor):
+		s := m.session
+		cmd = func() tea.Msg {
+			return OpenEditorMsg{Connection: s.connection, Driver: s.driver, Schema: s.schema}
+		}
+	case key.Matches(msg, keymap.Session.Databases) && m.session.connection.Type != types.SQLite:
+		m.databasePicker, cmd = m.databasePicker.Open(m.session.connection, m.session.driver)
+	}
+	return m, cmd
+}
+
+// SetSize fits the main area, and whatever it shows, into width by height
+// cells
+func (m MainAreaModel) SetSize(width, height int) MainAreaModel {
+	m.width, m.height = width, height
+	m.connectionForm = m.connectionForm.SetSize(width, height)
+	m.prompt = m.prompt.SetSize(width, height)
+	return m
+}
+
+// Capturing reports whether the main area is consuming keys that would
+// otherwise move focus, such as while a text field or file picker is open
+func (m MainAreaModel) Capturing() bool {
+	if m.prompt.Visible() {
+		return true
+	}
+	if m.showSession {
+		return m.databasePicker.Visible()
+	}
+	return m.connectionForm.editing || m.connectionForm.showFilePicker || m.connectionForm.confirmSave ||
+		m.connectionForm.showURLInput || m.connectionForm.showTLSForm ||
+		m.connectionForm.showSSHForm
+}
+
+// Bindings lists the keys the main area answers to as it stands
+func (m MainAreaModel) Bindings() []key.Binding {
+	switch {
+	case m.prompt.Visible():
+		return []key.Binding{keymap.Field.Done, keymap.Field.Cancel}
+	case m.showSession && m.databasePicker.Visible():
+		return []key.Binding{keymap.Sidebar.Up, keymap.Sidebar.Down, keymap.Field.Done, keymap.Field.Cancel}
+	case m.showSession:
+		return []key.Binding{
+			keymap.Session.Editor,
+			keymap.When(keymap.Session.Databases, m.session.connection.Type != types.SQLite),
+		}
+	}
+	return m.connectionForm.Bindings()
+}
+
+// CloseSession closes the open database session, if any
+func (m MainAreaModel) CloseSession() {
+	m.session.close()
+}
+
+// MainArea draws the main area into the space the layout gives it
+func MainArea(layout Layout, mainArea MainAreaModel) string {
+	if layout.Main == 0 {
+		return ""
+	}
+
 	formContent := mainArea.connectionForm.View()
+	if mainArea.prompt.Visible() {
+		formContent = mainArea.prompt.View()
+	} else if mainArea.showSession {
+		formContent = mainArea.session.View(mainArea.databasePicker, layout.Main, layout.Height)
+	}
 
 	return lipgloss.Place(
-		globals.Width-30,
-		globals.Height-1,
+		layout.Main,
+		layout.Height,
 		lipgloss.Center,
 		lipgloss.Center,
-		formContent,
+		shared.Clip(formContent, layout.Height),
 	)
 }
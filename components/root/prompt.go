@@ -0,0 +1,224 @@
+package root
+
+import (
+	"errors"
+	"nectar/components/shared"
+	"nectar/store"
+	"nectar/types"
+	"strings"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// UnlockVaultMsg asks for the master password. Retry is run again once the
+// vault is open.
+type UnlockVaultMsg struct {
+	Retry tea.Cmd
+}
+
+// PasswordRequiredMsg asks for the password of a connection that is never
+// stored, before connecting to it
+type PasswordRequiredMsg struct {
+	Connection types.Connection
+}
+
+// promptDoneMsg closes the prompt and runs next
+type promptDoneMsg struct {
+	next tea.Cmd
+}
+
+// promptFailedMsg keeps the prompt open and shows err
+type promptFailedMsg struct {
+	err error
+}
+
+// SecretPromptModel asks for a password in a small modal. Entries are
+// handed to submit, whose command answers with promptDoneMsg or
+// promptFailedMsg.
+type SecretPromptModel struct {
+	title   string
+	hint    string
+	input   textinput.Model
+	entered string
+	confirm bool
+	submit  func(secret string) tea.Cmd
+	visible bool
+	busy    bool
+	err     error
+	width   int
+	height  int
+}
+
+func newSecretPrompt(title, hint string, confirm bool, submit func(string) tea.Cmd) (SecretPromptModel, tea.Cmd) {
+	input := textinput.New()
+	input.Placeholder = "password"
+	input.EchoMode = textinput.EchoPassword
+	input.EchoCharacter = '•'
+	input.CharLimit = 256
+	input.Width = 40
+
+	m := SecretPromptModel{
+		title:   title,
+		hint:    hint,
+		input:   input,
+		confirm: confirm,
+		submit:  submit,
+		visible: true,
+	}
+	return m, m.input.Focus()
+}
+
+// UnlockVaultPrompt asks for the master password, or for a new one twice
+// when no vault exists yet, and runs retry once the vault is open
+func UnlockVaultPrompt(connections *store.Store, retry tea.Cmd) (SecretPromptModel, tea.Cmd) {
+	vault := connections.Vault()
+	title, hint, create := "Unlock Vault", "Enter the master password for saved passwords", false
+	if !vault.Exists() {
+		title, hint, create = "Create Vault", "Choose a master password to encrypt saved passwords", true
+	}
+
+	return newSecretPrompt(title, hint, create, func(master string) tea.Cmd {
+		return func() tea.Msg {
+			if err := connections.Unlock(master); err != nil {
+				return promptFailedMsg{err: err}
+			}
+			return promptDoneMsg{next: retry}
+		}
+	})
+}
+
+// ConnectionPasswordPrompt asks for the password of conn and connects with it
+func ConnectionPasswordPrompt(conn types.Connection) (SecretPromptModel, tea.Cmd) {
+	hint := "Password for " + conn.Host
+	if conn.User != "" {
+		hint = "Password for " + conn.User + "@" + conn.Host
+	}
+	return newSecretPrompt("Connect to "+conn.Name, hint, false, func(password string) tea.Cmd {
+		conn.Password = password
+		return func() tea.Msg {
+			return promptDoneMsg{next: func() tea.Msg { return ConnectMsg{Connection: conn} }}
+		}
+	})
+}
+
+// SetSize fits the prompt into width by height cells
+func (m SecretPromptModel) SetSize(width, height int) SecretPromptModel {
+	m.width, m.height = width, height
+	m.input.Width = shared.InputWidth(40, shared.FitWidth(60, width))
+	return m
+}
+
+func (m SecretPromptModel) Visible() bool {
+	return m.visible
+}
+
+func (m SecretPromptModel) Update(msg tea.Msg) (SecretPromptModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case promptDoneMsg:
+		m.visible, m.busy = false, false
+		return m, msg.next
+	case promptFailedMsg:
+		m.busy, m.err = false, msg.err
+		m.entered = ""
+		m.input.SetValue("")
+		return m, nil
+	case tea.KeyMsg:
+		if m.busy {
+			return m, nil
+		}
+		switch msg.String() {
+		case "esc":
+			m.visible = false
+			return m, nil
+		case "enter":
+			return m.handleEnterKey()
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// Submit the entry, asking for it a second time first when confirming
+func (m SecretPromptModel) handleEnterKey() (SecretPromptModel, tea.Cmd) {
+	value := m.input.Value()
+	if value == "" {
+		return m, nil
+	}
+
+	if m.confirm && m.entered == "" {
+		m.entered, m.err = value, nil
+		m.input.SetValue("")
+		return m, nil
+	}
+	if m.confirm && value != m.entered {
+		m.entered, m.err = "", errors.New("passwords don't match, try again")
+		m.input.SetValue("")
+		return m, nil
+	}
+
+	m.busy, m.err = true, nil
+	return m, m.submit(value)
+}
+
+func (m SecretPromptModel) View() string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Bold(true)
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Text().Hex,
+			Dark:  catppuccin.Mocha.Text().Hex,
+		})
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	var content strings.Builder
+	content.WriteString(titleStyle.Render(m.title) + "\n\n")
+	content.WriteString(labelStyle.Render(m.hint) + "\n\n")
+
+	label := "Password:"
+	if m.confirm && m.entered != "" {
+		label = "Repeat password:"
+	}
+	content.WriteString(labelStyle.Render(label) + "\n")
+	content.WriteString(m.input.View() + "\n\n")
+
+	if m.err != nil {
+		errorStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{
+				Light: catppuccin.Latte.Red().Hex,
+				Dark:  catppuccin.Mocha.Red().Hex,
+			})
+		content.WriteString(errorStyle.Render("✗ "+m.err.Error()) + "\n\n")
+	}
+
+	if m.busy {
+		content.WriteString(mutedStyle.Render("Checking…"))
+	} else {
+		content.WriteString(mutedStyle.Render("Enter: submit, Esc: cancel"))
+	}
+
+	promptStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Padding(1, 4).
+		Width(60)
+
+	return shared.Panel(promptStyle, content.String(), m.width, m.height)
+}
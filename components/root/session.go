@@ -0,0 +1,168 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nectar/components/shared"
+	"nectar/driver"
+	"nectar/store"
+	"nectar/types"
+	"strings"
+	"time"
+
+	catppuccin "github.com/catppuccin/go"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ConnectTimeout bounds how long opening a session may take
+const ConnectTimeout = 15 * time.Second
+
+// SessionOpenedMsg is sent once a connection is live and introspected
+type SessionOpenedMsg struct {
+	Connection types.Connection
+	Driver     driver.Driver
+	Schema     *driver.Schema
+}
+
+// OpenEditorMsg asks the screen to open the query editor on a session,
+// starting with Query in the editor and running it straight away when Run
+// is set
+type OpenEditorMsg struct {
+	Connection types.Connection
+	Driver     driver.Driver
+	Schema     *driver.Schema
+	Query      string
+	Run        bool
+}
+
+// OpenTableMsg asks the screen to page through the rows of a table or view
+type OpenTableMsg struct {
+	Connection types.Connection
+	Driver     driver.Driver
+	Schema     *driver.Schema
+	Object     driver.Object
+}
+
+// SessionFailedMsg is sent when opening a connection fails
+type SessionFailedMsg struct {
+	Connection types.Connection
+	Err        error
+}
+
+// OpenSession connects to conn in the background, first fetching its
+// passwords from wherever they are kept. A locked vault or a password that
+// has to be typed in turns into a prompt instead. Cancelling ctx gives up,
+// with a SessionFailedMsg carrying context.Canceled.
+func OpenSession(ctx context.Context, connections *store.Store, conn types.Connection) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(ctx, ConnectTimeout)
+		defer cancel()
+
+		resolved, err := connections.Resolve(ctx, conn)
+		switch {
+		case errors.Is(err, store.ErrVaultLocked):
+			return UnlockVaultMsg{Retry: func() tea.Msg { return ConnectMsg{Connection: conn} }}
+		case errors.Is(err, store.ErrPasswordRequired):
+			return PasswordRequiredMsg{Connection: conn}
+		case err != nil:
+			return SessionFailedMsg{Connection: conn, Err: err}
+		}
+		conn = resolved
+
+		d, err := driver.Connect(ctx, conn)
+		if err != nil {
+			return SessionFailedMsg{Connection: conn, Err: err}
+		}
+		schema, err := d.Introspect(ctx)
+		if err != nil {
+			d.Close()
+			return SessionFailedMsg{Connection: conn, Err: err}
+		}
+		return SessionOpenedMsg{Connection: conn, Driver: d, Schema: schema}
+	}
+}
+
+type session struct {
+	connection types.Connection
+	driver     driver.Driver
+	schema     *driver.Schema
+}
+
+func (s *session) close() {
+	if s != nil && s.driver != nil {
+		s.driver.Close()
+	}
+}
+
+// Describe where a connection points, e.g. "db.example.com:5432/app"
+func connectionTarget(conn types.Connection) string {
+	if conn.Type == types.SQLite {
+		return conn.DatabaseFile
+	}
+	target := conn.Host + ":" + conn.Port
+	if conn.Database != "" {
+		target += "/" + conn.Database
+	}
+	if conn.SSH.Enabled {
+		target += " via " + conn.SSH.Host
+	}
+	return target
+}
+
+// View draws the session's card into width by height cells
+func (s *session) View(databasePicker DatabasePickerModel, width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Green().Hex,
+			Dark:  catppuccin.Mocha.Green().Hex,
+		}).
+		Bold(true)
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Text().Hex,
+			Dark:  catppuccin.Mocha.Text().Hex,
+		})
+
+	var tables, views int
+	for _, table := range s.schema.Tables {
+		if table.Kind == driver.KindView {
+			views++
+		} else {
+			tables++
+		}
+	}
+
+	var content strings.Builder
+	content.WriteString(titleStyle.Render("● Connected to "+s.connection.Name) + "\n\n")
+	content.WriteString(labelStyle.Render("  Engine: "+s.connection.Type.String()) + "\n")
+	content.WriteString(labelStyle.Render("  Target: "+connectionTarget(s.connection)) + "\n")
+	content.WriteString(labelStyle.Render(fmt.Sprintf("  Objects: %d tables, %d views", tables, views)) + "\n")
+	content.WriteString(labelStyle.Faint(true).Render("  press Enter to open the query editor") + "\n")
+
+	if s.connection.Type != types.SQLite {
+		database := s.connection.Database
+		if database == "" {
+			database = "server default"
+		}
+		content.WriteString(labelStyle.Render("  Database: "+database) + "\n\n")
+		if databasePicker.Visible() {
+			content.WriteString(databasePicker.View() + "\n")
+		} else {
+			content.WriteString(labelStyle.Faint(true).Render("  press d to switch database") + "\n")
+		}
+	}
+
+	cardStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Green().Hex,
+			Dark:  catppuccin.Mocha.Green().Hex,
+		}).
+		Padding(2, 4).
+		Width(60)
+
+	return shared.Panel(cardStyle, content.String(), width, height)
+}
@@ -1,16 +1,517 @@
 package root
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"nectar/components/shared"
+	"nectar/importer"
+	"nectar/keymap"
+	"nectar/store"
 	"nectar/styles"
 	"nectar/types"
+	"nectar/utils"
+	"strings"
 
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 )
 
-func Sidebar(globals *types.Globals) string {
+// ConnectMsg asks the screen to open a session for the given connection
+type ConnectMsg struct {
+	Connection types.Connection
+}
+
+// NewConnectionMsg asks the main area to show a blank connection form
+type NewConnectionMsg struct{}
+
+// EditConnectionMsg asks the main area to open a saved connection for editing
+type EditConnectionMsg struct {
+	Connection types.Connection
+}
+
+// ConnectionDeletedMsg is sent once a connection has been removed from the store
+type ConnectionDeletedMsg struct {
+	Name string
+}
+
+type connectionsLoadedMsg struct {
+	connections []types.Connection
+	importable  []importer.Candidate
+	err         error
+}
+
+type connectionImportedMsg struct {
+	name string
+	err  error
+}
+
+// SidebarModel lists the saved connections, followed by those found in the
+// files of other clients that could be imported. While a session is open it
+// shows that session's schema tree instead, and c switches between the two.
+type SidebarModel struct {
+	store         *store.Store
+	connections   []types.Connection
+	importable    []importer.Candidate
+	selected      int
+	focused       bool
+	confirmDelete bool
+	pendingSelect string
+	connecting    string
+	active        string
+	err           error
+	tree          SchemaTreeModel
+	browsing      bool
+	// width is the width chosen for the sidebar, which collapsing hides
+	// without forgetting
+	width     int
+	collapsed bool
+}
+
+func NewSidebar(connections *store.Store) SidebarModel {
+	return SidebarModel{
+		store: connections,
+		width: DefaultSidebarWidth,
+	}
+}
+
+func (m SidebarModel) Init() tea.Cmd {
+	return m.load()
+}
+
+func (m SidebarModel) Update(msg tea.Msg) (SidebarModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case connectionsLoadedMsg:
+		m.err = msg.err
+		if msg.err == nil {
+			m.connections = groupByEngine(msg.connections)
+			m.importable = msg.importable
+		}
+		m.selected = max(0, min(m.selected, m.entries()-1))
+		if m.pendingSelect != "" {
+			m.selectByName(m.pendingSelect)
+			m.pendingSelect = ""
+		}
+		return m, nil
+	case ConnectionSavedMsg:
+		// Highlight the saved connection once the reloaded list arrives
+		m.pendingSelect = msg.Connection.Name
+		return m, m.load()
+	case ConnectionDeletedMsg:
+		return m, m.load()
+	case connectionImportedMsg:
+		if msg.err != nil {
+			m.err = fmt.Errorf("%s: %w", msg.name, msg.err)
+			return m, nil
+		}
+		m.pendingSelect, m.err = msg.name, nil
+		return m, m.load()
+	case ConnectMsg:
+		m.connecting, m.err = msg.Connection.Name, nil
+		return m, nil
+	case SessionOpenedMsg:
+		m.connecting, m.active = "", msg.Connection.Name
+		m.tree = NewSchemaTree(msg.Connection, msg.Driver, msg.Schema)
+		m.browsing = true
+		return m, m.tree.Init()
+	case SessionFailedMsg:
+		m.connecting, m.err = "", fmt.Errorf("%s: %w", msg.Connection.Name, msg.Err)
+		// Giving up on connecting isn't worth reporting
+		if errors.Is(msg.Err, context.Canceled) {
+			m.err = nil
+		}
+		return m, nil
+	case UnlockVaultMsg, PasswordRequiredMsg:
+		// Connecting waits for the prompt and starts over once answered
+		m.connecting = ""
+		return m, nil
+	case tea.KeyMsg:
+		if !m.focused {
+			return m, nil
+		}
+		if m.confirmDelete {
+			return m.handleConfirmKeys(msg)
+		}
+		if key.Matches(msg, keymap.Sidebar.Browse) && m.tree.Active() && !m.tree.Capturing() {
+			m.browsing = !m.browsing
+			return m, nil
+		}
+		if m.browsing {
+			var cmd tea.Cmd
+			m.tree, cmd = m.tree.Update(msg)
+			return m, cmd
+		}
+		return m.handleKeys(msg)
+	}
+
+	// Whatever else there is concerns the tree's background work
+	var cmd tea.Cmd
+	m.tree, cmd = m.tree.Update(msg)
+	return m, cmd
+}
+
+// Handle list navigation and actions
+func (m SidebarModel) handleKeys(msg tea.KeyMsg) (SidebarModel, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keymap.Sidebar.Up):
+		if m.selected > 0 {
+			m.selected--
+		}
+	case key.Matches(msg, keymap.Sidebar.Down):
+		if m.selected < m.entries()-1 {
+			m.selected++
+		}
+	case key.Matches(msg, keymap.Sidebar.Import) && m.selected >= len(m.connections):
+		if candidate, ok := m.selectedCandidate(); ok {
+			return m, m.importConnection(candidate.Connection)
+		}
+	case key.Matches(msg, keymap.Sidebar.Connect):
+		if conn, ok := m.Selected(); ok {
+			return m, func() tea.Msg { return ConnectMsg{Connection: conn} }
+		}
+	case key.Matches(msg, keymap.Sidebar.Edit):
+		if conn, ok := m.Selected(); ok {
+			return m, func() tea.Msg { return EditConnectionMsg{Connection: conn} }
+		}
+	case key.Matches(msg, keymap.Sidebar.Delete):
+		if _, ok := m.Selected(); ok {
+			m.confirmDelete = true
+		}
+	}
+	return m, nil
+}
+
+// Handle the y/n answer to the delete prompt; anything but "y" cancels
+func (m SidebarModel) handleConfirmKeys(msg tea.KeyMsg) (SidebarModel, tea.Cmd) {
+	m.confirmDelete = false
+	if msg.String() != "y" && msg.String() != "Y" {
+		return m, nil
+	}
+
+	conn, ok := m.Selected()
+	if !ok {
+		return m, nil
+	}
+	connections := m.store
+	return m, func() tea.Msg {
+		if err := connections.Delete(conn.Name); err != nil {
+			return connectionsLoadedMsg{err: err}
+		}
+		return ConnectionDeletedMsg{Name: conn.Name}
+	}
+}
+
+// Save a connection found in another client's files. A locked vault asks
+// for the master password and the import is tried again.
+func (m SidebarModel) importConnection(conn types.Connection) tea.Cmd {
+	connections := m.store
+	var save tea.Cmd
+	save = func() tea.Msg {
+		err := connections.Save(conn)
+		if errors.Is(err, store.ErrVaultLocked) {
+			return UnlockVaultMsg{Retry: save}
+		}
+		return connectionImportedMsg{name: conn.Name, err: err}
+	}
+	return save
+}
+
+func (m SidebarModel) load() tea.Cmd {
+	connections := m.store
+	return func() tea.Msg {
+		loaded, err := connections.Load()
+		if err != nil {
+			return connectionsLoadedMsg{err: err}
+		}
+		importable := importer.Pending(importer.Discover(), loaded)
+		return connectionsLoadedMsg{connections: loaded, importable: importable}
+	}
+}
+
+// Focus gives the sidebar keyboard focus, showing it if it was collapsed
+func (m SidebarModel) Focus() SidebarModel {
+	m.focused = true
+	m.collapsed = false
+	return m
+}
+
+// Collapse hides the sidebar, which gives up focus with it
+func (m SidebarModel) Collapse() SidebarModel {
+	m.collapsed = true
+	return m.Blur()
+}
+
+// Collapsed reports whether the sidebar is hidden
+func (m SidebarModel) Collapsed() bool {
+	return m.collapsed
+}
+
+// Resize widens the sidebar by delta columns, or narrows it for a negative
+// delta, keeping it within bounds for a terminal total columns wide
+func (m SidebarModel) Resize(delta, total int) SidebarModel {
+	m.width = max(MinSidebarWidth, min(m.width+delta, total/2))
+	return m
+}
+
+// Blur removes keyboard focus and cancels any pending delete prompt
+func (m SidebarModel) Blur() SidebarModel {
+	m.focused = false
+	m.confirmDelete = false
+	return m
+}
+
+func (m SidebarModel) Focused() bool {
+	return m.focused
+}
+
+// Capturing reports whether the sidebar is taking every key, such as while
+// typing into the schema tree's filter or answering the delete prompt
+func (m SidebarModel) Capturing() bool {
+	return m.confirmDelete || (m.browsing && m.tree.Capturing())
+}
+
+// Bindings lists the keys the sidebar answers to as it stands. While a
+// delete waits on its answer every key is the sidebar's.
+func (m SidebarModel) Bindings() []key.Binding {
+	browse := keymap.When(keymap.Sidebar.Browse, m.tree.Active() && !m.tree.Capturing())
+	if m.browsing {
+		return append(m.tree.Bindings(), browse)
+	}
+	_, selected := m.Selected()
+	_, importable := m.selectedCandidate()
+	return []key.Binding{
+		keymap.Sidebar.Up,
+		keymap.Sidebar.Down,
+		keymap.When(keymap.Sidebar.Import, importable),
+		keymap.When(keymap.Sidebar.Connect, selected),
+		keymap.When(keymap.Sidebar.Edit, selected),
+		keymap.When(keymap.Sidebar.Delete, selected),
+		browse,
+	}
+}
+
+// Selected returns the highlighted connection, if any
+func (m SidebarModel) Selected() (types.Connection, bool) {
+	if m.selected < 0 || m.selected >= len(m.connections) {
+		return types.Connection{}, false
+	}
+	return m.connections[m.selected], true
+}
+
+// selectedCandidate returns the highlighted importable connection, if any.
+// These are listed after the saved ones.
+func (m SidebarModel) selectedCandidate() (importer.Candidate, bool) {
+	i := m.selected - len(m.connections)
+	if i < 0 || i >= len(m.importable) {
+		return importer.Candidate{}, false
+	}
+	return m.importable[i], true
+}
+
+// Len returns the number of saved connections
+func (m SidebarModel) Len() int {
+	return len(m.connections)
+}
+
+// entries counts what can be selected: the saved connections and the
+// importable ones
+func (m SidebarModel) entries() int {
+	return len(m.connections) + len(m.importable)
+}
+
+func (m *SidebarModel) selectByName(name string) {
+	for i, conn := range m.connections {
+		if conn.Name == name {
+			m.selected = i
+			return
+		}
+	}
+}
+
+// Order connections by engine, keeping the saved order within each engine
+func groupByEngine(connections []types.Connection) []types.Connection {
+	grouped := make([]types.Connection, 0, len(connections))
+	for _, connType := range utils.ConnectionTypes {
+		for _, conn := range connections {
+			if conn.Type == connType {
+				grouped = append(grouped, conn)
+			}
+		}
+	}
+	return grouped
+}
+
+// Sidebar draws the sidebar into the space the layout gives it
+func Sidebar(layout Layout, sidebar SidebarModel) string {
+	if layout.Sidebar == 0 {
+		return ""
+	}
+
 	return styles.BaseStyle.
-		Width(30).Height(globals.Height - 1).
+		Width(layout.Sidebar).Height(layout.Height).
 		BorderRight(true).
 		BorderStyle(lipgloss.NormalBorder()).
-		Render("Sidebar placeholder")
+		Render(sidebar.render(layout.Sidebar, layout.Height))
+}
+
+func (m SidebarModel) render(width, height int) string {
+	if m.browsing {
+		return m.tree.render(width, height, m.focused)
+	}
+	return m.renderConnections(width, height)
+}
+
+func (m SidebarModel) renderConnections(width, height int) string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Bold(true)
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Subtext0().Hex,
+			Dark:  catppuccin.Mocha.Subtext0().Hex,
+		})
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	var content strings.Builder
+	content.WriteString(styles.PaddedHorizontal.Render(titleStyle.Render("Connections")) + "\n\n")
+
+	footer := m.renderFooter(width, mutedStyle)
+
+	if m.entries() == 0 {
+		content.WriteString(styles.PaddedHorizontal.Width(width).Render(
+			mutedStyle.Render("No saved connections. Press ^n to create one."),
+		))
+		return content.String() + "\n\n" + footer
+	}
+
+	// Build the list as lines, remembering which line holds each connection
+	var lines []string
+	selectedLine := 0
+	for i, conn := range m.connections {
+		if i == 0 || m.connections[i-1].Type != conn.Type {
+			if i > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, styles.PaddedHorizontal.Render(headerStyle.Render(conn.Type.String())))
+		}
+		if i == m.selected {
+			selectedLine = len(lines)
+		}
+		lines = append(lines, m.renderEntry(i, conn, width))
+	}
+
+	// Then what other clients' files describe, under a heading of its own
+	for i, candidate := range m.importable {
+		if i == 0 {
+			if len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, styles.PaddedHorizontal.Render(headerStyle.Render("Importable")))
+		}
+		index := len(m.connections) + i
+		if index == m.selected {
+			selectedLine = len(lines)
+		}
+		lines = append(lines, m.renderCandidate(index, candidate, width, mutedStyle))
+	}
+
+	// Keep the selected entry inside the visible window
+	visible := max(1, height-2-lipgloss.Height(footer))
+	offset := 0
+	if selectedLine >= visible {
+		offset = selectedLine - visible + 1
+	}
+	end := min(offset+visible, len(lines))
+
+	content.WriteString(strings.Join(lines[offset:end], "\n"))
+	for range visible - (end - offset) {
+		content.WriteString("\n")
+	}
+	content.WriteString("\n" + footer)
+
+	return content.String()
+}
+
+func (m SidebarModel) renderEntry(index int, conn types.Connection, width int) string {
+	swatch := lipgloss.NewStyle().
+		Foreground(shared.ConnectionColors[shared.ColorIndex(conn.Color)].Color).
+		Render("■")
+
+	name := ansi.Truncate(conn.Name, width-8, "…")
+
+	if conn.Name == m.active {
+		name += " ●"
+	} else if conn.Name == m.connecting {
+		name += " …"
+	}
+
+	if index != m.selected {
+		return "   " + swatch + " " + name
+	}
+
+	selectedStyle := lipgloss.NewStyle().Bold(true)
+	if m.focused {
+		selectedStyle = selectedStyle.Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		})
+	}
+	return " " + selectedStyle.Render(">") + " " + swatch + " " + selectedStyle.Render(name)
+}
+
+func (m SidebarModel) renderCandidate(index int, candidate importer.Candidate, width int, mutedStyle lipgloss.Style) string {
+	source := " " + candidate.Source.String()
+	name := ansi.Truncate(candidate.Connection.Name, max(1, width-8-len(source)), "…")
+
+	if index != m.selected {
+		return "   " + mutedStyle.Render("+ "+name+source)
+	}
+
+	selectedStyle := lipgloss.NewStyle().Bold(true)
+	if m.focused {
+		selectedStyle = selectedStyle.Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		})
+	}
+	return " " + selectedStyle.Render(">") + " " + mutedStyle.Render("+") + " " +
+		selectedStyle.Render(name) + mutedStyle.Render(source)
+}
+
+func (m SidebarModel) renderFooter(width int, mutedStyle lipgloss.Style) string {
+	warningStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Red().Hex,
+			Dark:  catppuccin.Mocha.Red().Hex,
+		})
+
+	if m.err != nil {
+		return styles.PaddedHorizontal.Width(width).Render(warningStyle.Render("✗ " + m.err.Error()))
+	}
+	if !m.confirmDelete {
+		if candidate, ok := m.selectedCandidate(); ok && m.focused {
+			return styles.PaddedHorizontal.Width(width).Render(mutedStyle.Render("From " + candidate.Path))
+		}
+		if m.tree.Active() {
+			return styles.PaddedHorizontal.Width(width).Render(mutedStyle.Render("c: back to " + m.active))
+		}
+		return ""
+	}
+
+	conn, _ := m.Selected()
+	return styles.PaddedHorizontal.Width(width).Render(
+		warningStyle.Render("Delete \""+conn.Name+"\"?") + " " + mutedStyle.Render("(y/n)"),
+	)
 }
@@ -0,0 +1,371 @@
+package root
+
+import (
+	"nectar/components/shared"
+	"nectar/types"
+	"nectar/utils"
+	"os"
+	"path/filepath"
+	"strings"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// SSH tunnel field indices
+const (
+	sshFieldEnabled = iota
+	sshFieldHost
+	sshFieldPort
+	sshFieldUser
+	sshFieldPassword
+	sshFieldKeyFile
+	sshFieldAgent
+	sshFieldForwardAgent
+	sshFieldHostKeyCheck
+	sshFieldKnownHosts
+	sshFieldCount
+)
+
+// SSH tunnel text input indices
+const (
+	sshInputHost = iota
+	sshInputPort
+	sshInputUser
+	sshInputPassword
+	sshInputCount
+)
+
+// Map SSH tunnel fields to their text inputs
+var sshFieldInputs = map[int]int{
+	sshFieldHost:     sshInputHost,
+	sshFieldPort:     sshInputPort,
+	sshFieldUser:     sshInputUser,
+	sshFieldPassword: sshInputPassword,
+}
+
+// SSHFormModel edits a connection's SSH tunnel. Like the TLS settings it is
+// shown in place of the connection form.
+type SSHFormModel struct {
+	tunnel         types.SSHTunnel
+	inputs         []textinput.Model
+	filePicker     shared.FilePickerModel
+	showFilePicker bool
+	focused        int
+	editing        bool
+	width          int
+	height         int
+}
+
+func NewSSHForm(tunnel types.SSHTunnel) SSHFormModel {
+	inputs := make([]textinput.Model, sshInputCount)
+
+	inputs[sshInputHost] = textinput.New()
+	inputs[sshInputHost].Placeholder = "bastion.example.com"
+	inputs[sshInputHost].CharLimit = 255
+	inputs[sshInputHost].Width = 40
+
+	inputs[sshInputPort] = textinput.New()
+	inputs[sshInputPort].Placeholder = utils.DefaultSSHPort
+	inputs[sshInputPort].CharLimit = 6
+	inputs[sshInputPort].Width = 10
+
+	inputs[sshInputUser] = textinput.New()
+	inputs[sshInputUser].Placeholder = "username"
+	inputs[sshInputUser].CharLimit = 100
+	inputs[sshInputUser].Width = 40
+
+	inputs[sshInputPassword] = textinput.New()
+	inputs[sshInputPassword].Placeholder = "password or key passphrase"
+	inputs[sshInputPassword].EchoMode = textinput.EchoPassword
+	inputs[sshInputPassword].EchoCharacter = '•'
+	inputs[sshInputPassword].CharLimit = 100
+	inputs[sshInputPassword].Width = 40
+
+	inputs[sshInputHost].SetValue(tunnel.Host)
+	inputs[sshInputPort].SetValue(tunnel.Port)
+	inputs[sshInputUser].SetValue(tunnel.User)
+	inputs[sshInputPassword].SetValue(tunnel.Password)
+
+	return SSHFormModel{
+		tunnel: tunnel,
+		inputs: inputs,
+	}
+}
+
+// SetSize fits the panel, and the file picker it opens, into width by
+// height cells
+func (m SSHFormModel) SetSize(width, height int) SSHFormModel {
+	m.width, m.height = width, height
+	panel := shared.FitWidth(60, width)
+	for i := range m.inputs {
+		if i != sshInputPort {
+			m.inputs[i].Width = shared.InputWidth(40, panel)
+		}
+	}
+	m.filePicker = m.filePicker.WithSize(width, height)
+	return m
+}
+
+// Tunnel returns the SSH tunnel settings as currently edited
+func (m SSHFormModel) Tunnel() types.SSHTunnel {
+	tunnel := m.tunnel
+	tunnel.Host = strings.TrimSpace(m.inputs[sshInputHost].Value())
+	tunnel.Port = strings.TrimSpace(m.inputs[sshInputPort].Value())
+	tunnel.User = strings.TrimSpace(m.inputs[sshInputUser].Value())
+	tunnel.Password = m.inputs[sshInputPassword].Value()
+	return tunnel
+}
+
+// Capturing reports whether esc should stay inside the SSH form
+func (m SSHFormModel) Capturing() bool {
+	return m.editing || m.showFilePicker
+}
+
+func (m SSHFormModel) Update(msg tea.Msg) (SSHFormModel, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	if m.showFilePicker {
+		return m.handleFilePickerKeys(keyMsg)
+	}
+
+	if m.editing {
+		switch keyMsg.String() {
+		case "enter", "esc", "tab", "shift+tab":
+			m.editing = false
+			m.blurAllInputs()
+		default:
+			input := sshFieldInputs[m.focused]
+			var cmd tea.Cmd
+			m.inputs[input], cmd = m.inputs[input].Update(keyMsg)
+			return m, cmd
+		}
+	}
+
+	switch keyMsg.String() {
+	case "tab", "down":
+		m.focused = (m.focused + 1) % sshFieldCount
+	case "shift+tab", "up":
+		m.focused = (m.focused - 1 + sshFieldCount) % sshFieldCount
+	case "enter":
+		return m.handleEnterKey()
+	case "backspace", "delete":
+		// Clear the focused file path
+		if path := m.pathFor(m.focused); path != nil {
+			*path = ""
+		}
+	}
+	return m, nil
+}
+
+func (m SSHFormModel) handleEnterKey() (SSHFormModel, tea.Cmd) {
+	switch m.focused {
+	case sshFieldEnabled:
+		m.tunnel.Enabled = !m.tunnel.Enabled
+		return m, nil
+	case sshFieldAgent:
+		m.tunnel.UseAgent = !m.tunnel.UseAgent
+		return m, nil
+	case sshFieldForwardAgent:
+		// The agent is only forwarded to a bastion whose host key is checked
+		m.tunnel.ForwardAgent = !m.tunnel.ForwardAgent
+		if m.tunnel.ForwardAgent {
+			m.tunnel.SkipHostKeyCheck = false
+		}
+		return m, nil
+	case sshFieldHostKeyCheck:
+		m.tunnel.SkipHostKeyCheck = !m.tunnel.SkipHostKeyCheck
+		if m.tunnel.SkipHostKeyCheck {
+			m.tunnel.ForwardAgent = false
+		}
+		return m, nil
+	}
+
+	if input, ok := sshFieldInputs[m.focused]; ok {
+		m.editing = true
+		return m, m.inputs[input].Focus()
+	}
+
+	if path := m.pathFor(m.focused); path != nil {
+		m.filePicker = shared.NewFilePicker().
+			WithTitle("Select "+sshFieldName(m.focused)).
+			WithExtensions().
+			WithSize(m.width, m.height)
+		if *path != "" {
+			m.filePicker = m.filePicker.WithDirectory(filepath.Dir(*path))
+		} else {
+			m.filePicker = m.filePicker.WithDirectory(sshDir())
+		}
+		m.showFilePicker = true
+		return m, m.filePicker.Init()
+	}
+	return m, nil
+}
+
+func (m SSHFormModel) handleFilePickerKeys(msg tea.KeyMsg) (SSHFormModel, tea.Cmd) {
+	if msg.String() == "esc" {
+		m.showFilePicker = false
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.filePicker, cmd = m.filePicker.Update(msg)
+	if selected := m.filePicker.SelectedFile(); selected != "" {
+		*m.pathFor(m.focused) = selected
+		m.showFilePicker = false
+	}
+	return m, cmd
+}
+
+func (m *SSHFormModel) blurAllInputs() {
+	for i := range m.inputs {
+		m.inputs[i].Blur()
+	}
+}
+
+// pathFor returns the file path behind a field, or nil for other fields
+func (m *SSHFormModel) pathFor(field int) *string {
+	switch field {
+	case sshFieldKeyFile:
+		return &m.tunnel.KeyFile
+	case sshFieldKnownHosts:
+		return &m.tunnel.KnownHostsFile
+	default:
+		return nil
+	}
+}
+
+func sshFieldName(field int) string {
+	switch field {
+	case sshFieldHost:
+		return "Host"
+	case sshFieldPort:
+		return "Port"
+	case sshFieldUser:
+		return "User"
+	case sshFieldPassword:
+		return "Password"
+	case sshFieldKeyFile:
+		return "Private Key"
+	case sshFieldKnownHosts:
+		return "Known Hosts File"
+	default:
+		return ""
+	}
+}
+
+// The usual home of keys and known_hosts, where browsing starts
+func sshDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(home, ".ssh")
+}
+
+func (m SSHFormModel) View() string {
+	if m.showFilePicker {
+		return m.filePicker.View()
+	}
+
+	var content strings.Builder
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Bold(true)
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Text().Hex,
+			Dark:  catppuccin.Mocha.Text().Hex,
+		})
+
+	focusedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		})
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	content.WriteString(titleStyle.Render("SSH Tunnel") + "\n\n")
+
+	m.renderToggle(&content, "Use SSH Tunnel", sshFieldEnabled, m.tunnel.Enabled, focusedStyle, labelStyle)
+
+	for _, field := range []int{sshFieldHost, sshFieldPort, sshFieldUser, sshFieldPassword} {
+		label := sshFieldName(field) + ":"
+		if m.focused == field {
+			hint := "press Enter to edit"
+			if m.editing {
+				hint = "press Enter to finish editing"
+			}
+			label = focusedStyle.Render("> " + label + " (" + hint + ")")
+		} else {
+			label = labelStyle.Render("  " + label)
+		}
+		content.WriteString(label + "\n")
+		content.WriteString("  " + m.inputs[sshFieldInputs[field]].View() + "\n\n")
+	}
+
+	m.renderPathField(&content, sshFieldKeyFile, "none", focusedStyle, labelStyle)
+	m.renderToggle(&content, "Use SSH Agent", sshFieldAgent, m.tunnel.UseAgent, focusedStyle, labelStyle)
+	m.renderToggle(&content, "Forward SSH Agent", sshFieldForwardAgent, m.tunnel.ForwardAgent, focusedStyle, labelStyle)
+	m.renderToggle(&content, "Verify Host Key", sshFieldHostKeyCheck, !m.tunnel.SkipHostKeyCheck, focusedStyle, labelStyle)
+	m.renderPathField(&content, sshFieldKnownHosts, "~/.ssh/known_hosts", focusedStyle, labelStyle)
+
+	content.WriteString(mutedStyle.Render("Esc: back to connection"))
+
+	formStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Padding(1, 4).
+		Width(60)
+
+	return shared.Panel(formStyle, content.String(), m.width, m.height)
+}
+
+func (m SSHFormModel) renderToggle(content *strings.Builder, name string, field int, enabled bool, focusedStyle, labelStyle lipgloss.Style) {
+	label := name + ": "
+	if enabled {
+		label += "✓ Yes"
+	} else {
+		label += "✗ No"
+	}
+
+	if m.focused == field {
+		label = focusedStyle.Render("> " + label + " (press Enter to toggle)")
+	} else {
+		label = labelStyle.Render("  " + label)
+	}
+	content.WriteString(label + "\n\n")
+}
+
+func (m SSHFormModel) renderPathField(content *strings.Builder, field int, fallback string, focusedStyle, labelStyle lipgloss.Style) {
+	path := *m.pathFor(field)
+	if path == "" {
+		path = fallback
+	}
+	label := sshFieldName(field) + ": " + path
+
+	if m.focused == field {
+		label = focusedStyle.Render("> " + label + " (Enter to browse, ⌫ to clear)")
+	} else {
+		label = labelStyle.Render("  " + label)
+	}
+	content.WriteString(label + "\n\n")
+}
@@ -1,33 +1,41 @@
 package root
 
 import (
+	"nectar/keymap"
 	"nectar/styles"
 	"nectar/types"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/lipgloss"
 )
 
-func StatusBar(globals *types.Globals) string {
+// StatusBar renders the bottom bar with hints for the bindings that are on
+// and the version. Hints that don't fit are left off from the last; when
+// not even the first fits beside the version, the version is left off.
+func StatusBar(globals *types.Globals, bindings ...key.Binding) string {
 	w := lipgloss.Width
 
-	helpText := lipgloss.JoinHorizontal(
-		lipgloss.Top,
-		styles.PaddedHorizontal.Render("↑/k: up"),
-		styles.PaddedHorizontal.Render("↓/j: down"),
-		styles.PaddedHorizontal.Render("↹: next"),
-		styles.PaddedHorizontal.Render("^n: new"),
-		styles.PaddedHorizontal.Render("^↵: connect"),
-		styles.PaddedHorizontal.Render("^s: save"),
-		styles.PaddedHorizontal.Render("^t: test"),
-		styles.PaddedHorizontal.Render("^d: delete"),
-		styles.PaddedHorizontal.Render("^c: quit"),
-	)
-
 	versionText := lipgloss.JoinHorizontal(
 		lipgloss.Top,
 		styles.PaddedHorizontal.Render("Nectar "+globals.Version+" ("+globals.BuildDate+")"),
 	)
 
+	help := keymap.Help(bindings...)
+	hints := make([]string, 0, len(help))
+	room := globals.Width - w(versionText)
+	for i, hint := range help {
+		hint = styles.PaddedHorizontal.Render(hint)
+		if i == 0 && w(hint) > room {
+			versionText, room = "", globals.Width
+		}
+		if w(hint) > room {
+			break
+		}
+		hints = append(hints, hint)
+		room -= w(hint)
+	}
+	helpText := lipgloss.JoinHorizontal(lipgloss.Top, hints...)
+
 	separator := styles.BaseStyle.Width(globals.Width - w(helpText) - w(versionText)).Render("")
 
 	return styles.StatusBar.Render(
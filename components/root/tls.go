@@ -0,0 +1,299 @@
+package root
+
+import (
+	"nectar/components/shared"
+	"nectar/types"
+	"path/filepath"
+	"strings"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TLS settings field indices
+const (
+	tlsFieldMode = iota
+	tlsFieldCA
+	tlsFieldCert
+	tlsFieldKey
+	tlsFieldServerName
+	tlsFieldCount
+)
+
+// Extensions offered when browsing for certificates and keys
+var certificateExtensions = []string{".pem", ".crt", ".cer", ".key"}
+
+// TLSFormModel edits a connection's TLS settings. It is shown in place of
+// the connection form, the same way the file picker is.
+type TLSFormModel struct {
+	config         types.TLSConfig
+	serverName     textinput.Model
+	filePicker     shared.FilePickerModel
+	showFilePicker bool
+	focused        int
+	editing        bool
+	width          int
+	height         int
+}
+
+func NewTLSForm(config types.TLSConfig, host string) TLSFormModel {
+	serverName := textinput.New()
+	serverName.Placeholder = host
+	serverName.CharLimit = 255
+	serverName.Width = 40
+	serverName.SetValue(config.ServerName)
+
+	return TLSFormModel{
+		config:     config,
+		serverName: serverName,
+	}
+}
+
+// SetSize fits the panel, and the file picker it opens, into width by
+// height cells
+func (m TLSFormModel) SetSize(width, height int) TLSFormModel {
+	m.width, m.height = width, height
+	m.serverName.Width = shared.InputWidth(40, shared.FitWidth(60, width))
+	m.filePicker = m.filePicker.WithSize(width, height)
+	return m
+}
+
+// Config returns the TLS settings as currently edited
+func (m TLSFormModel) Config() types.TLSConfig {
+	config := m.config
+	config.ServerName = strings.TrimSpace(m.serverName.Value())
+	return config
+}
+
+// Capturing reports whether esc should stay inside the TLS form
+func (m TLSFormModel) Capturing() bool {
+	return m.editing || m.showFilePicker
+}
+
+func (m TLSFormModel) Update(msg tea.Msg) (TLSFormModel, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	if m.showFilePicker {
+		return m.handleFilePickerKeys(keyMsg)
+	}
+
+	if m.editing {
+		switch keyMsg.String() {
+		case "enter", "esc", "tab", "shift+tab":
+			m.editing = false
+			m.serverName.Blur()
+		default:
+			var cmd tea.Cmd
+			m.serverName, cmd = m.serverName.Update(keyMsg)
+			return m, cmd
+		}
+	}
+
+	switch keyMsg.String() {
+	case "tab", "down":
+		m.focused = (m.focused + 1) % tlsFieldCount
+	case "shift+tab", "up":
+		m.focused = (m.focused - 1 + tlsFieldCount) % tlsFieldCount
+	case "left":
+		if m.focused == tlsFieldMode && m.config.Mode > types.TLSDisable {
+			m.config.Mode--
+		}
+	case "right":
+		if m.focused == tlsFieldMode && m.config.Mode < types.TLSVerifyFull {
+			m.config.Mode++
+		}
+	case "enter":
+		return m.handleEnterKey()
+	case "backspace", "delete":
+		// Clear the focused certificate path
+		if path := m.pathField(); path != nil {
+			*path = ""
+		}
+	}
+	return m, nil
+}
+
+func (m TLSFormModel) handleEnterKey() (TLSFormModel, tea.Cmd) {
+	if m.focused == tlsFieldServerName {
+		m.editing = true
+		return m, m.serverName.Focus()
+	}
+
+	if path := m.pathField(); path != nil {
+		m.filePicker = shared.NewFilePicker().
+			WithTitle("Select "+tlsFieldName(m.focused)).
+			WithExtensions(certificateExtensions...).
+			WithSize(m.width, m.height)
+		if *path != "" {
+			m.filePicker = m.filePicker.WithDirectory(filepath.Dir(*path))
+		}
+		m.showFilePicker = true
+		return m, m.filePicker.Init()
+	}
+	return m, nil
+}
+
+func (m TLSFormModel) handleFilePickerKeys(msg tea.KeyMsg) (TLSFormModel, tea.Cmd) {
+	if msg.String() == "esc" {
+		m.showFilePicker = false
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.filePicker, cmd = m.filePicker.Update(msg)
+	if selected := m.filePicker.SelectedFile(); selected != "" {
+		*m.pathField() = selected
+		m.showFilePicker = false
+	}
+	return m, cmd
+}
+
+// pathField returns the certificate path edited by the focused field
+func (m *TLSFormModel) pathField() *string {
+	return m.pathFor(m.focused)
+}
+
+// pathFor returns the certificate path behind a field, or nil for fields
+// that aren't paths
+func (m *TLSFormModel) pathFor(field int) *string {
+	switch field {
+	case tlsFieldCA:
+		return &m.config.CAFile
+	case tlsFieldCert:
+		return &m.config.CertFile
+	case tlsFieldKey:
+		return &m.config.KeyFile
+	default:
+		return nil
+	}
+}
+
+func tlsFieldName(field int) string {
+	switch field {
+	case tlsFieldCA:
+		return "CA Certificate"
+	case tlsFieldCert:
+		return "Client Certificate"
+	case tlsFieldKey:
+		return "Client Key"
+	default:
+		return ""
+	}
+}
+
+// Describe what each mode checks, so the choice is less of a guess
+func tlsModeDescription(mode types.TLSMode) string {
+	switch mode {
+	case types.TLSDisable:
+		return "never use TLS"
+	case types.TLSPrefer:
+		return "use TLS when the server offers it"
+	case types.TLSRequire:
+		return "always encrypt, don't verify the certificate"
+	case types.TLSVerifyCA:
+		return "encrypt and verify the certificate chain"
+	case types.TLSVerifyFull:
+		return "verify the chain and the server name"
+	default:
+		return ""
+	}
+}
+
+func (m TLSFormModel) View() string {
+	if m.showFilePicker {
+		return m.filePicker.View()
+	}
+
+	var content strings.Builder
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Bold(true)
+
+	labelStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Text().Hex,
+			Dark:  catppuccin.Mocha.Text().Hex,
+		})
+
+	focusedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		})
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	content.WriteString(titleStyle.Render("TLS Settings") + "\n\n")
+
+	// Mode selector
+	modeLabel := "Mode: " + m.config.Mode.String()
+	if m.focused == tlsFieldMode {
+		modeLabel = focusedStyle.Render("> " + modeLabel + " (use ← → to change)")
+	} else {
+		modeLabel = labelStyle.Render("  " + modeLabel)
+	}
+	content.WriteString(modeLabel + "\n")
+	content.WriteString(mutedStyle.Render("  "+tlsModeDescription(m.config.Mode)) + "\n\n")
+
+	// Certificate paths
+	for _, field := range []int{tlsFieldCA, tlsFieldCert, tlsFieldKey} {
+		m.renderPathField(&content, field, focusedStyle, labelStyle)
+	}
+
+	// Server name override
+	label := "Server Name:"
+	if m.focused == tlsFieldServerName {
+		hint := "press Enter to edit"
+		if m.editing {
+			hint = "press Enter to finish editing"
+		}
+		label = focusedStyle.Render("> " + label + " (" + hint + ")")
+	} else {
+		label = labelStyle.Render("  " + label)
+	}
+	content.WriteString(label + "\n")
+	content.WriteString("  " + m.serverName.View() + "\n\n")
+
+	content.WriteString(mutedStyle.Render("Esc: back to connection"))
+
+	formStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Padding(2, 4).
+		Width(60)
+
+	return shared.Panel(formStyle, content.String(), m.width, m.height)
+}
+
+func (m TLSFormModel) renderPathField(content *strings.Builder, field int, focusedStyle, labelStyle lipgloss.Style) {
+	path := *m.pathFor(field)
+	label := tlsFieldName(field) + ": "
+	if path != "" {
+		label += path
+	} else {
+		label += "none"
+	}
+
+	if m.focused == field {
+		label = focusedStyle.Render("> " + label + " (Enter to browse, ⌫ to clear)")
+	} else {
+		label = labelStyle.Render("  " + label)
+	}
+	content.WriteString(label + "\n\n")
+}
@@ -0,0 +1,580 @@
+package root
+
+import (
+	"context"
+	"nectar/driver"
+	"nectar/keymap"
+	"nectar/styles"
+	"nectar/syntax"
+	"nectar/types"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+// treeNode is an entry of the schema tree. A group node gathers the
+// objects of its object's kind, like "Tables" in a schema or "Columns" in a
+// table. Children are loaded the first time a node is expanded.
+type treeNode struct {
+	object   driver.Object
+	group    bool
+	parent   *treeNode
+	children []*treeNode
+	expanded bool
+	loaded   bool
+	loading  bool
+}
+
+func (n *treeNode) label() string {
+	if n.group {
+		return n.object.Kind.Plural()
+	}
+	return n.object.Name
+}
+
+// treeLoadedMsg carries the children read for a node
+type treeLoadedMsg struct {
+	root     *treeNode
+	node     *treeNode
+	children []*treeNode
+	err      error
+}
+
+// treeStatusMsg reports the outcome of an action on a node
+type treeStatusMsg struct {
+	root   *treeNode
+	status string
+	err    error
+}
+
+// treeRow is a node as shown, at its depth in the tree
+type treeRow struct {
+	node  *treeNode
+	depth int
+}
+
+// SchemaTreeModel browses the objects of an open session. Nodes load
+// lazily through the driver and can be filtered by name.
+type SchemaTreeModel struct {
+	connection types.Connection
+	driver     driver.Driver
+	schema     *driver.Schema
+	root       *treeNode
+	cursor     *treeNode
+	filter     textinput.Model
+	filtering  bool
+	status     string
+	err        error
+}
+
+func NewSchemaTree(conn types.Connection, d driver.Driver, schema *driver.Schema) SchemaTreeModel {
+	filter := textinput.New()
+	filter.Placeholder = "filter"
+	filter.Prompt = "/ "
+	filter.CharLimit = 128
+
+	return SchemaTreeModel{
+		connection: conn,
+		driver:     d,
+		schema:     schema,
+		root:       &treeNode{expanded: true},
+		filter:     filter,
+	}
+}
+
+// Active reports whether the tree belongs to an open session
+func (m SchemaTreeModel) Active() bool {
+	return m.driver != nil
+}
+
+// Capturing reports whether the filter box is taking keys
+func (m SchemaTreeModel) Capturing() bool {
+	return m.filtering
+}
+
+// Bindings lists the keys the tree answers to as it stands
+func (m SchemaTreeModel) Bindings() []key.Binding {
+	if m.filtering {
+		return []key.Binding{keymap.Field.Done, keymap.Field.Cancel}
+	}
+	return []key.Binding{
+		keymap.Tree.Up, keymap.Tree.Down, keymap.Tree.Top, keymap.Tree.Bottom,
+		keymap.Tree.Expand, keymap.Tree.Collapse, keymap.Tree.Filter, keymap.Tree.Reload,
+		keymap.Tree.Open, keymap.Tree.DDL, keymap.Tree.Copy,
+	}
+}
+
+func (m SchemaTreeModel) Init() tea.Cmd {
+	return m.load(m.root)
+}
+
+// currentDatabase is the database a PostgreSQL session is in. Without one
+// set, the server picks the database named after the user.
+func currentDatabase(conn types.Connection) string {
+	switch {
+	case conn.Database != "":
+		return conn.Database
+	case conn.User != "":
+		return conn.User
+	default:
+		return "postgres"
+	}
+}
+
+// expandable reports whether a node can have children. PostgreSQL can only
+// look inside the database the session is in; the others are switched to.
+func (m SchemaTreeModel) expandable(n *treeNode) bool {
+	if n.group {
+		return true
+	}
+	switch n.object.Kind {
+	case driver.ObjectDatabase:
+		return n.object.Name == currentDatabase(m.connection)
+	case driver.ObjectSchema:
+		return true
+	default:
+		return len(driver.TableKinds(n.object.Kind)) > 0
+	}
+}
+
+// load reads a node's children in the background
+func (m SchemaTreeModel) load(node *treeNode) tea.Cmd {
+	node.loading = true
+	d, connType, root := m.driver, m.connection.Type, m.root
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), ConnectTimeout)
+		defer cancel()
+		children, err := loadChildren(ctx, d, connType, root, node)
+		return treeLoadedMsg{root: root, node: node, children: children, err: err}
+	}
+}
+
+func loadChildren(ctx context.Context, d driver.Driver, connType types.ConnectionType, root, node *treeNode) ([]*treeNode, error) {
+	var objects []driver.Object
+	var groups []driver.ObjectKind
+	obj := node.object
+
+	switch {
+	case node == root && connType == types.PostgreSQL:
+		databases, err := d.Databases(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range databases {
+			objects = append(objects, driver.Object{Kind: driver.ObjectDatabase, Name: name})
+		}
+	case node == root, obj.Kind == driver.ObjectDatabase && !node.group:
+		schemas, err := d.Schemas(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range schemas {
+			objects = append(objects, driver.Object{Kind: driver.ObjectSchema, Schema: name, Name: name})
+		}
+	case node.group:
+		var err error
+		if objects, err = d.Objects(ctx, obj.Kind, obj.Schema, obj.Table); err != nil {
+			return nil, err
+		}
+	case obj.Kind == driver.ObjectSchema:
+		groups = driver.ObjectKinds(connType)
+	default:
+		groups = driver.TableKinds(obj.Kind)
+	}
+
+	children := make([]*treeNode, 0, len(objects)+len(groups))
+	for _, o := range objects {
+		children = append(children, &treeNode{object: o, parent: node})
+	}
+	for _, kind := range groups {
+		group := driver.Object{Kind: kind, Schema: obj.Schema}
+		if obj.Kind != driver.ObjectSchema {
+			group.Table = obj.Name
+		}
+		children = append(children, &treeNode{object: group, group: true, parent: node})
+	}
+	return children, nil
+}
+
+func (m SchemaTreeModel) Update(msg tea.Msg) (SchemaTreeModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case treeLoadedMsg:
+		// Loads from a previous session's tree are dropped
+		if msg.root != m.root {
+			return m, nil
+		}
+		msg.node.loading = false
+		if msg.err != nil {
+			msg.node.expanded = false
+			m.err = msg.err
+			return m, nil
+		}
+		msg.node.children, msg.node.loaded = msg.children, true
+		if m.cursor == nil && len(msg.children) > 0 {
+			m.cursor = msg.children[0]
+		}
+		return m, nil
+	case treeStatusMsg:
+		if msg.root == m.root {
+			m.status, m.err = msg.status, msg.err
+		}
+		return m, nil
+	case tea.KeyMsg:
+		if m.filtering {
+			return m.handleFilterKeys(msg)
+		}
+		m.status, m.err = "", nil
+		return m.handleKeys(msg)
+	}
+
+	// Cursor blinks for the filter box
+	var cmd tea.Cmd
+	if m.filtering {
+		m.filter, cmd = m.filter.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m SchemaTreeModel) handleFilterKeys(msg tea.KeyMsg) (SchemaTreeModel, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keymap.Field.Cancel):
+		m.filter.SetValue("")
+		m.filtering = false
+		m.filter.Blur()
+		return m, nil
+	case key.Matches(msg, keymap.Field.Done):
+		m.filtering = false
+		m.filter.Blur()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.filter, cmd = m.filter.Update(msg)
+	return m, cmd
+}
+
+func (m SchemaTreeModel) handleKeys(msg tea.KeyMsg) (SchemaTreeModel, tea.Cmd) {
+	rows := m.rows()
+	index := m.cursorIndex(rows)
+
+	switch {
+	case key.Matches(msg, keymap.Tree.Up):
+		if index > 0 {
+			m.cursor = rows[index-1].node
+		}
+	case key.Matches(msg, keymap.Tree.Down):
+		if index < len(rows)-1 {
+			m.cursor = rows[index+1].node
+		}
+	case key.Matches(msg, keymap.Tree.Top):
+		if len(rows) > 0 {
+			m.cursor = rows[0].node
+		}
+	case key.Matches(msg, keymap.Tree.Bottom):
+		if len(rows) > 0 {
+			m.cursor = rows[len(rows)-1].node
+		}
+	case key.Matches(msg, keymap.Tree.Filter):
+		m.filtering = true
+		return m, m.filter.Focus()
+	}
+
+	node := m.cursor
+	if node == nil || index < 0 {
+		return m, nil
+	}
+
+	switch {
+	case key.Matches(msg, keymap.Tree.Expand):
+		if !m.expandable(node) {
+			if node.object.Kind == driver.ObjectDatabase && msg.String() == "enter" {
+				// Another PostgreSQL database means another session
+				conn := m.connection
+				conn.Database = node.object.Name
+				return m, func() tea.Msg { return ConnectMsg{Connection: conn} }
+			}
+			return m, nil
+		}
+		if node.expanded && msg.String() != "right" && msg.String() != "l" {
+			node.expanded = false
+			return m, nil
+		}
+		node.expanded = true
+		if !node.loaded && !node.loading {
+			return m, m.load(node)
+		}
+	case key.Matches(msg, keymap.Tree.Collapse):
+		if node.expanded {
+			node.expanded = false
+		} else if node.parent != nil && node.parent != m.root {
+			m.cursor = node.parent
+		}
+	case key.Matches(msg, keymap.Tree.Reload):
+		// Reload the node, or the list it sits in
+		target := node
+		if !m.expandable(node) || !node.loaded {
+			target = node.parent
+		}
+		target.loaded = false
+		if target.expanded || target == m.root {
+			return m, m.load(target)
+		}
+	case key.Matches(msg, keymap.Tree.Open):
+		return m, m.openData(node)
+	case key.Matches(msg, keymap.Tree.DDL):
+		return m, m.showDDL(node)
+	case key.Matches(msg, keymap.Tree.Copy):
+		return m, m.copyName(node)
+	}
+	return m, nil
+}
+
+// qualifiedName writes a table-like object's name with its schema, quoted
+// as the dialect requires
+func (m SchemaTreeModel) qualifiedName(obj driver.Object) string {
+	dialect := m.connection.Type
+	return syntax.Quote(obj.Schema, dialect) + "." + syntax.Quote(obj.Name, dialect)
+}
+
+// openData opens the data viewer on a table or view
+func (m SchemaTreeModel) openData(node *treeNode) tea.Cmd {
+	switch node.object.Kind {
+	case driver.ObjectTable, driver.ObjectView, driver.ObjectMaterializedView:
+	default:
+		return nil
+	}
+	if node.group {
+		return nil
+	}
+
+	conn, d, schema, obj := m.connection, m.driver, m.schema, node.object
+	return func() tea.Msg {
+		return OpenTableMsg{Connection: conn, Driver: d, Schema: schema, Object: obj}
+	}
+}
+
+// showDDL opens the query editor on the statement that creates the object
+func (m SchemaTreeModel) showDDL(node *treeNode) tea.Cmd {
+	if node.group {
+		return nil
+	}
+	conn, d, schema, root, obj := m.connection, m.driver, m.schema, m.root, node.object
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), ConnectTimeout)
+		defer cancel()
+		ddl, err := d.DDL(ctx, obj)
+		if err != nil {
+			return treeStatusMsg{root: root, err: err}
+		}
+		return OpenEditorMsg{Connection: conn, Driver: d, Schema: schema, Query: ddl}
+	}
+}
+
+// copyName puts the object's name on the clipboard, falling back to the
+// terminal's clipboard escape sequence where there is no system clipboard
+func (m SchemaTreeModel) copyName(node *treeNode) tea.Cmd {
+	if node.group {
+		return nil
+	}
+	name, root := node.object.Name, m.root
+	return func() tea.Msg {
+		if err := clipboard.WriteAll(name); err != nil {
+			termenv.DefaultOutput().Copy(name)
+		}
+		return treeStatusMsg{root: root, status: "Copied " + name}
+	}
+}
+
+// rows flattens the tree into what is shown. With a filter, nodes are kept
+// when their name or a loaded descendant's matches, and the path down to a
+// match is opened up.
+func (m SchemaTreeModel) rows() []treeRow {
+	filter := strings.ToLower(m.filter.Value())
+	matches := map[*treeNode]bool{}
+	var mark func(n *treeNode) bool
+	mark = func(n *treeNode) bool {
+		found := false
+		for _, child := range n.children {
+			if mark(child) {
+				found = true
+			}
+		}
+		if n != m.root && strings.Contains(strings.ToLower(n.label()), filter) {
+			found = true
+		}
+		matches[n] = found
+		return found
+	}
+	if filter != "" {
+		mark(m.root)
+	}
+
+	var rows []treeRow
+	var walk func(n *treeNode, depth int)
+	walk = func(n *treeNode, depth int) {
+		for _, child := range n.children {
+			if filter != "" && !matches[child] {
+				continue
+			}
+			rows = append(rows, treeRow{node: child, depth: depth})
+			if child.expanded || filter != "" && m.hasMatchingChild(child, matches) {
+				walk(child, depth+1)
+			}
+		}
+	}
+	walk(m.root, 0)
+	return rows
+}
+
+func (m SchemaTreeModel) hasMatchingChild(n *treeNode, matches map[*treeNode]bool) bool {
+	for _, child := range n.children {
+		if matches[child] {
+			return true
+		}
+	}
+	return false
+}
+
+// cursorIndex finds the cursor among the rows, moving it to the first row
+// when it has been filtered out
+func (m *SchemaTreeModel) cursorIndex(rows []treeRow) int {
+	for i, row := range rows {
+		if row.node == m.cursor {
+			return i
+		}
+	}
+	if len(rows) == 0 {
+		return -1
+	}
+	m.cursor = rows[0].node
+	return 0
+}
+
+func (m SchemaTreeModel) render(width, height int, focused bool) string {
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Bold(true)
+
+	groupStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Subtext0().Hex,
+			Dark:  catppuccin.Mocha.Subtext0().Hex,
+		})
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	selectedStyle := lipgloss.NewStyle().Bold(true)
+	if focused {
+		selectedStyle = selectedStyle.Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		})
+	}
+
+	var content strings.Builder
+	content.WriteString(styles.PaddedHorizontal.Render(titleStyle.Render(ansi.Truncate(m.connection.Name, width-2, "…"))) + "\n")
+	if m.filtering || m.filter.Value() != "" {
+		m.filter.Width = width - 5
+		content.WriteString(styles.PaddedHorizontal.Render(m.filter.View()) + "\n")
+	} else {
+		content.WriteString("\n")
+	}
+
+	footer := m.renderFooter(width, mutedStyle)
+	rows := m.rows()
+	index := m.cursorIndex(rows)
+
+	var lines []string
+	switch {
+	case m.root.loading:
+		lines = append(lines, styles.PaddedHorizontal.Render(mutedStyle.Render("Loading…")))
+	case len(rows) == 0 && m.filter.Value() != "":
+		lines = append(lines, styles.PaddedHorizontal.Render(mutedStyle.Render("Nothing matches")))
+	}
+	for i, row := range rows {
+		node := row.node
+		arrow := "  "
+		if m.expandable(node) {
+			arrow = "▸ "
+			if node.expanded || m.filter.Value() != "" && m.hasLoadedChildren(node) {
+				arrow = "▾ "
+			}
+		}
+
+		indent := strings.Repeat("  ", row.depth)
+		label := node.label()
+		if node.loading {
+			label += " …"
+		}
+		room := max(1, width-3-len(indent)-2)
+		label = ansi.Truncate(label, room, "…")
+
+		switch {
+		case i == index:
+			label = selectedStyle.Render(label)
+		case node.group:
+			label = groupStyle.Render(label)
+		}
+
+		detail := ""
+		if rest := room - ansi.StringWidth(node.label()) - 1; rest > 2 && node.object.Detail != "" && !node.group {
+			detail = " " + mutedStyle.Render(ansi.Truncate(node.object.Detail, rest, "…"))
+		}
+
+		marker := " "
+		if i == index {
+			marker = selectedStyle.Render(">")
+		}
+		lines = append(lines, marker+" "+indent+mutedStyle.Render(arrow)+label+detail)
+	}
+
+	// Keep the cursor inside the visible window
+	visible := max(1, height-2-lipgloss.Height(footer))
+	offset := 0
+	if index >= visible {
+		offset = index - visible + 1
+	}
+	end := min(offset+visible, len(lines))
+
+	content.WriteString(strings.Join(lines[min(offset, end):end], "\n"))
+	for range visible - (end - min(offset, end)) {
+		content.WriteString("\n")
+	}
+	content.WriteString("\n" + footer)
+	return content.String()
+}
+
+func (m SchemaTreeModel) hasLoadedChildren(n *treeNode) bool {
+	return n.loaded && len(n.children) > 0
+}
+
+func (m SchemaTreeModel) renderFooter(width int, mutedStyle lipgloss.Style) string {
+	warningStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Red().Hex,
+			Dark:  catppuccin.Mocha.Red().Hex,
+		})
+
+	switch {
+	case m.err != nil:
+		return styles.PaddedHorizontal.Width(width).Render(warningStyle.Render("✗ " + m.err.Error()))
+	case m.status != "":
+		return styles.PaddedHorizontal.Width(width).Render(mutedStyle.Render(m.status))
+	}
+	return styles.PaddedHorizontal.Width(width).Render(
+		mutedStyle.Render("/ filter  o open  d DDL  y copy  r reload  c list"),
+	)
+}
@@ -0,0 +1,109 @@
+package shared
+
+import (
+	"strings"
+	"time"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// How long work runs before the indicator appears, so that quick work
+// doesn't flash it
+const busyDelay = 300 * time.Millisecond
+
+// BusyModel shows that something is under way: what it is, how long it has
+// taken and, when that can be known, how many rows have arrived. The owner
+// does the cancelling; this only says that esc does it.
+type BusyModel struct {
+	active  bool
+	label   string
+	started time.Time
+	rows    func() int64
+	spinner spinner.Model
+}
+
+func NewBusy() BusyModel {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	return BusyModel{spinner: s}
+}
+
+// Start shows label from now on. rows, which may be nil, reports the rows
+// fetched so far.
+func (m BusyModel) Start(label string, rows func() int64) (BusyModel, tea.Cmd) {
+	m.active, m.label, m.rows = true, label, rows
+	m.started = time.Now()
+	return m, m.spinner.Tick
+}
+
+// Stop hides the indicator, which lets its spinner run down
+func (m BusyModel) Stop() BusyModel {
+	m.active = false
+	return m
+}
+
+// Active reports whether something is under way
+func (m BusyModel) Active() bool {
+	return m.active
+}
+
+// Shown reports whether the work has run long enough to show the indicator
+func (m BusyModel) Shown() bool {
+	return m.active && m.Elapsed() >= busyDelay
+}
+
+// Elapsed is how long the work has been under way
+func (m BusyModel) Elapsed() time.Duration {
+	return time.Since(m.started)
+}
+
+// Update keeps the spinner turning, which also keeps the elapsed time and
+// row count shown up to date
+func (m BusyModel) Update(msg tea.Msg) (BusyModel, tea.Cmd) {
+	if _, ok := msg.(spinner.TickMsg); !ok || !m.active {
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.spinner, cmd = m.spinner.Update(msg)
+	return m, cmd
+}
+
+// View draws the indicator as a framed box no wider than width
+func (m BusyModel) View(width int) string {
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Padding(0, 2)
+
+	spinnerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		})
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	inner := max(10, width-6)
+	detail := m.Elapsed().Round(100 * time.Millisecond).String()
+	if m.rows != nil {
+		detail = rowCount(m.rows()) + " so far · " + detail
+	}
+
+	lines := []string{
+		ansi.Truncate(spinnerStyle.Render(m.spinner.View())+" "+m.label, inner, "…"),
+		ansi.Truncate(mutedStyle.Render(detail), inner, "…"),
+		mutedStyle.Render("esc: cancel"),
+	}
+	return boxStyle.Render(strings.Join(lines, "\n"))
+}
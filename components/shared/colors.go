@@ -1,6 +1,8 @@
 package shared
 
 import (
+	"nectar/types"
+
 	catppuccin "github.com/catppuccin/go"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -10,47 +12,40 @@ type ColorOption struct {
 	Color lipgloss.AdaptiveColor
 }
 
-var ConnectionColors = []ColorOption{
-	{
-		Name: "Red",
-		Color: lipgloss.AdaptiveColor{
-			Light: catppuccin.Latte.Red().Hex,
-			Dark:  catppuccin.Mocha.Red().Hex,
-		},
-	},
-	{
-		Name: "Green",
-		Color: lipgloss.AdaptiveColor{
-			Light: catppuccin.Latte.Green().Hex,
-			Dark:  catppuccin.Mocha.Green().Hex,
-		},
-	},
-	{
-		Name: "Blue",
-		Color: lipgloss.AdaptiveColor{
-			Light: catppuccin.Latte.Blue().Hex,
-			Dark:  catppuccin.Mocha.Blue().Hex,
-		},
-	},
-	{
-		Name: "Yellow",
-		Color: lipgloss.AdaptiveColor{
-			Light: catppuccin.Latte.Yellow().Hex,
-			Dark:  catppuccin.Mocha.Yellow().Hex,
-		},
-	},
-	{
-		Name: "Mauve",
-		Color: lipgloss.AdaptiveColor{
-			Light: catppuccin.Latte.Mauve().Hex,
-			Dark:  catppuccin.Mocha.Mauve().Hex,
-		},
-	},
-	{
-		Name: "Teal",
-		Color: lipgloss.AdaptiveColor{
-			Light: catppuccin.Latte.Teal().Hex,
-			Dark:  catppuccin.Mocha.Teal().Hex,
-		},
-	},
+// palette maps each of types.ConnectionColors to its catppuccin color
+var palette = map[string]func(catppuccin.Flavor) catppuccin.Color{
+	"Red":    catppuccin.Flavor.Red,
+	"Green":  catppuccin.Flavor.Green,
+	"Blue":   catppuccin.Flavor.Blue,
+	"Yellow": catppuccin.Flavor.Yellow,
+	"Mauve":  catppuccin.Flavor.Mauve,
+	"Teal":   catppuccin.Flavor.Teal,
+}
+
+var ConnectionColors = colorOptions(types.ConnectionColors)
+
+func colorOptions(names []string) []ColorOption {
+	options := make([]ColorOption, len(names))
+	for i, name := range names {
+		color := palette[name]
+		options[i] = ColorOption{
+			Name: name,
+			Color: lipgloss.AdaptiveColor{
+				Light: color(catppuccin.Latte).Hex,
+				Dark:  color(catppuccin.Mocha).Hex,
+			},
+		}
+	}
+	return options
+}
+
+// ColorIndex returns the position of the named color in ConnectionColors,
+// falling back to the first color for unknown names
+func ColorIndex(name string) int {
+	for i, option := range ConnectionColors {
+		if option.Name == name {
+			return i
+		}
+	}
+	return 0
 }
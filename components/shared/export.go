@@ -0,0 +1,456 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nectar/driver"
+	"nectar/export"
+	"nectar/types"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// exportDoneMsg carries the outcome of an export back to the dialog
+type exportDoneMsg struct {
+	id   int
+	rows int64
+	err  error
+}
+
+// The steps of the export dialog
+type exportStage int
+
+const (
+	exportOptions exportStage = iota
+	exportPicking
+	exportRunning
+	exportDone
+)
+
+// The fields of the options step, top to bottom
+const (
+	exportFieldSource = iota
+	exportFieldFormat
+	exportFieldQuoting
+	exportFieldHeader
+	exportFieldTable
+)
+
+// ExportModel writes a result to a file. It asks for the format, then for
+// the file, then runs the statement behind the result again and streams
+// every row it returns, not only those on screen. A result that can't
+// safely be read again is written out from the rows already read, unless
+// the user chooses to run its statement again.
+type ExportModel struct {
+	visible bool
+	stage   exportStage
+	field   int
+
+	driver driver.Driver
+	query  string
+	args   []any
+	// result is written instead of running query when it is set, unless
+	// rerun is chosen
+	result  *driver.Result
+	rerun   bool
+	name    string
+	options export.Options
+	table   textinput.Model
+	picker  FilePickerModel
+	// dir is where the last file was written, where the next one starts
+	dir string
+	// The space the dialog is drawn in
+	width  int
+	height int
+
+	runID    int
+	cancel   context.CancelFunc
+	progress *export.Progress
+	busy     BusyModel
+	path     string
+	rows     int64
+	duration time.Duration
+	err      error
+}
+
+func NewExport() ExportModel {
+	table := textinput.New()
+	table.Prompt = ""
+	table.Placeholder = "table to insert into"
+	table.CharLimit = 256
+	table.Width = 40
+
+	return ExportModel{table: table, busy: NewBusy()}
+}
+
+// Open shows the dialog for the rows query returns. name is the file name
+// suggested, without extension, and table the quoted table INSERT
+// statements write to, which may be left for the user to fill in.
+func (m ExportModel) Open(d driver.Driver, dialect types.ConnectionType, query string, args []any, name, table string) ExportModel {
+	m.visible, m.stage, m.field = true, exportOptions, exportFieldFormat
+	m.driver, m.query, m.args, m.name = d, query, args, name
+	m.result, m.rerun = nil, false
+	m.options.Dialect = dialect
+	m.table.SetValue(table)
+	m.table.Blur()
+	m.err = nil
+	return m
+}
+
+// OpenResult shows the dialog for rows that were already read, which are
+// written as they are rather than fetched again
+func (m ExportModel) OpenResult(result *driver.Result, dialect types.ConnectionType, name, table string) ExportModel {
+	m = m.Open(nil, dialect, "", nil, name, table)
+	m.result = result
+	return m
+}
+
+// OfferRerun lets the user of a dialog opened with OpenResult export every
+// row query returns instead, by running it again. The rows already read stay
+// the default, since running the query again repeats any side effects.
+func (m ExportModel) OfferRerun(d driver.Driver, query string, args []any) ExportModel {
+	m.driver, m.query, m.args = d, query, args
+	m.field = exportFieldSource
+	return m
+}
+
+// canRerun reports whether the user may choose between the rows already
+// read and running the query again
+func (m ExportModel) canRerun() bool {
+	return m.result != nil && m.query != ""
+}
+
+// SetSize fits the dialog into width by height cells
+func (m ExportModel) SetSize(width, height int) ExportModel {
+	m.width, m.height = width, height
+	m.table.Width = InputWidth(40, FitWidth(64, width))
+	m.picker = m.picker.WithSize(width, height)
+	return m
+}
+
+// Visible reports whether the dialog is open
+func (m ExportModel) Visible() bool {
+	return m.visible
+}
+
+// Close cancels any export still running
+func (m ExportModel) Close() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+}
+
+// fields lists the options that apply to the chosen format
+func (m ExportModel) fields() []int {
+	var fields []int
+	if m.canRerun() {
+		fields = append(fields, exportFieldSource)
+	}
+	switch {
+	case m.options.Format.Delimited():
+		return append(fields, exportFieldFormat, exportFieldQuoting, exportFieldHeader)
+	case m.options.Format == export.Insert:
+		return append(fields, exportFieldFormat, exportFieldTable)
+	default:
+		return append(fields, exportFieldFormat)
+	}
+}
+
+func (m ExportModel) Update(msg tea.Msg) (ExportModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case exportDoneMsg:
+		// An export that was cancelled has already said so
+		if msg.id != m.runID {
+			return m, nil
+		}
+		m.stage, m.cancel = exportDone, nil
+		m.rows, m.err = msg.rows, msg.err
+		m.duration = m.busy.Elapsed()
+		m.busy = m.busy.Stop()
+		return m, nil
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.busy, cmd = m.busy.Update(msg)
+		return m, cmd
+	case tea.KeyMsg:
+		switch m.stage {
+		case exportOptions:
+			return m.updateOptions(msg)
+		case exportPicking:
+			return m.updatePicker(msg)
+		case exportRunning:
+			if msg.String() == "esc" {
+				m.cancel()
+				m.runID++
+				m.stage, m.cancel = exportDone, nil
+				m.rows, m.err = m.progress.Rows(), context.Canceled
+				m.duration = m.busy.Elapsed()
+				m.busy = m.busy.Stop()
+			}
+			return m, nil
+		case exportDone:
+			switch msg.String() {
+			case "esc", "enter", "q":
+				m.visible = false
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	switch m.stage {
+	case exportOptions:
+		m.table, cmd = m.table.Update(msg)
+	case exportPicking:
+		m.picker, cmd = m.picker.Update(msg)
+	}
+	return m, cmd
+}
+
+// updateOptions moves between the options and changes them. Enter goes on
+// to picking the file.
+func (m ExportModel) updateOptions(msg tea.KeyMsg) (ExportModel, tea.Cmd) {
+	fields := m.fields()
+	at := max(0, slices.Index(fields, m.field))
+
+	switch msg.String() {
+	case "esc":
+		m.visible = false
+		m.table.Blur()
+		return m, nil
+	case "up", "shift+tab":
+		return m.focus(fields[(at+len(fields)-1)%len(fields)])
+	case "down", "tab":
+		return m.focus(fields[(at+1)%len(fields)])
+	case "enter":
+		m.options.Table = strings.TrimSpace(m.table.Value())
+		if m.options.Format == export.Insert && m.options.Table == "" {
+			m.err = errors.New("a table name is needed for INSERT statements")
+			return m.focus(exportFieldTable)
+		}
+		m.err = nil
+		m.table.Blur()
+		m.stage = exportPicking
+		ext := m.options.Format.Extension()
+		m.picker = NewFilePicker().
+			WithTitle("Export "+m.options.Format.String()+" to").
+			WithExtensions(ext).
+			WithSaveName(m.name+ext).
+			WithSize(m.width, m.height)
+		if m.dir != "" {
+			m.picker = m.picker.WithDirectory(m.dir)
+		} else if wd, err := os.Getwd(); err == nil {
+			m.picker = m.picker.WithDirectory(wd)
+		}
+		return m, m.picker.Init()
+	}
+
+	step := 0
+	switch msg.String() {
+	case "left":
+		step = -1
+	case "right", " ":
+		step = 1
+	}
+	if m.field == exportFieldTable || step == 0 {
+		var cmd tea.Cmd
+		m.table, cmd = m.table.Update(msg)
+		return m, cmd
+	}
+
+	switch m.field {
+	case exportFieldSource:
+		m.rerun = !m.rerun
+	case exportFieldFormat:
+		m.options.Format = cycle(export.Formats, m.options.Format, step)
+	case exportFieldQuoting:
+		m.options.Quoting = cycle(export.Quotings, m.options.Quoting, step)
+	case exportFieldHeader:
+		m.options.NoHeader = !m.options.NoHeader
+	}
+	return m, nil
+}
+
+// focus moves to an option, giving the table name the cursor when it's
+// the one chosen
+func (m ExportModel) focus(field int) (ExportModel, tea.Cmd) {
+	m.field = field
+	if field == exportFieldTable {
+		return m, m.table.Focus()
+	}
+	m.table.Blur()
+	return m, nil
+}
+
+// cycle steps through values, wrapping round at either end
+func cycle[T comparable](values []T, current T, step int) T {
+	i := max(0, slices.Index(values, current))
+	return values[(i+step+len(values))%len(values)]
+}
+
+// updatePicker drives the file picker; once a file is chosen the export
+// starts. esc goes back to the options.
+func (m ExportModel) updatePicker(msg tea.KeyMsg) (ExportModel, tea.Cmd) {
+	if msg.String() == "esc" {
+		m.stage = exportOptions
+		return m.focus(m.field)
+	}
+
+	var cmd tea.Cmd
+	m.picker, cmd = m.picker.Update(msg)
+	if path := m.picker.SelectedFile(); path != "" {
+		return m.start(path)
+	}
+	return m, cmd
+}
+
+// start runs the export in the background
+func (m ExportModel) start(path string) (ExportModel, tea.Cmd) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.runID++
+	m.stage, m.cancel = exportRunning, cancel
+	m.path, m.dir = path, m.picker.currentDir
+	m.progress = &export.Progress{}
+	m.rows, m.err = 0, nil
+	var tick tea.Cmd
+	m.busy, tick = m.busy.Start("Writing "+path, m.progress.Rows)
+
+	id, d, query, args, result, options, progress := m.runID, m.driver, m.query, m.args, m.result, m.options, m.progress
+	if m.rerun {
+		result = nil
+	}
+	return m, tea.Batch(tick, func() tea.Msg {
+		defer cancel()
+		if result != nil {
+			rows, err := export.ResultToFile(ctx, result, path, options, progress)
+			return exportDoneMsg{id: id, rows: rows, err: err}
+		}
+		rows, err := export.ToFile(ctx, d, query, args, path, options, progress)
+		return exportDoneMsg{id: id, rows: rows, err: err}
+	})
+}
+
+func (m ExportModel) View() string {
+	switch m.stage {
+	case exportPicking:
+		return m.picker.View()
+	case exportRunning:
+		return m.busy.View(FitWidth(68, m.width))
+	}
+
+	containerStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Padding(1, 2).
+		Width(64)
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Bold(true)
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Red().Hex,
+			Dark:  catppuccin.Mocha.Red().Hex,
+		})
+
+	successStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Green().Hex,
+			Dark:  catppuccin.Mocha.Green().Hex,
+		})
+
+	focusedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		})
+
+	var content strings.Builder
+	content.WriteString(titleStyle.Render("Export Result") + "\n\n")
+
+	switch m.stage {
+	case exportOptions:
+		for _, field := range m.fields() {
+			label, value := m.describe(field)
+			line := fmt.Sprintf("%-9s %s", label+":", value)
+			if field == m.field {
+				content.WriteString(focusedStyle.Render("> "+line) + "\n")
+			} else {
+				content.WriteString("  " + line + "\n")
+			}
+		}
+		if m.rerun {
+			content.WriteString("\n" + mutedStyle.Render("The statement runs again, repeating anything else it does") + "\n")
+		}
+		if m.err != nil {
+			content.WriteString("\n" + errorStyle.Render("✗ "+m.err.Error()) + "\n")
+		}
+		content.WriteString("\n" + mutedStyle.Render("↑/↓: option, ←/→: change, Enter: choose file, Esc: cancel"))
+	case exportDone:
+		took := m.duration.Round(time.Millisecond)
+		switch {
+		case errors.Is(m.err, context.Canceled):
+			content.WriteString(errorStyle.Render("✗ Cancelled") + " after " + rowCount(m.rows) + "; " + m.path + " was left as it was\n")
+		case m.err != nil:
+			content.WriteString(errorStyle.Render("✗ "+m.err.Error()) + "\n")
+		default:
+			content.WriteString(successStyle.Render("✓ Wrote "+rowCount(m.rows)) + " to " + m.path + " in " + took.String() + "\n")
+		}
+		content.WriteString("\n" + mutedStyle.Render("Enter: close"))
+	}
+	return Panel(containerStyle, content.String(), m.width, m.height)
+}
+
+// describe gives an option's label and its current value
+func (m ExportModel) describe(field int) (string, string) {
+	switch field {
+	case exportFieldSource:
+		if m.rerun {
+			return "Rows", "‹ all, run again ›"
+		}
+		return "Rows", "‹ as read ›"
+	case exportFieldFormat:
+		return "Format", "‹ " + m.options.Format.String() + " ›"
+	case exportFieldQuoting:
+		return "Quote", "‹ " + m.options.Quoting.String() + " ›"
+	case exportFieldHeader:
+		if m.options.NoHeader {
+			return "Header", "‹ off ›"
+		}
+		return "Header", "‹ on ›"
+	case exportFieldTable:
+		return "Table", m.table.View()
+	default:
+		return "", ""
+	}
+}
+
+// rowCount says how many rows, e.g. "1 row" or "12 rows"
+func rowCount(n int64) string {
+	if n == 1 {
+		return "1 row"
+	}
+	return fmt.Sprintf("%d rows", n)
+}
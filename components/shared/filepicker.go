@@ -7,21 +7,38 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 )
 
 const (
 	MaxVisibleFiles = 10
 )
 
+// Extensions shown by default, for picking SQLite database files
+var SQLiteExtensions = []string{".db", ".sqlite", ".sqlite3"}
+
 type FilePickerModel struct {
+	title        string
+	extensions   []string
 	currentDir   string
 	files        []fs.DirEntry
 	selected     int
 	selectedFile string
 	err          error
 	scrollOffset int
+
+	// saving picks a file to write: the name is typed below the list,
+	// which tab moves focus to and from
+	saving      bool
+	name        textinput.Model
+	nameFocused bool
+
+	// The space the picker is drawn in, unknown while zero
+	width  int
+	height int
 }
 
 func NewFilePicker() FilePickerModel {
@@ -31,6 +48,8 @@ func NewFilePicker() FilePickerModel {
 	}
 
 	fp := FilePickerModel{
+		title:        "Select SQLite Database File",
+		extensions:   SQLiteExtensions,
 		currentDir:   homeDir,
 		selected:     0,
 		scrollOffset: 0,
@@ -39,6 +58,63 @@ func NewFilePicker() FilePickerModel {
 	return fp
 }
 
+// WithTitle sets the heading shown above the file list
+func (m FilePickerModel) WithTitle(title string) FilePickerModel {
+	m.title = title
+	return m
+}
+
+// WithExtensions limits the listed files to the given extensions; with none
+// every file is shown
+func (m FilePickerModel) WithExtensions(extensions ...string) FilePickerModel {
+	m.extensions = extensions
+	m.loadDirectory()
+	return m
+}
+
+// WithDirectory starts browsing from dir instead of the home directory
+func (m FilePickerModel) WithDirectory(dir string) FilePickerModel {
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		m.currentDir = dir
+		m.loadDirectory()
+	}
+	return m
+}
+
+// WithSize fits the picker into width by height cells
+func (m FilePickerModel) WithSize(width, height int) FilePickerModel {
+	m.width, m.height = width, height
+	m.name.Width = InputWidth(60, FitWidth(80, width))
+	return m
+}
+
+// WithSaveName makes the picker choose a file to write rather than one to
+// open, suggesting name for it. The file may not exist yet.
+func (m FilePickerModel) WithSaveName(name string) FilePickerModel {
+	m.saving = true
+	m.name = textinput.New()
+	m.name.Prompt = "Name: "
+	m.name.CharLimit = 255
+	m.name.Width = InputWidth(60, FitWidth(80, m.width))
+	m.name.SetValue(name)
+	m.name.Focus()
+	m.nameFocused = true
+	return m
+}
+
+// matches reports whether a file name passes the extension filter
+func (m FilePickerModel) matches(name string) bool {
+	if len(m.extensions) == 0 {
+		return true
+	}
+	for _, ext := range m.extensions {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *FilePickerModel) loadDirectory() {
 	files, err := os.ReadDir(m.currentDir)
 	if err != nil {
@@ -56,13 +132,9 @@ func (m *FilePickerModel) loadDirectory() {
 		filteredFiles = append(filteredFiles, &parentDirEntry{})
 	}
 
-	// Add directories and SQLite files
+	// Add directories and files matching the extension filter
 	for _, file := range files {
-		if file.IsDir() {
-			filteredFiles = append(filteredFiles, file)
-		} else if strings.HasSuffix(strings.ToLower(file.Name()), ".db") ||
-			strings.HasSuffix(strings.ToLower(file.Name()), ".sqlite") ||
-			strings.HasSuffix(strings.ToLower(file.Name()), ".sqlite3") {
+		if file.IsDir() || m.matches(file.Name()) {
 			filteredFiles = append(filteredFiles, file)
 		}
 	}
@@ -102,10 +174,27 @@ func (p *parentDirEntry) Type() fs.FileMode          { return fs.ModeDir }
 func (p *parentDirEntry) Info() (fs.FileInfo, error) { return nil, nil }
 
 func (m FilePickerModel) Init() tea.Cmd {
+	if m.saving {
+		return textinput.Blink
+	}
 	return nil
 }
 
 func (m FilePickerModel) Update(msg tea.Msg) (FilePickerModel, tea.Cmd) {
+	if m.saving {
+		if keyMsg, ok := msg.(tea.KeyMsg); ok && keyMsg.String() == "tab" {
+			m.nameFocused = !m.nameFocused
+			if m.nameFocused {
+				return m, m.name.Focus()
+			}
+			m.name.Blur()
+			return m, nil
+		}
+		if m.nameFocused {
+			return m.updateName(msg)
+		}
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
@@ -139,8 +228,14 @@ func (m FilePickerModel) Update(msg tea.Msg) (FilePickerModel, tea.Cmd) {
 					m.currentDir = filepath.Join(m.currentDir, selectedFile.Name())
 				}
 				m.loadDirectory()
+			} else if m.saving {
+				// Write over the file, once its name is confirmed
+				m.name.SetValue(selectedFile.Name())
+				m.name.CursorEnd()
+				m.nameFocused = true
+				return m, m.name.Focus()
 			} else {
-				// Select the SQLite file
+				// Select the file
 				m.selectedFile = filepath.Join(m.currentDir, selectedFile.Name())
 			}
 		}
@@ -148,6 +243,40 @@ func (m FilePickerModel) Update(msg tea.Msg) (FilePickerModel, tea.Cmd) {
 	return m, nil
 }
 
+// updateName edits the name of the file to write. Enter picks it, or opens
+// it when it names a directory.
+func (m FilePickerModel) updateName(msg tea.Msg) (FilePickerModel, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && keyMsg.String() == "enter" {
+		name := strings.TrimSpace(m.name.Value())
+		if name == "" {
+			return m, nil
+		}
+		path := m.savePath()
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			m.currentDir = path
+			m.name.SetValue("")
+			m.loadDirectory()
+			return m, nil
+		}
+		m.selectedFile = path
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.name, cmd = m.name.Update(msg)
+	return m, cmd
+}
+
+// savePath is where the typed name points: relative names are taken from
+// the directory being browsed
+func (m FilePickerModel) savePath() string {
+	name := strings.TrimSpace(m.name.Value())
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(m.currentDir, name)
+}
+
 func (m FilePickerModel) View() string {
 	// Fixed height container to prevent UI pushing
 	containerStyle := lipgloss.NewStyle().
@@ -155,24 +284,27 @@ func (m FilePickerModel) View() string {
 		Padding(1, 2).
 		Width(80).
 		Height(MaxVisibleFiles + 8) // Fixed height: header + files + help + padding
+	if m.saving {
+		containerStyle = containerStyle.Height(MaxVisibleFiles + 9)
+	}
 
 	if m.err != nil {
-		errorContent := "Select SQLite Database File\n\nError: " + m.err.Error()
-		return containerStyle.Render(errorContent)
+		errorContent := m.title + "\n\nError: " + m.err.Error()
+		return Panel(containerStyle, errorContent, m.width, m.height)
 	}
 
 	var content strings.Builder
 
 	// Header section
 	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
-	content.WriteString(headerStyle.Render("Select SQLite Database File"))
+	content.WriteString(headerStyle.Render(m.title))
 	content.WriteString("\n")
 	content.WriteString("Current: " + filepath.Base(m.currentDir))
 	content.WriteString("\n\n")
 
 	// Files section with fixed height
 	if len(m.files) == 0 {
-		content.WriteString("No SQLite files found in this directory")
+		content.WriteString("No matching files found in this directory")
 		// Add padding to maintain consistent height
 		for i := 0; i < MaxVisibleFiles-1; i++ {
 			content.WriteString("\n")
@@ -193,6 +325,9 @@ func (m FilePickerModel) View() string {
 			} else {
 				line = "📄 " + file.Name()
 			}
+			// Long names are cut rather than wrapped, which would push the
+			// list out of its box
+			line = ansi.Truncate(line, FitWidth(80, m.width)-8, "…")
 
 			if i == m.selected {
 				selectedStyle := lipgloss.NewStyle().
@@ -215,9 +350,24 @@ func (m FilePickerModel) View() string {
 	// Footer with navigation help
 	helpStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
 	help := helpStyle.Render("↑/↓: navigate, Enter: select/open, Esc: cancel")
+	if m.saving {
+		content.WriteString("\n" + m.name.View())
+		if name := strings.TrimSpace(m.name.Value()); name != "" {
+			if info, err := os.Stat(m.savePath()); err == nil && !info.IsDir() {
+				warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+				content.WriteString(warnStyle.Render("  (replaces existing file)"))
+			}
+		}
+		content.WriteString("\n")
+		if m.nameFocused {
+			help = helpStyle.Render("Enter: save here, Tab: browse files, Esc: cancel")
+		} else {
+			help = helpStyle.Render("↑/↓: navigate, Enter: open/use name, Tab: edit name, Esc: cancel")
+		}
+	}
 	content.WriteString("\n" + help)
 
-	return containerStyle.Render(content.String())
+	return Panel(containerStyle, content.String(), m.width, m.height)
 }
 
 func min(a, b int) int {
@@ -0,0 +1,379 @@
+package shared
+
+import (
+	"fmt"
+	"nectar/driver"
+	"strings"
+
+	catppuccin "github.com/catppuccin/go"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	// Widest a column gets before its values are truncated
+	maxGridColumnWidth = 40
+	// Rows looked at when sizing columns, so huge results size instantly
+	gridWidthSample = 1000
+)
+
+// CellMark highlights a cell, such as one holding a change not yet saved
+type CellMark int
+
+const (
+	MarkNone CellMark = iota
+	MarkChanged
+	MarkInserted
+	MarkDeleted
+)
+
+// GridModel shows a result set as a table with a sticky header and a cell
+// cursor. Only the rows and columns in view are formatted and drawn, so it
+// stays quick on results of any size.
+type GridModel struct {
+	columns []string
+	rows    [][]any
+	widths  []int
+	row     int
+	col     int
+	top     int
+	left    int
+	width   int
+	height  int
+	focused bool
+	marks   func(row, col int) CellMark
+}
+
+func NewGrid() GridModel {
+	return GridModel{width: 80, height: 10}
+}
+
+// SetResult replaces the grid's contents and moves the cursor to the start
+func (m GridModel) SetResult(result *driver.Result) GridModel {
+	m.columns, m.rows = nil, nil
+	if result != nil {
+		m.columns, m.rows = result.Columns, result.Rows
+	}
+	m.row, m.col, m.top, m.left = 0, 0, 0, 0
+	m.widths = columnWidths(m.columns, m.rows)
+	return m
+}
+
+// ReplaceResult swaps in new contents of much the same shape, such as the
+// same rows after an edit, keeping the cursor where it is
+func (m GridModel) ReplaceResult(result *driver.Result) GridModel {
+	row, col, top, left := m.row, m.col, m.top, m.left
+	m = m.SetResult(result)
+	m.row, m.col = max(0, min(row, len(m.rows)-1)), max(0, min(col, len(m.columns)-1))
+	m.top, m.left = min(top, m.row), min(left, m.col)
+	m.scrollToCursor()
+	return m
+}
+
+// SetMarks sets what highlights each cell; nil marks none
+func (m GridModel) SetMarks(marks func(row, col int) CellMark) GridModel {
+	m.marks = marks
+	return m
+}
+
+// SetSize sets the area the grid renders into, header and footer included
+func (m GridModel) SetSize(width, height int) GridModel {
+	m.width, m.height = max(width, 10), max(height, 3)
+	m.scrollToCursor()
+	return m
+}
+
+func (m GridModel) Focus() GridModel {
+	m.focused = true
+	return m
+}
+
+func (m GridModel) Blur() GridModel {
+	m.focused = false
+	return m
+}
+
+func (m GridModel) Focused() bool {
+	return m.focused
+}
+
+// Empty reports whether there is nothing to show
+func (m GridModel) Empty() bool {
+	return len(m.columns) == 0
+}
+
+// Cursor returns the row and column of the selected cell
+func (m GridModel) Cursor() (row, col int) {
+	return m.row, m.col
+}
+
+// SetCursor moves the cursor to a cell, as near as the grid allows
+func (m GridModel) SetCursor(row, col int) GridModel {
+	m.row = max(0, min(row, len(m.rows)-1))
+	m.col = max(0, min(col, len(m.columns)-1))
+	m.scrollToCursor()
+	return m
+}
+
+// Value returns the value in the selected cell
+func (m GridModel) Value() (any, bool) {
+	if m.row >= len(m.rows) || m.col >= len(m.columns) {
+		return nil, false
+	}
+	return m.rows[m.row][m.col], true
+}
+
+// columnWidths sizes each column to its header and widest sampled value
+func columnWidths(columns []string, rows [][]any) []int {
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = max(4, ansi.StringWidth(column))
+	}
+	for _, row := range rows[:min(len(rows), gridWidthSample)] {
+		for i, value := range row {
+			text, _ := driver.FormatValue(value)
+			widths[i] = max(widths[i], ansi.StringWidth(text))
+		}
+	}
+	for i := range widths {
+		widths[i] = min(widths[i], maxGridColumnWidth)
+	}
+	return widths
+}
+
+// Rows of data that fit between the header and the footer
+func (m GridModel) pageSize() int {
+	return max(1, m.height-2)
+}
+
+func (m GridModel) Update(msg tea.Msg) (GridModel, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok || !m.focused || m.Empty() {
+		return m, nil
+	}
+
+	switch keyMsg.String() {
+	case "up", "k":
+		m.row--
+	case "down", "j":
+		m.row++
+	case "left", "h":
+		m.col--
+	case "right", "l":
+		m.col++
+	case "pgup", "ctrl+b":
+		m.row -= m.pageSize()
+	case "pgdown", "ctrl+f":
+		m.row += m.pageSize()
+	case "home", "0":
+		m.col = 0
+	case "end", "$":
+		m.col = len(m.columns) - 1
+	case "g", "ctrl+home":
+		m.row = 0
+	case "G", "ctrl+end":
+		m.row = len(m.rows) - 1
+	default:
+		return m, nil
+	}
+
+	m.row = max(0, min(m.row, len(m.rows)-1))
+	m.col = max(0, min(m.col, len(m.columns)-1))
+	m.scrollToCursor()
+	return m, nil
+}
+
+// scrollToCursor moves the window so the selected cell is in view
+func (m *GridModel) scrollToCursor() {
+	if m.row < m.top {
+		m.top = m.row
+	}
+	if m.row >= m.top+m.pageSize() {
+		m.top = m.row - m.pageSize() + 1
+	}
+	m.widenVisible()
+
+	if m.col < m.left {
+		m.left = m.col
+	}
+	for m.left < m.col && m.lastVisibleColumn() < m.col {
+		m.left++
+	}
+}
+
+// widenVisible grows columns to fit the rows in view, for values wider
+// than any in the sample taken when the result was set
+func (m *GridModel) widenVisible() {
+	for _, row := range m.rows[m.top:min(m.top+m.pageSize(), len(m.rows))] {
+		for i, value := range row {
+			text, _ := driver.FormatValue(value)
+			m.widths[i] = max(m.widths[i], min(ansi.StringWidth(text), maxGridColumnWidth))
+		}
+	}
+}
+
+// lastVisibleColumn returns the last column drawn in full from m.left
+func (m GridModel) lastVisibleColumn() int {
+	used := 0
+	for i := m.left; i < len(m.widths); i++ {
+		used += m.widths[i] + 3
+		if used > m.width {
+			return max(m.left, i-1)
+		}
+	}
+	return len(m.widths) - 1
+}
+
+func (m GridModel) View() string {
+	if m.Empty() {
+		return ""
+	}
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Bold(true)
+
+	borderStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Surface2().Hex,
+			Dark:  catppuccin.Mocha.Surface2().Hex,
+		})
+
+	nullStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay0().Hex,
+			Dark:  catppuccin.Mocha.Overlay0().Hex,
+		}).
+		Italic(true)
+
+	rowStyle := lipgloss.NewStyle().
+		Background(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Surface0().Hex,
+			Dark:  catppuccin.Mocha.Surface0().Hex,
+		})
+
+	cursorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Base().Hex,
+			Dark:  catppuccin.Mocha.Base().Hex,
+		}).
+		Background(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		})
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	last := min(m.lastVisibleColumn()+1, len(m.columns))
+	separator := borderStyle.Render("│")
+
+	// Columns past the last one that fits are drawn partly, then cut off
+	end := last
+	if end < len(m.columns) {
+		end++
+	}
+
+	var lines []string
+	var header []string
+	for i := m.left; i < end; i++ {
+		header = append(header, headerStyle.Render(" "+fitCell(m.columns[i], m.widths[i])+" "))
+	}
+	lines = append(lines, strings.Join(header, separator))
+
+	bottom := min(m.top+m.pageSize(), len(m.rows))
+	for r := m.top; r < bottom; r++ {
+		var cells []string
+		for i := m.left; i < end; i++ {
+			text, null := driver.FormatValue(m.rows[r][i])
+			cell := " " + fitCell(text, m.widths[i]) + " "
+			style, marked := lipgloss.NewStyle(), false
+			if m.marks != nil {
+				style, marked = markStyle(m.marks(r, i))
+			}
+			switch {
+			case m.focused && r == m.row && i == m.col:
+				cell = cursorStyle.Render(cell)
+			case marked && r == m.row && m.focused:
+				cell = style.Inherit(rowStyle).Render(cell)
+			case marked:
+				cell = style.Render(cell)
+			case null && r == m.row && m.focused:
+				cell = nullStyle.Inherit(rowStyle).Render(cell)
+			case null:
+				cell = nullStyle.Render(cell)
+			case r == m.row && m.focused:
+				cell = rowStyle.Render(cell)
+			}
+			cells = append(cells, cell)
+		}
+		lines = append(lines, strings.Join(cells, separator))
+	}
+
+	for i, line := range lines {
+		lines[i] = ansi.Truncate(line, m.width, "")
+	}
+	for len(lines) < m.height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, mutedStyle.Render(ansi.Truncate(m.footer(), m.width, "…")))
+	return strings.Join(lines, "\n")
+}
+
+// markStyle returns how a marked cell is drawn, and false for no mark
+func markStyle(mark CellMark) (lipgloss.Style, bool) {
+	switch mark {
+	case MarkChanged:
+		return lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{
+				Light: catppuccin.Latte.Peach().Hex,
+				Dark:  catppuccin.Mocha.Peach().Hex,
+			}).
+			Bold(true), true
+	case MarkInserted:
+		return lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{
+				Light: catppuccin.Latte.Green().Hex,
+				Dark:  catppuccin.Mocha.Green().Hex,
+			}), true
+	case MarkDeleted:
+		return lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{
+				Light: catppuccin.Latte.Red().Hex,
+				Dark:  catppuccin.Mocha.Red().Hex,
+			}).
+			Strikethrough(true), true
+	default:
+		return lipgloss.NewStyle(), false
+	}
+}
+
+// footer reports the cursor position and the full value of the selected
+// cell, which the grid itself may have had to truncate
+func (m GridModel) footer() string {
+	if len(m.rows) == 0 {
+		return "No rows"
+	}
+	position := fmt.Sprintf("row %d/%d  col %d/%d", m.row+1, len(m.rows), m.col+1, len(m.columns))
+	if !m.focused {
+		return position
+	}
+	value, _ := m.Value()
+	text, _ := driver.FormatValue(value)
+	return position + "  " + m.columns[m.col] + ": " + strings.ReplaceAll(text, "\n", "⏎")
+}
+
+// fitCell pads or truncates text to exactly width cells, keeping values on
+// one line
+func fitCell(text string, width int) string {
+	text = strings.NewReplacer("\n", "⏎", "\r", "", "\t", " ").Replace(text)
+	text = ansi.Truncate(text, width, "…")
+	return text + strings.Repeat(" ", max(0, width-ansi.StringWidth(text)))
+}
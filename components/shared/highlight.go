@@ -0,0 +1,17 @@
+package shared
+
+import (
+	"nectar/styles"
+	"nectar/syntax"
+	"nectar/types"
+	"strings"
+)
+
+// HighlightSQL colours a script the way the query editor does
+func HighlightSQL(script string, dialect types.ConnectionType) string {
+	var b strings.Builder
+	for _, token := range syntax.Tokenize(script, dialect) {
+		b.WriteString(styles.SQLToken(token.Kind).Render(token.Text))
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package shared
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Narrowest a panel gets, however little room there is
+const MinPanelWidth = 30
+
+// FitWidth is how wide a panel that would like to be preferred columns wide
+// can be within available columns, its border included. A size of zero
+// means the space isn't known yet and leaves the panel as it is.
+func FitWidth(preferred, available int) int {
+	if available <= 0 {
+		return preferred
+	}
+	return max(MinPanelWidth, min(preferred, available-2))
+}
+
+// InputWidth is how wide a text field that would like to be preferred
+// columns wide may be inside a panel of the given width, short of its edges
+func InputWidth(preferred, panel int) int {
+	return max(10, min(preferred, panel-8))
+}
+
+// Panel renders content in a framed style, fitted into width by height
+// cells. A panel that doesn't fit gives up its side padding first, so the
+// text keeps room to wrap, then its top and bottom padding, then the blank
+// lines between its parts; what still doesn't fit is cut off at the bottom.
+func Panel(style lipgloss.Style, content string, width, height int) string {
+	preferred := style.GetWidth()
+	if fitted := FitWidth(preferred, width); fitted < preferred {
+		style = style.Width(fitted).PaddingLeft(1).PaddingRight(1)
+	}
+
+	view := style.Render(content)
+	if height <= 0 || lipgloss.Height(view) <= height {
+		return view
+	}
+	style = style.PaddingTop(0).PaddingBottom(0)
+	if view = style.Render(content); lipgloss.Height(view) <= height {
+		return view
+	}
+	lines := strings.Split(content, "\n")
+	lines = slices.DeleteFunc(lines, func(line string) bool { return strings.TrimSpace(line) == "" })
+	return Clip(style.Render(strings.Join(lines, "\n")), height)
+}
+
+// Clip keeps the first height lines of view
+func Clip(view string, height int) string {
+	lines := strings.Split(view, "\n")
+	if height <= 0 || len(lines) <= height {
+		return view
+	}
+	return strings.Join(lines[:height], "\n")
+}
@@ -0,0 +1,33 @@
+package shared
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Overlay draws block over base with its top left corner at x, y, keeping
+// whatever of base lies either side of it
+func Overlay(base string, block []string, x, y int) string {
+	lines := strings.Split(base, "\n")
+	for i, line := range block {
+		row := y + i
+		if row < 0 || row >= len(lines) {
+			continue
+		}
+		left := ansi.Truncate(lines[row], x, "")
+		left += strings.Repeat(" ", max(0, x-ansi.StringWidth(left)))
+		right := ansi.TruncateLeft(lines[row], x+ansi.StringWidth(line), "")
+		lines[row] = left + line + right
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Center draws block over the middle of base, which is width by height
+func Center(base, block string, width, height int) string {
+	lines := strings.Split(block, "\n")
+	x := max(0, (width-lipgloss.Width(block))/2)
+	y := max(0, (height-len(lines))/2)
+	return Overlay(base, lines, x, y)
+}
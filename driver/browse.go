@@ -0,0 +1,141 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"nectar/types"
+)
+
+// ErrNoDDL is returned by DDL for objects the engine can't script
+var ErrNoDDL = errors.New("no DDL available for this object")
+
+// ObjectKind is a kind of database object, as browsed in the schema tree
+type ObjectKind int
+
+const (
+	ObjectDatabase ObjectKind = iota
+	ObjectSchema
+	ObjectTable
+	ObjectView
+	ObjectMaterializedView
+	ObjectSequence
+	ObjectFunction
+	ObjectTrigger
+	ObjectColumn
+	ObjectIndex
+	ObjectConstraint
+)
+
+func (k ObjectKind) String() string {
+	switch k {
+	case ObjectDatabase:
+		return "database"
+	case ObjectSchema:
+		return "schema"
+	case ObjectTable:
+		return "table"
+	case ObjectView:
+		return "view"
+	case ObjectMaterializedView:
+		return "materialized view"
+	case ObjectSequence:
+		return "sequence"
+	case ObjectFunction:
+		return "function"
+	case ObjectTrigger:
+		return "trigger"
+	case ObjectColumn:
+		return "column"
+	case ObjectIndex:
+		return "index"
+	case ObjectConstraint:
+		return "constraint"
+	default:
+		return "unknown"
+	}
+}
+
+// Plural names a group of objects of the kind, e.g. "Tables"
+func (k ObjectKind) Plural() string {
+	switch k {
+	case ObjectIndex:
+		return "Indexes"
+	case ObjectMaterializedView:
+		return "Materialized views"
+	default:
+		s := k.String()
+		return string(s[0]-'a'+'A') + s[1:] + "s"
+	}
+}
+
+// Object is a database object found by browsing. Table is set on the
+// objects that belong to one: columns, indexes, constraints and triggers.
+type Object struct {
+	Kind   ObjectKind
+	Schema string
+	Table  string
+	Name   string
+	// Detail is a short description, such as a column's type or the
+	// arguments of a function
+	Detail string
+}
+
+// ObjectKinds lists the kinds of object an engine keeps in each schema, in
+// the order the schema tree shows them
+func ObjectKinds(connType types.ConnectionType) []ObjectKind {
+	switch connType {
+	case types.PostgreSQL:
+		return []ObjectKind{ObjectTable, ObjectView, ObjectMaterializedView, ObjectSequence, ObjectFunction, ObjectTrigger}
+	case types.MySQL:
+		return []ObjectKind{ObjectTable, ObjectView, ObjectFunction, ObjectTrigger}
+	default:
+		return []ObjectKind{ObjectTable, ObjectView, ObjectTrigger}
+	}
+}
+
+// TableKinds lists the kinds of object found under a table or view
+func TableKinds(kind ObjectKind) []ObjectKind {
+	switch kind {
+	case ObjectTable:
+		return []ObjectKind{ObjectColumn, ObjectIndex, ObjectConstraint}
+	case ObjectMaterializedView:
+		return []ObjectKind{ObjectColumn, ObjectIndex}
+	case ObjectView:
+		return []ObjectKind{ObjectColumn}
+	default:
+		return nil
+	}
+}
+
+// queryObjects runs a query returning name and detail columns and turns
+// each row into an object of the given kind
+func (d *sqlDriver) queryObjects(ctx context.Context, kind ObjectKind, schema, table, query string, args ...any) ([]Object, error) {
+	result, err := d.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]Object, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		name, _ := FormatValue(row[0])
+		detail, _ := FormatValue(row[1])
+		objects = append(objects, Object{Kind: kind, Schema: schema, Table: table, Name: name, Detail: detail})
+	}
+	return objects, nil
+}
+
+// queryDDL runs a query and returns the text in the given column of its
+// first row
+func (d *sqlDriver) queryDDL(ctx context.Context, column int, query string, args ...any) (string, error) {
+	result, err := d.Query(ctx, query, args...)
+	if err != nil {
+		return "", err
+	}
+	if len(result.Rows) == 0 || column >= len(result.Columns) {
+		return "", ErrNoDDL
+	}
+	ddl, null := FormatValue(result.Rows[0][column])
+	if null {
+		return "", ErrNoDDL
+	}
+	return ddl, nil
+}
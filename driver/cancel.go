@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Longest stopping a cancelled statement on the server may take
+const stopTimeout = 5 * time.Second
+
+// stopper stops a statement on the server when its context is cancelled.
+// Dropping the connection alone leaves PostgreSQL and MySQL running the
+// statement to the end; SQLite needs none, since its driver interrupts the
+// statement itself.
+type stopper struct {
+	// session identifies the server session behind conn
+	session func(ctx context.Context, conn *sql.Conn) (int64, error)
+	// stop writes the statement that stops what a session is running
+	stop func(session int64) string
+}
+
+// postgresStopper cancels through pg_cancel_backend, finding the backend
+// from the connection without asking the server
+var postgresStopper = &stopper{
+	session: func(ctx context.Context, conn *sql.Conn) (int64, error) {
+		var pid int64
+		err := conn.Raw(func(driverConn any) error {
+			c, ok := driverConn.(*stdlib.Conn)
+			if !ok {
+				return fmt.Errorf("unexpected connection type %T", driverConn)
+			}
+			pid = int64(c.Conn().PgConn().PID())
+			return nil
+		})
+		return pid, err
+	},
+	stop: func(session int64) string {
+		return fmt.Sprintf("SELECT pg_cancel_backend(%d)", session)
+	},
+}
+
+// mysqlStopper cancels with KILL QUERY, which stops the statement but
+// keeps the session
+var mysqlStopper = &stopper{
+	session: func(ctx context.Context, conn *sql.Conn) (int64, error) {
+		var id int64
+		err := conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id)
+		return id, err
+	},
+	stop: func(session int64) string {
+		return fmt.Sprintf("KILL QUERY %d", session)
+	},
+}
+
+// guard runs a statement on a connection of its own and, should ctx be
+// cancelled before it finishes, stops it on the server from another one.
+// The connection goes back to the pool only once any stop has been sent,
+// so the stop can't land on a later statement.
+func (d *sqlDriver) guard(ctx context.Context, run func(conn *sql.Conn) error) error {
+	if d.db == nil {
+		return ErrNotOpen
+	}
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if d.stopper == nil {
+		return run(conn)
+	}
+	session, err := d.session(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	db, stop := d.db, d.stopper.stop(session)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+			stopCtx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+			defer cancel()
+			// Best effort: the statement is abandoned here either way
+			db.ExecContext(stopCtx, stop)
+		case <-done:
+		}
+	}()
+	defer wg.Wait()
+	defer close(done)
+
+	return run(conn)
+}
+
+// session returns the server session behind conn. The pool hands out the
+// same few connections over and over, so each is asked only the first time
+// it is seen: for MySQL that is a round trip saved on every statement.
+func (d *sqlDriver) session(ctx context.Context, conn *sql.Conn) (int64, error) {
+	// The driver's connection is only kept as a key, never used outside Raw
+	var pooled any
+	conn.Raw(func(driverConn any) error {
+		pooled = driverConn
+		return nil
+	})
+	if session, ok := d.sessions.Load(pooled); ok {
+		return session.(int64), nil
+	}
+
+	session, err := d.stopper.session(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	d.sessions.Store(pooled, session)
+	return session, nil
+}
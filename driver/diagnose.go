@@ -0,0 +1,415 @@
+package driver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"nectar/types"
+	"nectar/utils"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Stage is one layer checked by Diagnose, in the order they run
+type Stage int
+
+const (
+	StageDNS Stage = iota
+	StageTCP
+	StageSSH
+	StageTLS
+	StageAuth
+	StageVersion
+	StageDatabase
+)
+
+// Stages lists every diagnostic stage in execution order
+var Stages = []Stage{StageDNS, StageTCP, StageSSH, StageTLS, StageAuth, StageVersion, StageDatabase}
+
+func (s Stage) String() string {
+	switch s {
+	case StageDNS:
+		return "DNS resolution"
+	case StageTCP:
+		return "TCP connect"
+	case StageSSH:
+		return "SSH tunnel"
+	case StageTLS:
+		return "TLS handshake"
+	case StageAuth:
+		return "Authentication"
+	case StageVersion:
+		return "Server version"
+	case StageDatabase:
+		return "Database"
+	default:
+		return "Unknown"
+	}
+}
+
+type StageStatus int
+
+const (
+	StatusPending StageStatus = iota
+	StatusRunning
+	StatusPassed
+	StatusFailed
+	StatusSkipped
+)
+
+func (s StageStatus) String() string {
+	switch s {
+	case StatusPending:
+		return "pending"
+	case StatusRunning:
+		return "running"
+	case StatusPassed:
+		return "passed"
+	case StatusFailed:
+		return "failed"
+	case StatusSkipped:
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
+
+// StageResult reports the progress or outcome of a single stage
+type StageResult struct {
+	Stage    Stage
+	Status   StageStatus
+	Detail   string
+	Err      error
+	Duration time.Duration
+}
+
+// Diagnose checks conn one layer at a time, calling report as each stage
+// starts and finishes. Once a stage fails the remaining ones are skipped.
+// With an SSH tunnel, DNS and TCP check the bastion and every later stage
+// goes through the tunnel.
+func Diagnose(ctx context.Context, conn types.Connection, report func(StageResult)) {
+	d := &diagnosis{conn: conn, report: report}
+
+	if conn.Type == types.SQLite {
+		d.skip(StageDNS, "local file")
+		d.skip(StageTCP, "local file")
+		d.skip(StageSSH, "local file")
+		d.skip(StageTLS, "local file")
+	} else {
+		d.run(ctx, StageDNS, d.resolve)
+		d.run(ctx, StageTCP, d.dial)
+		if conn.SSH.Enabled {
+			d.run(ctx, StageSSH, d.openTunnel)
+		} else {
+			d.skip(StageSSH, "not configured")
+		}
+		if conn.TLS.Mode != types.TLSDisable {
+			d.run(ctx, StageTLS, d.handshake)
+		} else {
+			d.skip(StageTLS, "TLS disabled")
+		}
+	}
+
+	d.run(ctx, StageAuth, d.authenticate)
+	d.run(ctx, StageVersion, d.version)
+	d.run(ctx, StageDatabase, d.database)
+
+	if d.session != nil {
+		d.session.Close()
+	}
+	if d.tunnel != nil {
+		d.tunnel.Close()
+	}
+}
+
+type diagnosis struct {
+	conn    types.Connection
+	report  func(StageResult)
+	failed  bool
+	addr    string
+	tunnel  *Tunnel
+	session Driver
+	// set when authentication succeeded but the database could not be opened
+	databaseErr error
+}
+
+func (d *diagnosis) run(ctx context.Context, stage Stage, check func(context.Context) (string, error)) {
+	if d.failed {
+		d.skip(stage, "")
+		return
+	}
+	if err := ctx.Err(); err != nil {
+		d.failed = true
+		d.report(StageResult{Stage: stage, Status: StatusFailed, Err: err})
+		return
+	}
+
+	d.report(StageResult{Stage: stage, Status: StatusRunning})
+	start := time.Now()
+	detail, err := check(ctx)
+	result := StageResult{Stage: stage, Status: StatusPassed, Detail: detail, Err: err, Duration: time.Since(start)}
+	if err != nil {
+		result.Status = StatusFailed
+		d.failed = true
+	}
+	d.report(result)
+}
+
+func (d *diagnosis) skip(stage Stage, detail string) {
+	d.report(StageResult{Stage: stage, Status: StatusSkipped, Detail: detail})
+}
+
+// target returns the host and port the network stages check: the bastion
+// when tunnelling, the database server otherwise
+func (d *diagnosis) target() (string, string) {
+	if d.conn.SSH.Enabled {
+		port := d.conn.SSH.Port
+		if port == "" {
+			port = utils.DefaultSSHPort
+		}
+		return d.conn.SSH.Host, port
+	}
+	return hostOrDefault(d.conn), portOrDefault(d.conn)
+}
+
+func (d *diagnosis) resolve(ctx context.Context) (string, error) {
+	host, port := d.target()
+	if ip := net.ParseIP(host); ip != nil {
+		d.addr = net.JoinHostPort(host, port)
+		return host + " (literal address)", nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	d.addr = net.JoinHostPort(addrs[0], port)
+	return strings.Join(addrs, ", "), nil
+}
+
+func (d *diagnosis) dial(ctx context.Context) (string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+	return d.addr, nil
+}
+
+// openTunnel logs in to the bastion and checks it can reach the database,
+// then points the remaining stages at the tunnel's local end
+func (d *diagnosis) openTunnel(ctx context.Context) (string, error) {
+	tunnel, err := OpenTunnel(ctx, d.conn)
+	if err != nil {
+		return "", err
+	}
+	if err := tunnel.Check(); err != nil {
+		tunnel.Close()
+		return "", err
+	}
+
+	d.tunnel = tunnel
+	d.conn = tunnel.Rewrite(d.conn)
+	d.addr = net.JoinHostPort(d.conn.Host, d.conn.Port)
+	return "forwarding " + d.addr + " to " + tunnel.remote, nil
+}
+
+// handshake negotiates TLS the way the engine's protocol does: PostgreSQL
+// asks with an SSLRequest packet, MySQL upgrades after the server greeting.
+// With prefer, a server without TLS is reported but not treated as fatal.
+func (d *diagnosis) handshake(ctx context.Context) (string, error) {
+	detail, err := d.negotiateTLS(ctx)
+	if err != nil && d.conn.TLS.Mode == types.TLSPrefer {
+		return "unavailable (" + err.Error() + "), falling back to plaintext", nil
+	}
+	return detail, err
+}
+
+func (d *diagnosis) negotiateTLS(ctx context.Context) (string, error) {
+	config, err := tlsConfig(d.conn)
+	if err != nil {
+		return "", err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	switch d.conn.Type {
+	case types.PostgreSQL:
+		err = startPostgresTLS(conn)
+	case types.MySQL:
+		err = startMySQLTLS(conn)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return "", err
+	}
+
+	state := tlsConn.ConnectionState()
+	detail := tls.VersionName(state.Version) + ", " + tls.CipherSuiteName(state.CipherSuite)
+	if config.InsecureSkipVerify && config.VerifyPeerCertificate == nil {
+		detail += ", certificate not verified"
+	}
+	return detail, nil
+}
+
+func startPostgresTLS(conn net.Conn) error {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], 80877103)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 'S' {
+		return errors.New("server does not accept SSL connections")
+	}
+	return nil
+}
+
+const (
+	mysqlClientProtocol41 = 0x00000200
+	mysqlClientSSL        = 0x00000800
+)
+
+func startMySQLTLS(conn net.Conn) error {
+	// Initial handshake: 3 byte length, 1 byte sequence, then the payload
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+	if len(payload) < 1 {
+		return errors.New("malformed server greeting")
+	}
+	if payload[0] == 0xff {
+		return errors.New("server refused the connection")
+	}
+
+	// protocol version, NUL terminated server version, connection id,
+	// 8 bytes of auth data and a filler byte precede the capability flags
+	end := strings.IndexByte(string(payload[1:]), 0)
+	offset := 1 + end + 1 + 4 + 8 + 1
+	if end < 0 || len(payload) < offset+2 {
+		return errors.New("malformed server greeting")
+	}
+	capabilities := binary.LittleEndian.Uint16(payload[offset : offset+2])
+	if capabilities&mysqlClientSSL == 0 {
+		return errors.New("server does not support SSL")
+	}
+
+	request := make([]byte, 4+32)
+	request[0], request[3] = 32, 1
+	binary.LittleEndian.PutUint32(request[4:8], mysqlClientProtocol41|mysqlClientSSL)
+	binary.LittleEndian.PutUint32(request[8:12], 1<<24)
+	request[12] = 45 // utf8mb4_general_ci
+	_, err := conn.Write(request)
+	return err
+}
+
+func (d *diagnosis) authenticate(ctx context.Context) (string, error) {
+	session, err := Connect(ctx, d.conn)
+	if err != nil {
+		if isUnknownDatabase(err) {
+			// The credentials were accepted; the database stage reports the rest
+			d.databaseErr = err
+			d.authenticateWithoutDatabase(ctx)
+			return "credentials accepted", nil
+		}
+		return "", err
+	}
+	d.session = session
+	if d.conn.Type == types.SQLite {
+		return "no authentication required", nil
+	}
+	return "logged in as " + d.conn.User, nil
+}
+
+// Log in without selecting a database so the version can still be read.
+// Failure is fine; the version stage then reports it as unavailable.
+func (d *diagnosis) authenticateWithoutDatabase(ctx context.Context) {
+	conn := d.conn
+	conn.Database = ""
+	if conn.Type == types.PostgreSQL {
+		conn.Database = "postgres"
+	}
+	if session, err := Connect(ctx, conn); err == nil {
+		d.session = session
+	}
+}
+
+func (d *diagnosis) version(ctx context.Context) (string, error) {
+	if d.session == nil {
+		return "unavailable", nil
+	}
+	return d.session.ServerVersion(ctx)
+}
+
+func (d *diagnosis) database(ctx context.Context) (string, error) {
+	if d.databaseErr != nil {
+		return "", d.databaseErr
+	}
+
+	var query string
+	switch d.conn.Type {
+	case types.PostgreSQL:
+		query = "SELECT current_database()"
+	case types.MySQL:
+		query = "SELECT DATABASE()"
+	case types.SQLite:
+		query = "SELECT count(*) FROM sqlite_master"
+	}
+	result, err := d.session.Query(ctx, query)
+	if err != nil {
+		return "", err
+	}
+
+	if d.conn.Type == types.SQLite {
+		return fmt.Sprintf("%v schema objects", result.Rows[0][0]), nil
+	}
+	if len(result.Rows) == 0 || result.Rows[0][0] == nil {
+		return "none selected", nil
+	}
+	return fmt.Sprint(result.Rows[0][0]), nil
+}
+
+// isUnknownDatabase reports whether err means the server accepted the login
+// but the requested database does not exist
+func isUnknownDatabase(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "3D000"
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1049
+	}
+	return false
+}
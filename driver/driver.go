@@ -0,0 +1,128 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"nectar/types"
+)
+
+// Driver is an open (or openable) session against a single database
+type Driver interface {
+	// Open connects to the database described by conn
+	Open(ctx context.Context, conn types.Connection) error
+	// Ping verifies the connection is still alive
+	Ping(ctx context.Context) error
+	// Query runs a statement that returns rows and reads the full result
+	Query(ctx context.Context, query string, args ...any) (*Result, error)
+	// Stream runs a statement that returns rows without holding them: the
+	// column names go to columns once, then each row to row as it is read.
+	// An error from either stops the statement.
+	Stream(ctx context.Context, query string, columns func([]string) error, row func([]any) error, args ...any) error
+	// Exec runs a statement that does not return rows
+	Exec(ctx context.Context, query string, args ...any) (*Result, error)
+	// Close releases the connection
+	Close() error
+	// Introspect reads the schemas, tables, views, columns and functions of
+	// the current database
+	Introspect(ctx context.Context) (*Schema, error)
+	// ServerVersion reports the version string of the database server
+	ServerVersion(ctx context.Context) (string, error)
+	// Databases lists the databases the session could switch to
+	Databases(ctx context.Context) ([]string, error)
+	// Schemas lists the schemas of the current database; for MySQL these are
+	// its databases and for SQLite the attached database files
+	Schemas(ctx context.Context) ([]string, error)
+	// Objects lists the objects of a kind in a schema, or for columns,
+	// indexes and constraints, those of the given table
+	Objects(ctx context.Context, kind ObjectKind, schema, table string) ([]Object, error)
+	// DDL returns the statement that creates an object
+	DDL(ctx context.Context, object Object) (string, error)
+	// Keys lists the sets of columns that identify a table's rows: the
+	// primary key first, then any unique keys
+	Keys(ctx context.Context, schema, table string) ([][]string, error)
+	// Transact runs statements in a single transaction, rolling back if any
+	// of them fails or a keyed one doesn't affect exactly one row
+	Transact(ctx context.Context, statements []Statement) error
+}
+
+// Result holds the outcome of a statement. Values in Rows are nil for SQL
+// NULL; everything else is converted to a Go string, number, bool or time.
+type Result struct {
+	Columns      []string
+	Rows         [][]any
+	RowsAffected int64
+}
+
+type TableKind int
+
+const (
+	KindTable TableKind = iota
+	KindView
+)
+
+func (k TableKind) String() string {
+	switch k {
+	case KindTable:
+		return "table"
+	case KindView:
+		return "view"
+	default:
+		return "unknown"
+	}
+}
+
+type Schema struct {
+	Schemas   []string
+	Tables    []Table
+	Functions []Function
+}
+
+type Table struct {
+	Schema  string
+	Name    string
+	Kind    TableKind
+	Columns []Column
+}
+
+type Function struct {
+	Schema string
+	Name   string
+}
+
+type Column struct {
+	Name       string
+	Type       string
+	Nullable   bool
+	PrimaryKey bool
+}
+
+// New returns an unopened driver for the given connection type
+func New(connType types.ConnectionType) (Driver, error) {
+	switch connType {
+	case types.PostgreSQL:
+		return &postgresDriver{}, nil
+	case types.MySQL:
+		return &mysqlDriver{}, nil
+	case types.SQLite:
+		return &sqliteDriver{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported connection type %s", connType)
+	}
+}
+
+// Connect opens and pings a driver for conn, closing it again on failure
+func Connect(ctx context.Context, conn types.Connection) (Driver, error) {
+	d, err := New(conn.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Open(ctx, conn); err != nil {
+		d.Close()
+		return nil, err
+	}
+	if err := d.Ping(ctx); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,69 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+)
+
+// Statement is a query with its arguments, as run by Transact
+type Statement struct {
+	Query string
+	Args  []any
+	// Keyed statements pick out a single row by its key, so affecting any
+	// other number of rows means the row changed since it was read
+	Keyed bool
+}
+
+// Transact runs the statements in order in one transaction, rolling it
+// back as soon as one fails or a keyed one misses its row
+func (d *sqlDriver) Transact(ctx context.Context, statements []Statement) error {
+	if d.db == nil {
+		return ErrNotOpen
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for i, stmt := range statements {
+		result, err := tx.ExecContext(ctx, stmt.Query, stmt.Args...)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+		if !stmt.Keyed {
+			continue
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+		if affected != 1 {
+			tx.Rollback()
+			return fmt.Errorf("statement %d (%s) affected %d rows instead of 1; the row was changed or deleted since it was read", i+1, stmt.Query, affected)
+		}
+	}
+	return tx.Commit()
+}
+
+// queryKeys runs a query returning a key name and a column name per row,
+// ordered by key, and collects the columns of each key in turn
+func (d *sqlDriver) queryKeys(ctx context.Context, query string, args ...any) ([][]string, error) {
+	result, err := d.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var keys [][]string
+	previous := ""
+	for i, row := range result.Rows {
+		name, _ := FormatValue(row[0])
+		column, _ := FormatValue(row[1])
+		if i == 0 || name != previous {
+			keys = append(keys, nil)
+			previous = name
+		}
+		keys[len(keys)-1] = append(keys[len(keys)-1], column)
+	}
+	return keys, nil
+}
@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"nectar/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatValue renders a result value as text. NULL is reported separately
+// so callers can style it apart from the string "NULL".
+func FormatValue(value any) (text string, null bool) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", true
+	case string:
+		return v, false
+	case []byte:
+		return string(v), false
+	case bool:
+		return strconv.FormatBool(v), false
+	case int64:
+		return strconv.FormatInt(v, 10), false
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), false
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), false
+	case time.Time:
+		return formatTime(v), false
+	default:
+		return fmt.Sprint(v), false
+	}
+}
+
+// Dates come back as midnight timestamps; don't pad them with a time
+func formatTime(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format(time.DateOnly)
+	}
+	if t.Nanosecond() != 0 {
+		return t.Format("2006-01-02 15:04:05.999999999 -07:00")
+	}
+	return t.Format("2006-01-02 15:04:05 -07:00")
+}
+
+// Literal writes a result value as an SQL literal in the dialect: numbers
+// and booleans bare, NULL as such, bytes in hex, times in the form the
+// dialect parses and everything else as a quoted string. Floats that aren't
+// finite only have a literal in PostgreSQL.
+func Literal(value any, dialect types.ConnectionType) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return floatLiteral(v, 64, dialect)
+	case float32:
+		return floatLiteral(float64(v), 32, dialect)
+	case []byte:
+		if dialect == types.PostgreSQL {
+			return `'\x` + hex.EncodeToString(v) + "'::bytea", nil
+		}
+		return "X'" + hex.EncodeToString(v) + "'", nil
+	case time.Time:
+		return QuoteString(timeLiteral(v, dialect), dialect), nil
+	default:
+		text, _ := FormatValue(v)
+		return QuoteString(text, dialect), nil
+	}
+}
+
+func floatLiteral(f float64, bits int, dialect types.ConnectionType) (string, error) {
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, bits), nil
+	}
+	if dialect != types.PostgreSQL {
+		return "", fmt.Errorf("%s has no %s literal", strconv.FormatFloat(f, 'g', -1, bits), dialect)
+	}
+	switch {
+	case math.IsNaN(f):
+		return "'NaN'::float8", nil
+	case f > 0:
+		return "'Infinity'::float8", nil
+	default:
+		return "'-Infinity'::float8", nil
+	}
+}
+
+// timeLiteral writes a time the way the dialect reads it. MySQL's DATETIME
+// takes no offset and no more than microseconds; the values it returns are
+// already in the connection's zone. SQLite keeps times as text, which has
+// no offset when it was written in UTC.
+func timeLiteral(t time.Time, dialect types.ConnectionType) string {
+	switch {
+	case t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0:
+		return t.Format(time.DateOnly)
+	case dialect == types.MySQL:
+		return t.Format("2006-01-02 15:04:05.999999")
+	case dialect == types.SQLite && t.Location() == time.UTC:
+		return t.Format("2006-01-02 15:04:05.999999999")
+	default:
+		return t.Format("2006-01-02 15:04:05.999999999-07:00")
+	}
+}
+
+// QuoteString writes text as a string literal; MySQL also takes backslash
+// as an escape inside one
+func QuoteString(text string, dialect types.ConnectionType) string {
+	text = strings.ReplaceAll(text, "'", "''")
+	if dialect == types.MySQL {
+		text = strings.ReplaceAll(text, `\`, `\\`)
+	}
+	return "'" + text + "'"
+}
@@ -0,0 +1,172 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"nectar/syntax"
+	"nectar/types"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type mysqlDriver struct {
+	sqlDriver
+}
+
+func (d *mysqlDriver) Open(ctx context.Context, conn types.Connection) error {
+	conn, err := d.throughTunnel(ctx, conn)
+	if err != nil {
+		return err
+	}
+	cfg, err := mysqlConfig(conn)
+	if err != nil {
+		return err
+	}
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return err
+	}
+	d.use(sql.OpenDB(connector))
+	d.stopper = mysqlStopper
+	return nil
+}
+
+func (d *mysqlDriver) ServerVersion(ctx context.Context) (string, error) {
+	return d.queryString(ctx, "SELECT VERSION()")
+}
+
+func (d *mysqlDriver) Databases(ctx context.Context) ([]string, error) {
+	return d.queryStrings(ctx, "SHOW DATABASES")
+}
+
+// A MySQL schema is a database, so every one on the server is listed
+const mysqlSchemas = `SELECT schema_name FROM information_schema.schemata ORDER BY schema_name`
+
+func (d *mysqlDriver) Introspect(ctx context.Context) (*Schema, error) {
+	return d.introspect(ctx, `
+		SELECT c.table_schema, c.table_name,
+			CASE WHEN t.table_type = 'VIEW' THEN 'view' ELSE 'table' END,
+			c.column_name, c.column_type, c.is_nullable = 'YES', c.column_key = 'PRI'
+		FROM information_schema.columns c
+		JOIN information_schema.tables t
+			ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = DATABASE()
+		ORDER BY c.table_name, c.ordinal_position`, mysqlSchemas, `
+		SELECT routine_schema, routine_name
+		FROM information_schema.routines
+		WHERE routine_schema = DATABASE()
+		ORDER BY routine_name`)
+}
+
+func (d *mysqlDriver) Schemas(ctx context.Context) ([]string, error) {
+	return d.queryStrings(ctx, mysqlSchemas)
+}
+
+func (d *mysqlDriver) Objects(ctx context.Context, kind ObjectKind, schema, table string) ([]Object, error) {
+	switch kind {
+	case ObjectTable, ObjectView:
+		tableType := "BASE TABLE"
+		if kind == ObjectView {
+			tableType = "VIEW"
+		}
+		return d.queryObjects(ctx, kind, schema, "", `
+			SELECT table_name, ''
+			FROM information_schema.tables
+			WHERE table_schema = ? AND table_type = ?
+			ORDER BY 1`, schema, tableType)
+	case ObjectFunction:
+		// Procedures are listed alongside, told apart by their detail
+		return d.queryObjects(ctx, kind, schema, "", `
+			SELECT routine_name, LOWER(routine_type)
+			FROM information_schema.routines
+			WHERE routine_schema = ?
+			ORDER BY 1`, schema)
+	case ObjectTrigger:
+		triggers, err := d.queryObjects(ctx, kind, schema, "", `
+			SELECT trigger_name, event_object_table
+			FROM information_schema.triggers
+			WHERE trigger_schema = ?
+			ORDER BY 1`, schema)
+		for i := range triggers {
+			triggers[i].Table = triggers[i].Detail
+		}
+		return triggers, err
+	case ObjectColumn:
+		return d.queryObjects(ctx, kind, schema, table, `
+			SELECT column_name, column_type
+			FROM information_schema.columns
+			WHERE table_schema = ? AND table_name = ?
+			ORDER BY ordinal_position`, schema, table)
+	case ObjectIndex:
+		return d.queryObjects(ctx, kind, schema, table, `
+			SELECT index_name, GROUP_CONCAT(column_name ORDER BY seq_in_index SEPARATOR ', ')
+			FROM information_schema.statistics
+			WHERE table_schema = ? AND table_name = ?
+			GROUP BY index_name
+			ORDER BY 1`, schema, table)
+	case ObjectConstraint:
+		return d.queryObjects(ctx, kind, schema, table, `
+			SELECT constraint_name, LOWER(constraint_type)
+			FROM information_schema.table_constraints
+			WHERE table_schema = ? AND table_name = ?
+			ORDER BY 1`, schema, table)
+	}
+	return nil, nil
+}
+
+// Keys are the unique indexes, the primary key first
+func (d *mysqlDriver) Keys(ctx context.Context, schema, table string) ([][]string, error) {
+	return d.queryKeys(ctx, `
+		SELECT index_name, column_name
+		FROM information_schema.statistics
+		WHERE table_schema = ? AND table_name = ? AND non_unique = 0
+		ORDER BY index_name = 'PRIMARY' DESC, index_name, seq_in_index`, schema, table)
+}
+
+// DDL comes from SHOW CREATE. Columns, indexes and constraints have none of
+// their own, so they show their table's.
+func (d *mysqlDriver) DDL(ctx context.Context, object Object) (string, error) {
+	name := syntax.QuoteIdentifier(object.Schema, types.MySQL) + "." + syntax.QuoteIdentifier(object.Name, types.MySQL)
+	switch object.Kind {
+	case ObjectDatabase, ObjectSchema:
+		return d.queryDDL(ctx, 1, "SHOW CREATE DATABASE "+syntax.QuoteIdentifier(object.Name, types.MySQL))
+	case ObjectTable:
+		return d.queryDDL(ctx, 1, "SHOW CREATE TABLE "+name)
+	case ObjectView:
+		return d.queryDDL(ctx, 1, "SHOW CREATE VIEW "+name)
+	case ObjectFunction:
+		if object.Detail == "procedure" {
+			return d.queryDDL(ctx, 2, "SHOW CREATE PROCEDURE "+name)
+		}
+		return d.queryDDL(ctx, 2, "SHOW CREATE FUNCTION "+name)
+	case ObjectTrigger:
+		return d.queryDDL(ctx, 2, "SHOW CREATE TRIGGER "+name)
+	case ObjectColumn, ObjectIndex, ObjectConstraint:
+		return d.queryDDL(ctx, 1, "SHOW CREATE TABLE "+syntax.QuoteIdentifier(object.Schema, types.MySQL)+"."+syntax.QuoteIdentifier(object.Table, types.MySQL))
+	}
+	return "", ErrNoDDL
+}
+
+// mysqlConfig builds the go-sql-driver configuration, mapping the libpq
+// style TLS modes onto MySQL's (prefer allows falling back to plaintext)
+func mysqlConfig(conn types.Connection) (*mysql.Config, error) {
+	tlsSettings, err := tlsConfig(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := mysql.NewConfig()
+	cfg.User = conn.User
+	cfg.Passwd = conn.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(hostOrDefault(conn), portOrDefault(conn))
+	cfg.DBName = conn.Database
+	cfg.ParseTime = true
+	// Count the rows an UPDATE matched, as PostgreSQL does, rather than only
+	// those whose values changed, so a keyed edit can tell it found its row
+	cfg.ClientFoundRows = true
+	cfg.TLS = tlsSettings
+	cfg.AllowFallbackToPlaintext = conn.TLS.Mode == types.TLSPrefer
+	return cfg, nil
+}
@@ -0,0 +1,297 @@
+package driver
+
+import (
+	"context"
+	"nectar/types"
+	"nectar/utils"
+	"net"
+	"net/url"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+type postgresDriver struct {
+	sqlDriver
+}
+
+func (d *postgresDriver) Open(ctx context.Context, conn types.Connection) error {
+	conn, err := d.throughTunnel(ctx, conn)
+	if err != nil {
+		return err
+	}
+	config, err := postgresConfig(conn)
+	if err != nil {
+		return err
+	}
+	d.use(stdlib.OpenDB(*config))
+	d.stopper = postgresStopper
+	return nil
+}
+
+func (d *postgresDriver) ServerVersion(ctx context.Context) (string, error) {
+	return d.queryString(ctx, "SHOW server_version")
+}
+
+func (d *postgresDriver) Databases(ctx context.Context) ([]string, error) {
+	return d.queryStrings(ctx, "SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate ORDER BY datname")
+}
+
+// User schemas, leaving out the system catalogs
+const postgresSchemas = `
+	SELECT nspname
+	FROM pg_namespace
+	WHERE nspname NOT LIKE 'pg\_%' AND nspname <> 'information_schema'
+	ORDER BY nspname`
+
+func (d *postgresDriver) Introspect(ctx context.Context) (*Schema, error) {
+	return d.introspect(ctx, `
+		SELECT c.table_schema, c.table_name,
+			CASE WHEN t.table_type = 'VIEW' THEN 'view' ELSE 'table' END,
+			c.column_name, c.data_type, c.is_nullable = 'YES',
+			EXISTS (
+				SELECT 1
+				FROM information_schema.table_constraints tc
+				JOIN information_schema.key_column_usage k
+					ON k.constraint_schema = tc.constraint_schema
+					AND k.constraint_name = tc.constraint_name
+				WHERE tc.constraint_type = 'PRIMARY KEY'
+					AND tc.table_schema = c.table_schema
+					AND tc.table_name = c.table_name
+					AND k.column_name = c.column_name
+			)
+		FROM information_schema.columns c
+		JOIN information_schema.tables t
+			ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY c.table_schema, c.table_name, c.ordinal_position`, postgresSchemas, `
+		SELECT DISTINCT n.nspname, p.proname
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname NOT LIKE 'pg\_%' AND n.nspname <> 'information_schema'
+		ORDER BY 1, 2`)
+}
+
+func (d *postgresDriver) Schemas(ctx context.Context) ([]string, error) {
+	return d.queryStrings(ctx, postgresSchemas)
+}
+
+// Relations are found by regclass, so names needing quotes resolve too
+const postgresRelation = `to_regclass(format('%I.%I', $1::text, $2::text))`
+
+func (d *postgresDriver) Objects(ctx context.Context, kind ObjectKind, schema, table string) ([]Object, error) {
+	switch kind {
+	case ObjectTable, ObjectView:
+		tableType := "BASE TABLE"
+		if kind == ObjectView {
+			tableType = "VIEW"
+		}
+		return d.queryObjects(ctx, kind, schema, "", `
+			SELECT table_name, ''
+			FROM information_schema.tables
+			WHERE table_schema = $1 AND table_type = $2
+			ORDER BY 1`, schema, tableType)
+	case ObjectMaterializedView:
+		return d.queryObjects(ctx, kind, schema, "", `
+			SELECT matviewname, '' FROM pg_matviews WHERE schemaname = $1 ORDER BY 1`, schema)
+	case ObjectSequence:
+		return d.queryObjects(ctx, kind, schema, "", `
+			SELECT sequence_name, data_type
+			FROM information_schema.sequences
+			WHERE sequence_schema = $1
+			ORDER BY 1`, schema)
+	case ObjectFunction:
+		return d.queryObjects(ctx, kind, schema, "", `
+			SELECT p.proname, pg_get_function_identity_arguments(p.oid)
+			FROM pg_proc p
+			JOIN pg_namespace n ON n.oid = p.pronamespace
+			WHERE n.nspname = $1
+			ORDER BY 1, 2`, schema)
+	case ObjectTrigger:
+		triggers, err := d.queryObjects(ctx, kind, schema, "", `
+			SELECT DISTINCT trigger_name, event_object_table
+			FROM information_schema.triggers
+			WHERE trigger_schema = $1
+			ORDER BY 1`, schema)
+		for i := range triggers {
+			triggers[i].Table = triggers[i].Detail
+		}
+		return triggers, err
+	case ObjectColumn:
+		return d.queryObjects(ctx, kind, schema, table, `
+			SELECT attname, format_type(atttypid, atttypmod)
+			FROM pg_attribute
+			WHERE attrelid = `+postgresRelation+` AND attnum > 0 AND NOT attisdropped
+			ORDER BY attnum`, schema, table)
+	case ObjectIndex:
+		return d.queryObjects(ctx, kind, schema, table, `
+			SELECT indexname, regexp_replace(indexdef, '^.* USING ', '')
+			FROM pg_indexes
+			WHERE schemaname = $1 AND tablename = $2
+			ORDER BY 1`, schema, table)
+	case ObjectConstraint:
+		return d.queryObjects(ctx, kind, schema, table, `
+			SELECT conname, pg_get_constraintdef(oid)
+			FROM pg_constraint
+			WHERE conrelid = `+postgresRelation+` AND contype <> 'n'
+			ORDER BY 1`, schema, table)
+	}
+	return nil, nil
+}
+
+// Unique indexes count as keys as well as constraints; partial and
+// expression indexes don't identify rows
+func (d *postgresDriver) Keys(ctx context.Context, schema, table string) ([][]string, error) {
+	return d.queryKeys(ctx, `
+		SELECT i.indexrelid::regclass::text, a.attname
+		FROM pg_index i
+		CROSS JOIN LATERAL unnest(i.indkey::int2[]) WITH ORDINALITY AS k(attnum, n)
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+		WHERE i.indrelid = `+postgresRelation+` AND i.indisunique
+			AND i.indpred IS NULL AND i.indexprs IS NULL
+		ORDER BY i.indisprimary DESC, 1, k.n`, schema, table)
+}
+
+// PostgreSQL has no SHOW CREATE, so DDL is put together from the catalogs
+func (d *postgresDriver) DDL(ctx context.Context, object Object) (string, error) {
+	switch object.Kind {
+	case ObjectDatabase:
+		return d.queryDDL(ctx, 0, `SELECT format('CREATE DATABASE %I;', $1::text)`, object.Name)
+	case ObjectSchema:
+		return d.queryDDL(ctx, 0, `SELECT format('CREATE SCHEMA %I;', $1::text)`, object.Name)
+	case ObjectTable:
+		return d.queryDDL(ctx, 0, postgresTableDDL, object.Schema, object.Name)
+	case ObjectView, ObjectMaterializedView:
+		create := "CREATE VIEW"
+		if object.Kind == ObjectMaterializedView {
+			create = "CREATE MATERIALIZED VIEW"
+		}
+		return d.queryDDL(ctx, 0, `
+			SELECT format(E'`+create+` %I.%I AS\n%s', $1::text, $2::text, pg_get_viewdef(`+postgresRelation+`, true))`,
+			object.Schema, object.Name)
+	case ObjectSequence:
+		return d.queryDDL(ctx, 0, `
+			SELECT format('CREATE SEQUENCE %I.%I AS %s INCREMENT BY %s MINVALUE %s MAXVALUE %s START WITH %s%s;',
+				schemaname, sequencename, data_type, increment_by, min_value, max_value, start_value,
+				CASE WHEN cycle THEN ' CYCLE' ELSE '' END)
+			FROM pg_sequences
+			WHERE schemaname = $1 AND sequencename = $2`, object.Schema, object.Name)
+	case ObjectFunction:
+		return d.queryDDL(ctx, 0, `
+			SELECT pg_get_functiondef(p.oid)
+			FROM pg_proc p
+			JOIN pg_namespace n ON n.oid = p.pronamespace
+			WHERE n.nspname = $1 AND p.proname = $2 AND pg_get_function_identity_arguments(p.oid) = $3`,
+			object.Schema, object.Name, object.Detail)
+	case ObjectTrigger:
+		return d.queryDDL(ctx, 0, `
+			SELECT pg_get_triggerdef(t.oid, true) || ';'
+			FROM pg_trigger t
+			JOIN pg_class c ON c.oid = t.tgrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = $1 AND t.tgname = $2`, object.Schema, object.Name)
+	case ObjectColumn:
+		return d.queryDDL(ctx, 0, `SELECT format('ALTER TABLE %I.%I ADD COLUMN %I %s;', $1::text, $2::text, $3::text, $4::text)`,
+			object.Schema, object.Table, object.Name, object.Detail)
+	case ObjectIndex:
+		return d.queryDDL(ctx, 0, `
+			SELECT indexdef || ';' FROM pg_indexes WHERE schemaname = $1 AND indexname = $2`, object.Schema, object.Name)
+	case ObjectConstraint:
+		return d.queryDDL(ctx, 0, `
+			SELECT format('ALTER TABLE %I.%I ADD CONSTRAINT %I %s;', $1::text, $2::text, conname, pg_get_constraintdef(oid))
+			FROM pg_constraint
+			WHERE conrelid = `+postgresRelation+` AND conname = $3`, object.Schema, object.Table, object.Name)
+	}
+	return "", ErrNoDDL
+}
+
+// postgresTableDDL scripts a table from its columns, constraints and the
+// indexes that don't back a constraint
+const postgresTableDDL = `
+	SELECT format(E'CREATE TABLE %I.%I (\n%s\n);', n.nspname, c.relname, array_to_string(
+		array(
+			SELECT format('    %I %s%s%s', a.attname, format_type(a.atttypid, a.atttypmod),
+				CASE WHEN a.attnotnull THEN ' NOT NULL' ELSE '' END,
+				COALESCE(' DEFAULT ' || pg_get_expr(ad.adbin, ad.adrelid), ''))
+			FROM pg_attribute a
+			LEFT JOIN pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+			WHERE a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+			ORDER BY a.attnum
+		) || array(
+			SELECT format('    CONSTRAINT %I %s', con.conname, pg_get_constraintdef(con.oid))
+			FROM pg_constraint con
+			WHERE con.conrelid = c.oid AND con.contype <> 'n'
+			ORDER BY con.contype = 'p' DESC, con.conname
+		), E',\n'))
+		|| COALESCE(E'\n\n' || (
+			SELECT string_agg(i.indexdef || ';', E'\n' ORDER BY i.indexname)
+			FROM pg_indexes i
+			WHERE i.schemaname = n.nspname AND i.tablename = c.relname
+				AND NOT EXISTS (
+					SELECT 1 FROM pg_constraint x
+					WHERE x.conindid = to_regclass(format('%I.%I', i.schemaname, i.indexname))
+				)
+		), '')
+	FROM pg_class c
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relname = $2`
+
+// postgresConfig parses the connection's DSN and swaps pgx's sslmode-derived
+// TLS settings for ours, which also carry the CA, client certificate and
+// server name override
+func postgresConfig(conn types.Connection) (*pgx.ConnConfig, error) {
+	config, err := pgx.ParseConfig(postgresDSN(conn))
+	if err != nil {
+		return nil, err
+	}
+
+	tlsSettings, err := tlsConfig(conn)
+	if err != nil {
+		return nil, err
+	}
+	if config.TLSConfig != nil {
+		config.TLSConfig = tlsSettings.Clone()
+	}
+	// prefer adds a plaintext fallback, which must stay without TLS
+	for _, fallback := range config.Fallbacks {
+		if fallback.TLSConfig != nil {
+			fallback.TLSConfig = tlsSettings.Clone()
+		}
+	}
+	return config, nil
+}
+
+// postgresDSN builds a postgres:// URL understood by pgx
+func postgresDSN(conn types.Connection) string {
+	dsn := url.URL{
+		Scheme: "postgres",
+		Host:   net.JoinHostPort(hostOrDefault(conn), portOrDefault(conn)),
+		Path:   "/" + conn.Database,
+	}
+	if conn.User != "" {
+		if conn.Password != "" {
+			dsn.User = url.UserPassword(conn.User, conn.Password)
+		} else {
+			dsn.User = url.User(conn.User)
+		}
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", conn.TLS.Mode.String())
+	dsn.RawQuery = query.Encode()
+	return dsn.String()
+}
+
+func hostOrDefault(conn types.Connection) string {
+	if conn.Host == "" {
+		return "localhost"
+	}
+	return conn.Host
+}
+
+func portOrDefault(conn types.Connection) string {
+	if conn.Port == "" {
+		return utils.GetDefaultPort(conn.Type)
+	}
+	return conn.Port
+}
@@ -0,0 +1,291 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"nectar/types"
+	"strings"
+	"sync"
+)
+
+var ErrNotOpen = errors.New("connection is not open")
+
+// sqlDriver implements the parts of Driver shared by every database/sql
+// backed engine. Engine drivers embed it and supply Open and Introspect.
+type sqlDriver struct {
+	db     *sql.DB
+	tunnel *Tunnel
+	// stopper, when set, stops cancelled statements on the server
+	stopper *stopper
+	// sessions caches the server session of each pooled connection, keyed
+	// by the driver's connection. Entries of connections the pool has since
+	// dropped stay until the pool is replaced.
+	sessions sync.Map
+}
+
+func (d *sqlDriver) open(driverName, dsn string) error {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+	d.use(db)
+	return nil
+}
+
+// throughTunnel opens conn's SSH tunnel, if it has one, and returns the
+// connection rewritten to dial the tunnel's local end
+func (d *sqlDriver) throughTunnel(ctx context.Context, conn types.Connection) (types.Connection, error) {
+	if d.tunnel != nil {
+		d.tunnel.Close()
+		d.tunnel = nil
+	}
+	if !conn.SSH.Enabled {
+		return conn, nil
+	}
+
+	tunnel, err := OpenTunnel(ctx, conn)
+	if err != nil {
+		return conn, err
+	}
+	d.tunnel = tunnel
+	return tunnel.Rewrite(conn), nil
+}
+
+// use replaces the underlying pool, closing any previous one
+func (d *sqlDriver) use(db *sql.DB) {
+	if d.db != nil {
+		d.db.Close()
+	}
+	d.db = db
+	d.sessions.Clear()
+}
+
+func (d *sqlDriver) Ping(ctx context.Context) error {
+	if d.db == nil {
+		return ErrNotOpen
+	}
+	return d.db.PingContext(ctx)
+}
+
+func (d *sqlDriver) Query(ctx context.Context, query string, args ...any) (*Result, error) {
+	result := &Result{}
+	err := d.Stream(ctx, query, func(columns []string) error {
+		result.Columns = columns
+		return nil
+	}, func(row []any) error {
+		result.Rows = append(result.Rows, row)
+		return nil
+	}, args...)
+	if err != nil {
+		return nil, err
+	}
+	result.RowsAffected = int64(len(result.Rows))
+	return result, nil
+}
+
+func (d *sqlDriver) Stream(ctx context.Context, query string, columns func([]string) error, row func([]any) error, args ...any) error {
+	return d.guard(ctx, func(conn *sql.Conn) error {
+		return stream(ctx, conn, query, columns, row, args...)
+	})
+}
+
+// stream runs a query on conn, handing over its rows as they are read
+func stream(ctx context.Context, conn *sql.Conn, query string, columns func([]string) error, row func([]any) error, args ...any) error {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if err := columns(names); err != nil {
+		return err
+	}
+	binary, err := binaryColumns(rows)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values, err := scanRow(rows, binary)
+		if err != nil {
+			return err
+		}
+		if err := row(values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (d *sqlDriver) Exec(ctx context.Context, query string, args ...any) (*Result, error) {
+	var res sql.Result
+	err := d.guard(ctx, func(conn *sql.Conn) error {
+		var err error
+		res, err = conn.ExecContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		// Not every statement reports affected rows (DDL, for instance)
+		affected = 0
+	}
+	return &Result{RowsAffected: affected}, nil
+}
+
+func (d *sqlDriver) Close() error {
+	var err error
+	if d.db != nil {
+		err = d.db.Close()
+		d.db = nil
+	}
+	if d.tunnel != nil {
+		d.tunnel.Close()
+		d.tunnel = nil
+	}
+	return err
+}
+
+// queryString runs a query that returns a single text value
+func (d *sqlDriver) queryString(ctx context.Context, query string) (string, error) {
+	if d.db == nil {
+		return "", ErrNotOpen
+	}
+	var value sql.NullString
+	if err := d.db.QueryRowContext(ctx, query).Scan(&value); err != nil {
+		return "", err
+	}
+	return value.String, nil
+}
+
+// queryStrings runs a query and collects the first column of every row
+func (d *sqlDriver) queryStrings(ctx context.Context, query string) ([]string, error) {
+	result, err := d.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		if s, ok := row[0].(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values, nil
+}
+
+// Column types whose values are bytes rather than text
+var binaryTypes = map[string]bool{
+	"BYTEA":      true,
+	"BINARY":     true,
+	"VARBINARY":  true,
+	"BLOB":       true,
+	"TINYBLOB":   true,
+	"MEDIUMBLOB": true,
+	"LONGBLOB":   true,
+}
+
+// binaryColumns marks the columns holding bytes. SQLite gives expressions
+// no type, but only hands back bytes for its blobs.
+func binaryColumns(rows *sql.Rows) ([]bool, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	binary := make([]bool, len(columnTypes))
+	for i, columnType := range columnTypes {
+		name := strings.ToUpper(columnType.DatabaseTypeName())
+		binary[i] = binaryTypes[name] || name == ""
+	}
+	return binary, nil
+}
+
+// scanRow reads the current row into normalised values
+func scanRow(rows *sql.Rows, binary []bool) ([]any, error) {
+	values := make([]any, len(binary))
+	pointers := make([]any, len(binary))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		// Drivers hand back text columns as bytes too; keep them printable
+		if b, ok := value.([]byte); ok && !binary[i] {
+			values[i] = string(b)
+		}
+	}
+	return values, nil
+}
+
+// introspect reads the columns of every table with columnsQuery (see
+// introspectColumns), then the schema names and the (schema, name) pairs of
+// functions with their own queries
+func (d *sqlDriver) introspect(ctx context.Context, columnsQuery, schemasQuery, functionsQuery string) (*Schema, error) {
+	schema, err := introspectColumns(ctx, d.db, columnsQuery)
+	if err != nil {
+		return nil, err
+	}
+	if schema.Schemas, err = d.queryStrings(ctx, schemasQuery); err != nil {
+		return nil, err
+	}
+
+	functions, err := d.Query(ctx, functionsQuery)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range functions.Rows {
+		schemaName, _ := row[0].(string)
+		name, _ := row[1].(string)
+		schema.Functions = append(schema.Functions, Function{Schema: schemaName, Name: name})
+	}
+	return schema, nil
+}
+
+// introspectColumns builds a schema from rows of (schema, table, kind,
+// column, type, nullable, primary key), ordered by table then column position
+func introspectColumns(ctx context.Context, db *sql.DB, query string, args ...any) (*Schema, error) {
+	if db == nil {
+		return nil, ErrNotOpen
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schema := &Schema{}
+	for rows.Next() {
+		var (
+			schemaName, tableName, kind, columnName, columnType string
+			nullable, primaryKey                                bool
+		)
+		if err := rows.Scan(&schemaName, &tableName, &kind, &columnName, &columnType, &nullable, &primaryKey); err != nil {
+			return nil, err
+		}
+
+		n := len(schema.Tables)
+		if n == 0 || schema.Tables[n-1].Schema != schemaName || schema.Tables[n-1].Name != tableName {
+			tableKind := KindTable
+			if kind == "view" {
+				tableKind = KindView
+			}
+			schema.Tables = append(schema.Tables, Table{Schema: schemaName, Name: tableName, Kind: tableKind})
+			n++
+		}
+		schema.Tables[n-1].Columns = append(schema.Tables[n-1].Columns, Column{
+			Name:       columnName,
+			Type:       columnType,
+			Nullable:   nullable,
+			PrimaryKey: primaryKey,
+		})
+	}
+	return schema, rows.Err()
+}
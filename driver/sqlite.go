@@ -0,0 +1,141 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nectar/syntax"
+	"nectar/types"
+	"net/url"
+	"os"
+
+	_ "modernc.org/sqlite"
+)
+
+type sqliteDriver struct {
+	sqlDriver
+}
+
+func (d *sqliteDriver) Open(ctx context.Context, conn types.Connection) error {
+	if conn.DatabaseFile == "" {
+		return errors.New("no database file selected")
+	}
+	// SQLite happily creates missing files; a typo should be an error instead
+	if _, err := os.Stat(conn.DatabaseFile); err != nil {
+		return fmt.Errorf("opening database file: %w", err)
+	}
+	return d.open("sqlite", sqliteDSN(conn))
+}
+
+func (d *sqliteDriver) ServerVersion(ctx context.Context) (string, error) {
+	return d.queryString(ctx, "SELECT sqlite_version()")
+}
+
+// Each attached database file is a schema, "main" being the one opened
+const sqliteSchemas = "SELECT name FROM pragma_database_list ORDER BY seq"
+
+func (d *sqliteDriver) Databases(ctx context.Context) ([]string, error) {
+	return d.queryStrings(ctx, sqliteSchemas)
+}
+
+func (d *sqliteDriver) Schemas(ctx context.Context) ([]string, error) {
+	return d.queryStrings(ctx, sqliteSchemas)
+}
+
+func (d *sqliteDriver) Introspect(ctx context.Context) (*Schema, error) {
+	// SQLite has no stored functions, so the built-in ones are listed
+	return d.introspect(ctx, `
+		SELECT 'main', m.name, m.type, p.name, p.type, p."notnull" = 0, p.pk > 0
+		FROM sqlite_master m
+		JOIN pragma_table_info(m.name) p
+		WHERE m.type IN ('table', 'view') AND m.name NOT LIKE 'sqlite_%'
+		ORDER BY m.name, p.cid`, sqliteSchemas, `
+		SELECT DISTINCT '', name FROM pragma_function_list WHERE name GLOB '[a-z_]*' ORDER BY name`)
+}
+
+func (d *sqliteDriver) Objects(ctx context.Context, kind ObjectKind, schema, table string) ([]Object, error) {
+	// The schema can't be a parameter where it qualifies sqlite_master
+	master := syntax.QuoteIdentifier(schema, types.SQLite) + ".sqlite_master"
+	switch kind {
+	case ObjectTable, ObjectView:
+		return d.queryObjects(ctx, kind, schema, "", `
+			SELECT name, '' FROM `+master+`
+			WHERE type = ? AND name NOT LIKE 'sqlite\_%' ESCAPE '\'
+			ORDER BY 1`, kind.String())
+	case ObjectTrigger:
+		triggers, err := d.queryObjects(ctx, kind, schema, "", `
+			SELECT name, tbl_name FROM `+master+` WHERE type = 'trigger' ORDER BY 1`)
+		for i := range triggers {
+			triggers[i].Table = triggers[i].Detail
+		}
+		return triggers, err
+	case ObjectColumn:
+		return d.queryObjects(ctx, kind, schema, table, `
+			SELECT name, type FROM pragma_table_info(?, ?) ORDER BY cid`, table, schema)
+	case ObjectIndex:
+		return d.queryObjects(ctx, kind, schema, table, `
+			SELECT name, CASE origin WHEN 'pk' THEN 'primary key' WHEN 'u' THEN 'unique' ELSE '' END
+			FROM pragma_index_list(?, ?)
+			ORDER BY 1`, table, schema)
+	case ObjectConstraint:
+		// Constraints aren't catalogued beyond keys, so those are listed
+		return d.queryObjects(ctx, kind, schema, table, `
+			SELECT 'PRIMARY KEY', group_concat(name, ', ')
+			FROM pragma_table_info(?1, ?2)
+			WHERE pk > 0
+			HAVING count(*) > 0
+			UNION ALL
+			SELECT 'FOREIGN KEY ' || (id + 1), group_concat("from", ', ') || ' → ' || "table"
+			FROM pragma_foreign_key_list(?1, ?2)
+			GROUP BY id`, table, schema)
+	}
+	return nil, nil
+}
+
+// An INTEGER PRIMARY KEY is the rowid and has no index of its own, so the
+// primary key is read from the columns before the unique indexes. Indexes
+// on expressions are left out.
+func (d *sqliteDriver) Keys(ctx context.Context, schema, table string) ([][]string, error) {
+	keys, err := d.queryKeys(ctx, `
+		SELECT 'primary key', name FROM pragma_table_info(?, ?) WHERE pk > 0 ORDER BY pk`, table, schema)
+	if err != nil {
+		return nil, err
+	}
+	unique, err := d.queryKeys(ctx, `
+		SELECT il.name, ii.name
+		FROM pragma_index_list(?, ?) il
+		JOIN pragma_index_info(il.name, ?) ii
+		WHERE il."unique" AND il.origin <> 'pk' AND il.partial = 0
+			AND NOT EXISTS (SELECT 1 FROM pragma_index_xinfo(il.name, ?) x WHERE x.key AND x.cid = -2)
+		ORDER BY il.name, ii.seqno`, table, schema, schema, schema)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, unique...), nil
+}
+
+// DDL is the statement SQLite keeps in sqlite_master. Columns and
+// constraints are part of their table's.
+func (d *sqliteDriver) DDL(ctx context.Context, object Object) (string, error) {
+	name := object.Name
+	switch object.Kind {
+	case ObjectTable, ObjectView, ObjectTrigger, ObjectIndex:
+	case ObjectColumn, ObjectConstraint:
+		name = object.Table
+	default:
+		return "", ErrNoDDL
+	}
+	ddl, err := d.queryDDL(ctx, 0, `
+		SELECT sql FROM `+syntax.QuoteIdentifier(object.Schema, types.SQLite)+`.sqlite_master WHERE name = ?`, name)
+	if err != nil {
+		return "", err
+	}
+	return ddl + ";", nil
+}
+
+// sqliteDSN writes the file as a URI path, escaped so a ? or # in its name
+// isn't taken for the start of the parameters
+func sqliteDSN(conn types.Connection) string {
+	path := (&url.URL{Path: conn.DatabaseFile}).EscapedPath()
+	return "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+}
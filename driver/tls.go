@@ -0,0 +1,87 @@
+package driver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"nectar/types"
+	"os"
+)
+
+// tlsConfig builds the client TLS settings for conn, following libpq's
+// sslmode semantics. It returns nil when TLS is disabled.
+func tlsConfig(conn types.Connection) (*tls.Config, error) {
+	settings := conn.TLS
+	if settings.Mode == types.TLSDisable {
+		return nil, nil
+	}
+
+	config := &tls.Config{ServerName: settings.ServerName}
+	if config.ServerName == "" {
+		config.ServerName = hostOrDefault(conn)
+	}
+
+	if settings.CAFile != "" {
+		pem, err := os.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificate: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", settings.CAFile)
+		}
+	}
+
+	if settings.CertFile != "" || settings.KeyFile != "" {
+		if settings.CertFile == "" || settings.KeyFile == "" {
+			return nil, errors.New("client certificate and key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	mode := settings.Mode
+	// Like libpq, require with a CA file present verifies the chain
+	if mode == types.TLSRequire && settings.CAFile != "" {
+		mode = types.TLSVerifyCA
+	}
+
+	switch mode {
+	case types.TLSPrefer, types.TLSRequire:
+		config.InsecureSkipVerify = true
+	case types.TLSVerifyCA:
+		// Go can't verify the chain without the host name, so do it by hand
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = verifyChain(config.RootCAs)
+	}
+	return config, nil
+}
+
+// verifyChain checks the server certificate against roots (the system pool
+// when nil) while ignoring the host name, as verify-ca requires
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server sent no certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
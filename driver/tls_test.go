@@ -0,0 +1,223 @@
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"nectar/types"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA is a certificate authority made up for a test, with the PEM files
+// of what it signs written to dir
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+	// file is the CA certificate's PEM file
+	file string
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &testCA{cert: cert, key: key, dir: t.TempDir()}
+	ca.file = ca.write(t, name+".crt", "CERTIFICATE", der)
+	return ca
+}
+
+// issue signs a leaf certificate for the DNS names, usable by servers and
+// clients alike, and returns it with the paths of its PEM files
+func (ca *testCA) issue(t *testing.T, name string, dnsNames ...string) (tls.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := ca.write(t, name+".crt", "CERTIFICATE", der)
+	keyFile := ca.write(t, name+".key", "EC PRIVATE KEY", keyDER)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, certFile, keyFile
+}
+
+func (ca *testCA) write(t *testing.T, name, kind string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(ca.dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// handshake runs a TLS handshake between a local server using serverConfig
+// and a client configured for conn, returning the client's error
+func handshake(t *testing.T, conn types.Connection, serverConfig *tls.Config) error {
+	t.Helper()
+	config, err := tlsConfig(conn)
+	if err != nil {
+		return err
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		server, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer server.Close()
+		server.SetDeadline(time.Now().Add(5 * time.Second))
+		server.(*tls.Conn).Handshake()
+	}()
+
+	raw, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	raw.SetDeadline(time.Now().Add(5 * time.Second))
+	client := tls.Client(raw, config)
+	if err := client.Handshake(); err != nil {
+		return err
+	}
+	// A rejected client certificate only shows once the server replies
+	_, err = client.Read(make([]byte, 1))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func TestTLSModes(t *testing.T) {
+	ca := newTestCA(t, "nectar test CA")
+	other := newTestCA(t, "someone else's CA")
+	serverCert, _, _ := ca.issue(t, "server", "db.internal")
+	server := &tls.Config{Certificates: []tls.Certificate{serverCert}}
+
+	// The server is dialled by address, which its certificate doesn't name
+	local := types.Connection{Type: types.PostgreSQL, Host: "127.0.0.1"}
+	with := func(settings types.TLSConfig) types.Connection {
+		conn := local
+		conn.TLS = settings
+		return conn
+	}
+
+	tests := []struct {
+		name string
+		conn types.Connection
+		ok   bool
+	}{
+		{"require trusts any certificate", with(types.TLSConfig{Mode: types.TLSRequire}), true},
+		{"prefer trusts any certificate", with(types.TLSConfig{Mode: types.TLSPrefer}), true},
+		{"require with a CA file verifies the chain", with(types.TLSConfig{Mode: types.TLSRequire, CAFile: other.file}), false},
+		{"verify-ca ignores the host name", with(types.TLSConfig{Mode: types.TLSVerifyCA, CAFile: ca.file}), true},
+		{"verify-ca rejects another CA", with(types.TLSConfig{Mode: types.TLSVerifyCA, CAFile: other.file}), false},
+		{"verify-full checks the host name", with(types.TLSConfig{Mode: types.TLSVerifyFull, CAFile: ca.file}), false},
+		{"verify-full with the server name overridden", with(types.TLSConfig{Mode: types.TLSVerifyFull, CAFile: ca.file, ServerName: "db.internal"}), true},
+		{"verify-full rejects another CA", with(types.TLSConfig{Mode: types.TLSVerifyFull, CAFile: other.file, ServerName: "db.internal"}), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := handshake(t, test.conn, server)
+			if test.ok && err != nil {
+				t.Fatalf("handshake failed: %v", err)
+			}
+			if !test.ok && err == nil {
+				t.Fatal("handshake succeeded, want it rejected")
+			}
+		})
+	}
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	ca := newTestCA(t, "nectar test CA")
+	serverCert, _, _ := ca.issue(t, "server", "db.internal")
+	_, certFile, keyFile := ca.issue(t, "client")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	server := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    roots,
+	}
+	settings := types.TLSConfig{Mode: types.TLSVerifyFull, CAFile: ca.file, ServerName: "db.internal"}
+	conn := types.Connection{Type: types.PostgreSQL, Host: "127.0.0.1", TLS: settings}
+
+	if err := handshake(t, conn, server); err == nil {
+		t.Error("handshake without a client certificate succeeded, want it rejected")
+	}
+
+	conn.TLS.CertFile, conn.TLS.KeyFile = certFile, keyFile
+	if err := handshake(t, conn, server); err != nil {
+		t.Errorf("handshake with a client certificate failed: %v", err)
+	}
+
+	conn.TLS.KeyFile = ""
+	if _, err := tlsConfig(conn); err == nil {
+		t.Error("a certificate without its key was accepted")
+	}
+}
+
+func TestTLSDisabled(t *testing.T) {
+	config, err := tlsConfig(types.Connection{Type: types.PostgreSQL, TLS: types.TLSConfig{Mode: types.TLSDisable}})
+	if err != nil || config != nil {
+		t.Fatalf("tlsConfig = %v, %v; want nil, nil", config, err)
+	}
+}
@@ -0,0 +1,292 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"nectar/types"
+	"nectar/utils"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Tunnel forwards a local port on the loopback interface to the database
+// host through an SSH bastion
+type Tunnel struct {
+	client   *ssh.Client
+	listener net.Listener
+	remote   string
+	wg       sync.WaitGroup
+	// agent and session stay open while the bastion may use the forwarded agent
+	agent   net.Conn
+	session *ssh.Session
+}
+
+// OpenTunnel connects to conn's SSH bastion and starts forwarding a local
+// port to the database host as seen from the bastion
+func OpenTunnel(ctx context.Context, conn types.Connection) (*Tunnel, error) {
+	config, agentConn, err := sshClientConfig(conn.SSH)
+	if err != nil {
+		return nil, err
+	}
+	release := func() {
+		if agentConn != nil {
+			agentConn.Close()
+		}
+	}
+
+	port := conn.SSH.Port
+	if port == "" {
+		port = utils.DefaultSSHPort
+	}
+	addr := net.JoinHostPort(conn.SSH.Host, port)
+
+	var dialer net.Dialer
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// The SSH handshake has no context support, so bound it by the deadline
+	if deadline, ok := ctx.Deadline(); ok {
+		netConn.SetDeadline(deadline)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, config)
+	if err != nil {
+		netConn.Close()
+		release()
+		return nil, fmt.Errorf("ssh %s: %w", addr, err)
+	}
+	netConn.SetDeadline(time.Time{})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		sshConn.Close()
+		release()
+		return nil, err
+	}
+
+	t := &Tunnel{
+		client:   ssh.NewClient(sshConn, chans, reqs),
+		listener: listener,
+		remote:   net.JoinHostPort(hostOrDefault(conn), portOrDefault(conn)),
+		agent:    agentConn,
+	}
+	if conn.SSH.ForwardAgent {
+		t.forwardAgent()
+	}
+	go t.serve()
+	return t, nil
+}
+
+// forwardAgent offers the local agent to the bastion, so it can log in to
+// further hosts with the user's keys. A bastion that doesn't allow agent
+// forwarding still makes a working tunnel, so refusals are ignored.
+func (t *Tunnel) forwardAgent() {
+	if err := agent.ForwardToAgent(t.client, agent.NewClient(t.agent)); err != nil {
+		return
+	}
+	session, err := t.client.NewSession()
+	if err != nil {
+		return
+	}
+	if err := agent.RequestAgentForwarding(session); err != nil {
+		session.Close()
+		return
+	}
+	t.session = session
+}
+
+// Rewrite returns conn pointed at the tunnel's local end. TLS keeps
+// verifying against the real host name.
+func (t *Tunnel) Rewrite(conn types.Connection) types.Connection {
+	if conn.TLS.ServerName == "" {
+		conn.TLS.ServerName = hostOrDefault(conn)
+	}
+	host, port, _ := net.SplitHostPort(t.listener.Addr().String())
+	conn.Host, conn.Port = host, port
+	conn.SSH = types.SSHTunnel{}
+	return conn
+}
+
+// Check dials the database host through the bastion once, so a host the
+// bastion can't reach is reported before any database traffic is sent
+func (t *Tunnel) Check() error {
+	remote, err := t.client.Dial("tcp", t.remote)
+	if err != nil {
+		return fmt.Errorf("bastion cannot reach %s: %w", t.remote, err)
+	}
+	return remote.Close()
+}
+
+func (t *Tunnel) Close() error {
+	err := t.listener.Close()
+	if t.session != nil {
+		t.session.Close()
+	}
+	t.client.Close()
+	if t.agent != nil {
+		t.agent.Close()
+	}
+	t.wg.Wait()
+	return err
+}
+
+func (t *Tunnel) serve() {
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		t.wg.Add(1)
+		go t.forward(local)
+	}
+}
+
+func (t *Tunnel) forward(local net.Conn) {
+	defer t.wg.Done()
+	defer local.Close()
+
+	remote, err := t.client.Dial("tcp", t.remote)
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(remote, local)
+		remote.Close()
+		close(done)
+	}()
+	io.Copy(local, remote)
+	local.Close()
+	<-done
+}
+
+// sshClientConfig builds the client settings for a tunnel. With UseAgent or
+// ForwardAgent it also returns the connection to the agent, which the caller
+// closes.
+func sshClientConfig(tunnel types.SSHTunnel) (*ssh.ClientConfig, net.Conn, error) {
+	if tunnel.Host == "" {
+		return nil, nil, errors.New("SSH tunnel has no host")
+	}
+
+	if tunnel.ForwardAgent && tunnel.SkipHostKeyCheck {
+		return nil, nil, errors.New("SSH agent forwarding needs the bastion's host key to be verified")
+	}
+	hostKeyCallback, err := hostKeyCallback(tunnel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var auth []ssh.AuthMethod
+	var agentConn net.Conn
+	if tunnel.UseAgent || tunnel.ForwardAgent {
+		agentConn, err = dialAgent()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if tunnel.UseAgent {
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+	}
+	if tunnel.KeyFile != "" {
+		signer, err := loadPrivateKey(tunnel.KeyFile, tunnel.Password)
+		if err != nil {
+			if agentConn != nil {
+				agentConn.Close()
+			}
+			return nil, nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if tunnel.Password != "" && tunnel.KeyFile == "" {
+		password := tunnel.Password
+		auth = append(auth,
+			ssh.Password(password),
+			ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}),
+		)
+	}
+	if len(auth) == 0 {
+		return nil, nil, errors.New("SSH tunnel needs a password, private key or agent")
+	}
+
+	return &ssh.ClientConfig{
+		User:            tunnel.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	}, agentConn, nil
+}
+
+func hostKeyCallback(tunnel types.SSHTunnel) (ssh.HostKeyCallback, error) {
+	if tunnel.SkipHostKeyCheck {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	path := tunnel.KnownHostsFile
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading known hosts: %w", err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return fmt.Errorf("host key for %s is not in %s", hostname, path)
+		}
+		return err
+	}, nil
+}
+
+// dialAgent connects to the running ssh-agent, which signs during the
+// handshake and afterwards answers the bastion's forwarded requests
+func dialAgent() (net.Conn, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("SSH agent requested but SSH_AUTH_SOCK is not set")
+	}
+	return net.Dial("unix", socket)
+}
+
+func loadPrivateKey(path, passphrase string) (ssh.Signer, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading private key: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(pem)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, errors.New("private key is encrypted; enter its passphrase as the SSH password")
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	return signer, nil
+}
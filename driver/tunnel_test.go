@@ -0,0 +1,421 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"nectar/types"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testBastion is an in-process SSH server on the loopback interface that
+// accepts one user key and forwards TCP connections
+type testBastion struct {
+	addr    string
+	hostKey ssh.Signer
+	// agentKeys receives the keys listed through each forwarded agent
+	agentKeys chan []*agent.Key
+	// agentRequests counts the agent forwarding requests received
+	agentRequests atomic.Int32
+}
+
+func newTestBastion(t *testing.T, userKey ssh.PublicKey) *testBastion {
+	t.Helper()
+	b := &testBastion{hostKey: newTestSigner(t), agentKeys: make(chan []*agent.Key, 1)}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), userKey.Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(b.hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	b.addr = listener.Addr().String()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn, config)
+		}
+	}()
+	return b
+}
+
+func (b *testBastion) serve(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	serverConn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "direct-tcpip":
+			go forwardChannel(newChannel)
+		case "session":
+			go b.session(serverConn, newChannel)
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+		}
+	}
+}
+
+// forwardChannel dials the host a direct-tcpip channel asks for and copies
+// between the two
+func forwardChannel(newChannel ssh.NewChannel) {
+	var target struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	remote, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	defer remote.Close()
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	go ssh.DiscardRequests(reqs)
+
+	go func() {
+		io.Copy(remote, channel)
+		remote.Close()
+	}()
+	io.Copy(channel, remote)
+}
+
+// session accepts agent forwarding and lists the keys of the forwarded agent
+func (b *testBastion) session(serverConn *ssh.ServerConn, newChannel ssh.NewChannel) {
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	for req := range reqs {
+		if req.Type != "auth-agent-req@openssh.com" {
+			req.Reply(false, nil)
+			continue
+		}
+		b.agentRequests.Add(1)
+		req.Reply(true, nil)
+		go func() {
+			agentChannel, agentReqs, err := serverConn.OpenChannel("auth-agent@openssh.com", nil)
+			if err != nil {
+				b.agentKeys <- nil
+				return
+			}
+			defer agentChannel.Close()
+			go ssh.DiscardRequests(agentReqs)
+			keys, _ := agent.NewClient(agentChannel).List()
+			b.agentKeys <- keys
+		}()
+	}
+}
+
+// knownHosts writes a known_hosts file listing key for the bastion
+func (b *testBastion) knownHosts(t *testing.T, key ssh.PublicKey) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(b.addr)}, key)
+	if err := os.WriteFile(path, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func (b *testBastion) tunnel(knownHosts string) types.SSHTunnel {
+	host, port, _ := net.SplitHostPort(b.addr)
+	return types.SSHTunnel{Enabled: true, Host: host, Port: port, User: "nectar", KnownHostsFile: knownHosts}
+}
+
+func newTestKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	signer, err := ssh.NewSignerFromKey(newTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// writeTestKey saves key in the OpenSSH format and returns the file's path
+func writeTestKey(t *testing.T, key ed25519.PrivateKey) string {
+	t.Helper()
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// echoServer stands in for the database behind the bastion
+func echoServer(t *testing.T) (string, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return host, port
+}
+
+func openTestTunnel(t *testing.T, conn types.Connection) (*Tunnel, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tunnel, err := OpenTunnel(ctx, conn)
+	if err == nil {
+		t.Cleanup(func() { tunnel.Close() })
+	}
+	return tunnel, err
+}
+
+func TestTunnelForwardsPort(t *testing.T) {
+	key := newTestKey(t)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bastion := newTestBastion(t, signer.PublicKey())
+	host, port := echoServer(t)
+
+	conn := types.Connection{Type: types.PostgreSQL, Host: host, Port: port}
+	conn.SSH = bastion.tunnel(bastion.knownHosts(t, bastion.hostKey.PublicKey()))
+	conn.SSH.KeyFile = writeTestKey(t, key)
+
+	tunnel, err := openTestTunnel(t, conn)
+	if err != nil {
+		t.Fatalf("OpenTunnel: %v", err)
+	}
+	if err := tunnel.Check(); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	local := tunnel.Rewrite(conn)
+	if local.SSH.Enabled || local.TLS.ServerName != host {
+		t.Errorf("Rewrite = %+v, want the tunnel dropped and TLS verifying %s", local, host)
+	}
+	client, err := net.Dial("tcp", net.JoinHostPort(local.Host, local.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(client, reply); err != nil {
+		t.Fatal(err)
+	}
+	if string(reply) != "ping" {
+		t.Errorf("read %q through the tunnel, want %q", reply, "ping")
+	}
+}
+
+func TestTunnelChecksHostKey(t *testing.T) {
+	key := newTestKey(t)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bastion := newTestBastion(t, signer.PublicKey())
+	keyFile := writeTestKey(t, key)
+
+	empty := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		knownHosts string
+		want       string
+	}{
+		{"unknown host", empty, "is not in"},
+		{"changed key", bastion.knownHosts(t, newTestSigner(t).PublicKey()), "key mismatch"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := types.Connection{Type: types.PostgreSQL, Host: "127.0.0.1", Port: "5432"}
+			conn.SSH = bastion.tunnel(test.knownHosts)
+			conn.SSH.KeyFile = keyFile
+			_, err := openTestTunnel(t, conn)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("OpenTunnel = %v, want an error mentioning %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestTunnelRejectsWrongKey(t *testing.T) {
+	bastion := newTestBastion(t, newTestSigner(t).PublicKey())
+	conn := types.Connection{Type: types.PostgreSQL, Host: "127.0.0.1", Port: "5432"}
+	conn.SSH = bastion.tunnel(bastion.knownHosts(t, bastion.hostKey.PublicKey()))
+	conn.SSH.KeyFile = writeTestKey(t, newTestKey(t))
+
+	if _, err := openTestTunnel(t, conn); err == nil {
+		t.Fatal("OpenTunnel succeeded with a key the bastion doesn't accept")
+	}
+}
+
+// startTestAgent serves an ssh-agent holding key on a socket named by
+// SSH_AUTH_SOCK for the rest of the test
+func startTestAgent(t *testing.T, key ed25519.PrivateKey) {
+	t.Helper()
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+}
+
+func TestTunnelForwardsAgent(t *testing.T) {
+	key := newTestKey(t)
+	startTestAgent(t, key)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bastion := newTestBastion(t, signer.PublicKey())
+	conn := types.Connection{Type: types.PostgreSQL, Host: "127.0.0.1", Port: "5432"}
+	conn.SSH = bastion.tunnel(bastion.knownHosts(t, bastion.hostKey.PublicKey()))
+	conn.SSH.UseAgent = true
+	conn.SSH.ForwardAgent = true
+
+	if _, err := openTestTunnel(t, conn); err != nil {
+		t.Fatalf("OpenTunnel: %v", err)
+	}
+	select {
+	case keys := <-bastion.agentKeys:
+		if len(keys) != 1 || !bytes.Equal(keys[0].Marshal(), signer.PublicKey().Marshal()) {
+			t.Errorf("bastion saw agent keys %v, want the user's key", keys)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the bastion was never offered the agent")
+	}
+}
+
+func TestTunnelKeepsAgentByDefault(t *testing.T) {
+	key := newTestKey(t)
+	startTestAgent(t, key)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bastion := newTestBastion(t, signer.PublicKey())
+	host, port := echoServer(t)
+	conn := types.Connection{Type: types.PostgreSQL, Host: host, Port: port}
+	conn.SSH = bastion.tunnel(bastion.knownHosts(t, bastion.hostKey.PublicKey()))
+	conn.SSH.UseAgent = true
+
+	tunnel, err := openTestTunnel(t, conn)
+	if err != nil {
+		t.Fatalf("OpenTunnel: %v", err)
+	}
+	// Check takes a round trip through the bastion, by which time a
+	// forwarding request sent while opening the tunnel has arrived
+	if err := tunnel.Check(); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if n := bastion.agentRequests.Load(); n != 0 {
+		t.Fatalf("bastion received %d agent forwarding requests, want none", n)
+	}
+}
+
+func TestTunnelRefusesAgentToUnverifiedHost(t *testing.T) {
+	key := newTestKey(t)
+	startTestAgent(t, key)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bastion := newTestBastion(t, signer.PublicKey())
+	conn := types.Connection{Type: types.PostgreSQL, Host: "127.0.0.1", Port: "5432"}
+	conn.SSH = bastion.tunnel("")
+	conn.SSH.UseAgent = true
+	conn.SSH.ForwardAgent = true
+	conn.SSH.SkipHostKeyCheck = true
+
+	if _, err := openTestTunnel(t, conn); err == nil {
+		t.Fatal("OpenTunnel forwarded the agent to a bastion whose host key isn't checked")
+	}
+	if n := bastion.agentRequests.Load(); n != 0 {
+		t.Fatalf("bastion received %d agent forwarding requests, want none", n)
+	}
+}
@@ -0,0 +1,263 @@
+// Package export writes result sets out as files: delimited text, JSON,
+// Markdown tables or INSERT statements. Rows are written as they are read,
+// so a result never has to fit in memory.
+package export
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"nectar/driver"
+	"nectar/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// Format is the kind of file a result is written as
+type Format int
+
+const (
+	CSV Format = iota
+	TSV
+	JSON
+	NDJSON
+	Markdown
+	Insert
+)
+
+// Formats lists the formats in the order the export form cycles them
+var Formats = []Format{CSV, TSV, JSON, NDJSON, Markdown, Insert}
+
+func (f Format) String() string {
+	switch f {
+	case CSV:
+		return "CSV"
+	case TSV:
+		return "TSV"
+	case JSON:
+		return "JSON"
+	case NDJSON:
+		return "NDJSON"
+	case Markdown:
+		return "Markdown"
+	case Insert:
+		return "SQL INSERT"
+	default:
+		return "unknown"
+	}
+}
+
+// Extension is the file extension usual for the format
+func (f Format) Extension() string {
+	switch f {
+	case CSV:
+		return ".csv"
+	case TSV:
+		return ".tsv"
+	case JSON:
+		return ".json"
+	case NDJSON:
+		return ".ndjson"
+	case Markdown:
+		return ".md"
+	case Insert:
+		return ".sql"
+	default:
+		return ""
+	}
+}
+
+// Delimited reports whether the format is delimited text, where quoting
+// and the header line can be chosen
+func (f Format) Delimited() bool {
+	return f == CSV || f == TSV
+}
+
+// ParseFormat finds a format by its name or extension, ignoring case
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "."))
+	for _, f := range Formats {
+		if name == strings.ToLower(f.String()) || name == strings.TrimPrefix(f.Extension(), ".") {
+			return f, nil
+		}
+	}
+	if name == "insert" {
+		return Insert, nil
+	}
+	return 0, fmt.Errorf("unknown format %q", name)
+}
+
+// Quoting is when delimited text puts a field in double quotes
+type Quoting int
+
+const (
+	// QuoteMinimal quotes only fields that would otherwise be misread
+	QuoteMinimal Quoting = iota
+	// QuoteNonNumeric quotes every field but numbers and NULL
+	QuoteNonNumeric
+	// QuoteAll quotes every field but NULL
+	QuoteAll
+)
+
+// Quotings lists the quoting modes in the order the export form cycles them
+var Quotings = []Quoting{QuoteMinimal, QuoteNonNumeric, QuoteAll}
+
+func (q Quoting) String() string {
+	switch q {
+	case QuoteMinimal:
+		return "when needed"
+	case QuoteNonNumeric:
+		return "non-numeric"
+	case QuoteAll:
+		return "all"
+	default:
+		return "unknown"
+	}
+}
+
+// Options says how a result is written
+type Options struct {
+	Format Format
+	// Quoting and NoHeader apply to CSV and TSV
+	Quoting  Quoting
+	NoHeader bool
+	// Table is the table INSERT statements write to, already quoted for
+	// Dialect
+	Table   string
+	Dialect types.ConnectionType
+}
+
+// Writer writes a result in one format: the columns first, then each row,
+// then Close to finish the file
+type Writer interface {
+	Columns(columns []string) error
+	Row(values []any) error
+	Close() error
+}
+
+// NewWriter returns a writer for the format in opts. Output is buffered
+// until Close.
+func NewWriter(w io.Writer, opts Options) Writer {
+	out := bufio.NewWriter(w)
+	switch opts.Format {
+	case TSV:
+		return &delimitedWriter{out: out, delimiter: '\t', quoting: opts.Quoting, header: !opts.NoHeader}
+	case JSON:
+		return &jsonWriter{out: out}
+	case NDJSON:
+		return &jsonWriter{out: out, lines: true}
+	case Markdown:
+		return &markdownWriter{out: out}
+	case Insert:
+		return &insertWriter{out: out, table: opts.Table, dialect: opts.Dialect}
+	default:
+		return &delimitedWriter{out: out, delimiter: ',', quoting: opts.Quoting, header: !opts.NoHeader}
+	}
+}
+
+// Progress counts the rows written by a running export. It is safe to read
+// while the export runs.
+type Progress struct {
+	rows atomic.Int64
+}
+
+// Rows returns how many rows have been written so far
+func (p *Progress) Rows() int64 {
+	if p == nil {
+		return 0
+	}
+	return p.rows.Load()
+}
+
+// ToFile runs query again and writes its rows to path as they arrive. The
+// rows go to a temporary file beside path that replaces it only once the
+// export completes, so a failed or cancelled export leaves path as it was.
+// progress, which may be nil, counts the rows as they are written.
+func ToFile(ctx context.Context, d driver.Driver, query string, args []any, path string, opts Options, progress *Progress) (int64, error) {
+	return toFile(path, func(w io.Writer) (int64, error) {
+		return Stream(ctx, d, query, args, w, opts, progress)
+	})
+}
+
+// ResultToFile writes rows that were already read to path, replacing it
+// only once they are all written as ToFile does
+func ResultToFile(ctx context.Context, result *driver.Result, path string, opts Options, progress *Progress) (int64, error) {
+	return toFile(path, func(w io.Writer) (int64, error) {
+		return WriteResult(ctx, result, w, opts, progress)
+	})
+}
+
+// toFile writes to a temporary file beside path and moves it into place
+// once write succeeds
+func toFile(path string, write func(w io.Writer) (int64, error)) (int64, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+
+	rows, err := write(f)
+	if err != nil {
+		f.Close()
+		return rows, err
+	}
+	if err := f.Close(); err != nil {
+		return rows, err
+	}
+	// A file being replaced keeps its permissions
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(f.Name(), mode); err != nil {
+		return rows, err
+	}
+	return rows, os.Rename(f.Name(), path)
+}
+
+// Stream runs query and writes its rows to w in the format in opts,
+// returning how many were written
+func Stream(ctx context.Context, d driver.Driver, query string, args []any, w io.Writer, opts Options, progress *Progress) (int64, error) {
+	writer := NewWriter(w, opts)
+	var count int64
+	err := d.Stream(ctx, query, writer.Columns, func(values []any) error {
+		if err := writer.Row(values); err != nil {
+			return err
+		}
+		count++
+		if progress != nil {
+			progress.rows.Store(count)
+		}
+		return nil
+	}, args...)
+	if err != nil {
+		return count, err
+	}
+	return count, writer.Close()
+}
+
+// WriteResult writes rows that were already read to w in the format in
+// opts, returning how many were written
+func WriteResult(ctx context.Context, result *driver.Result, w io.Writer, opts Options, progress *Progress) (int64, error) {
+	writer := NewWriter(w, opts)
+	if err := writer.Columns(result.Columns); err != nil {
+		return 0, err
+	}
+	var count int64
+	for _, row := range result.Rows {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		if err := writer.Row(row); err != nil {
+			return count, err
+		}
+		count++
+		if progress != nil {
+			progress.rows.Store(count)
+		}
+	}
+	return count, writer.Close()
+}
@@ -0,0 +1,270 @@
+package export
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"nectar/driver"
+	"nectar/syntax"
+	"nectar/types"
+	"strings"
+	"time"
+)
+
+// errNoColumns is returned for a statement that returned no result set to
+// write, such as an UPDATE
+var errNoColumns = errors.New("the statement returned no columns")
+
+// delimitedWriter writes CSV or TSV. NULL is an empty field that is never
+// quoted, so it can be told apart from an empty string under QuoteAll.
+type delimitedWriter struct {
+	out       *bufio.Writer
+	delimiter rune
+	quoting   Quoting
+	header    bool
+}
+
+func (w *delimitedWriter) Columns(columns []string) error {
+	if len(columns) == 0 {
+		return errNoColumns
+	}
+	if !w.header {
+		return nil
+	}
+	fields := make([]any, len(columns))
+	for i, name := range columns {
+		fields[i] = name
+	}
+	return w.Row(fields)
+}
+
+func (w *delimitedWriter) Row(values []any) error {
+	for i, value := range values {
+		if i > 0 {
+			w.out.WriteRune(w.delimiter)
+		}
+		text, null := formatValue(value)
+		if null {
+			continue
+		}
+		if w.quoted(value, text) {
+			w.out.WriteString(`"` + strings.ReplaceAll(text, `"`, `""`) + `"`)
+		} else {
+			w.out.WriteString(text)
+		}
+	}
+	_, err := w.out.WriteString("\n")
+	return err
+}
+
+// quoted reports whether a field goes in quotes
+func (w *delimitedWriter) quoted(value any, text string) bool {
+	switch w.quoting {
+	case QuoteAll:
+		return true
+	case QuoteNonNumeric:
+		if !numeric(value) {
+			return true
+		}
+	}
+	return text == "" || strings.ContainsRune(text, w.delimiter) ||
+		strings.ContainsAny(text, "\"\r\n") || text != strings.TrimSpace(text)
+}
+
+func (w *delimitedWriter) Close() error {
+	return w.out.Flush()
+}
+
+// numeric reports whether a value is a number
+func numeric(value any) bool {
+	switch value.(type) {
+	case int64, float64, float32:
+		return true
+	default:
+		return false
+	}
+}
+
+// jsonWriter writes one object per row, keyed by column, either inside an
+// array or as a line each
+type jsonWriter struct {
+	out     *bufio.Writer
+	lines   bool
+	columns []string
+	rows    int64
+}
+
+func (w *jsonWriter) Columns(columns []string) error {
+	if len(columns) == 0 {
+		return errNoColumns
+	}
+	w.columns = make([]string, len(columns))
+	for i, name := range columns {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		w.columns[i] = string(key)
+	}
+	if !w.lines {
+		w.out.WriteString("[")
+	}
+	return nil
+}
+
+func (w *jsonWriter) Row(values []any) error {
+	switch {
+	case w.lines:
+	case w.rows == 0:
+		w.out.WriteString("\n  ")
+	default:
+		w.out.WriteString(",\n  ")
+	}
+	w.rows++
+
+	// The object is written by hand to keep the columns in their order
+	w.out.WriteString("{")
+	for i, value := range values {
+		if i > 0 {
+			w.out.WriteString(",")
+		}
+		encoded, err := json.Marshal(jsonValue(value))
+		if err != nil {
+			return err
+		}
+		w.out.WriteString(w.columns[i] + ":")
+		w.out.Write(encoded)
+	}
+	w.out.WriteString("}")
+	if w.lines {
+		w.out.WriteString("\n")
+	}
+	return nil
+}
+
+func (w *jsonWriter) Close() error {
+	if !w.lines {
+		if w.rows > 0 {
+			w.out.WriteString("\n")
+		}
+		w.out.WriteString("]\n")
+	}
+	return w.out.Flush()
+}
+
+// jsonValue is a value as JSON can hold it: numbers JSON has no room for,
+// times and binary values are written as text
+func jsonValue(value any) any {
+	switch v := value.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			text, _ := driver.FormatValue(v)
+			return text
+		}
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			text, _ := driver.FormatValue(v)
+			return text
+		}
+	case time.Time, []byte:
+		text, _ := formatValue(v)
+		return text
+	}
+	return value
+}
+
+// formatValue writes a value as text the way the grid does, except binary
+// values, which are written in hex as \x0a1b since their bytes need not be
+// valid UTF-8
+func formatValue(value any) (string, bool) {
+	if b, ok := value.([]byte); ok {
+		return `\x` + hex.EncodeToString(b), false
+	}
+	return driver.FormatValue(value)
+}
+
+// markdownWriter writes a pipe table. Cells aren't padded to a common width
+// since the rows are written before all of them have been seen.
+type markdownWriter struct {
+	out *bufio.Writer
+}
+
+func (w *markdownWriter) Columns(columns []string) error {
+	if len(columns) == 0 {
+		return errNoColumns
+	}
+	cells := make([]string, len(columns))
+	rule := make([]string, len(columns))
+	for i, name := range columns {
+		cells[i] = markdownCell(name)
+		rule[i] = "---"
+	}
+	w.out.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	_, err := w.out.WriteString("| " + strings.Join(rule, " | ") + " |\n")
+	return err
+}
+
+func (w *markdownWriter) Row(values []any) error {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		text, null := formatValue(value)
+		if null {
+			cells[i] = "*NULL*"
+		} else {
+			cells[i] = markdownCell(text)
+		}
+	}
+	_, err := w.out.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	return err
+}
+
+func (w *markdownWriter) Close() error {
+	return w.out.Flush()
+}
+
+// markdownCell keeps text from breaking out of its cell
+func markdownCell(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>").Replace(text)
+}
+
+// insertWriter writes an INSERT statement per row
+type insertWriter struct {
+	out     *bufio.Writer
+	table   string
+	dialect types.ConnectionType
+	prefix  string
+}
+
+func (w *insertWriter) Columns(columns []string) error {
+	if len(columns) == 0 {
+		return errNoColumns
+	}
+	if w.table == "" {
+		return errors.New("a table name is needed for INSERT statements")
+	}
+	quoted := make([]string, len(columns))
+	for i, name := range columns {
+		quoted[i] = syntax.QuoteIdentifier(name, w.dialect)
+	}
+	w.prefix = "INSERT INTO " + w.table + " (" + strings.Join(quoted, ", ") + ") VALUES ("
+	return nil
+}
+
+func (w *insertWriter) Row(values []any) error {
+	literals := make([]string, len(values))
+	for i, value := range values {
+		literal, err := driver.Literal(value, w.dialect)
+		if err != nil {
+			return err
+		}
+		literals[i] = literal
+	}
+	_, err := w.out.WriteString(w.prefix + strings.Join(literals, ", ") + ");\n")
+	return err
+}
+
+func (w *insertWriter) Close() error {
+	return w.out.Flush()
+}
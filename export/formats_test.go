@@ -0,0 +1,209 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"nectar/types"
+	"testing"
+	"unicode/utf8"
+)
+
+// write runs a writer for opts over the columns and rows and returns what
+// it wrote
+func write(t *testing.T, opts Options, columns []string, rows ...[]any) string {
+	t.Helper()
+	var out bytes.Buffer
+	w := NewWriter(&out, opts)
+	if err := w.Columns(columns); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.Row(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestBinaryValues(t *testing.T) {
+	binary := []byte{0xff, 0x00, 0x0a, 0xc3}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{CSV, "data\n\\xff000ac3\n"},
+		{TSV, "data\n\\xff000ac3\n"},
+		{NDJSON, `{"data":"\\xff000ac3"}` + "\n"},
+		{Markdown, "| data |\n| --- |\n| \\\\xff000ac3 |\n"},
+		{Insert, "INSERT INTO \"blobs\" (\"data\") VALUES ('\\xff000ac3'::bytea);\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.format.String(), func(t *testing.T) {
+			opts := Options{Format: test.format, Table: `"blobs"`, Dialect: types.PostgreSQL}
+			got := write(t, opts, []string{"data"}, []any{binary})
+			if !utf8.ValidString(got) {
+				t.Fatalf("wrote invalid UTF-8: %q", got)
+			}
+			if got != test.want {
+				t.Errorf("wrote %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	got := write(t, Options{Format: JSON}, []string{"data"}, []any{binary})
+	var decoded []map[string]string
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatalf("JSON output %q doesn't parse: %v", got, err)
+	}
+	if decoded[0]["data"] != `\xff000ac3` {
+		t.Errorf("JSON holds %q, want %q", decoded[0]["data"], `\xff000ac3`)
+	}
+}
+
+func TestDelimited(t *testing.T) {
+	columns := []string{"id", "note"}
+	tests := []struct {
+		name string
+		opts Options
+		rows [][]any
+		want string
+	}{
+		{"plain", Options{Format: CSV}, [][]any{{int64(1), "a"}},
+			"id,note\n1,a\n"},
+		{"null and empty", Options{Format: CSV}, [][]any{{nil, ""}},
+			"id,note\n,\"\"\n"},
+		{"delimiter", Options{Format: CSV}, [][]any{{int64(1), "a,b"}},
+			"id,note\n1,\"a,b\"\n"},
+		{"quotes", Options{Format: CSV}, [][]any{{int64(1), `say "hi"`}},
+			"id,note\n1,\"say \"\"hi\"\"\"\n"},
+		{"newline", Options{Format: CSV}, [][]any{{int64(1), "a\nb"}},
+			"id,note\n1,\"a\nb\"\n"},
+		{"padding", Options{Format: CSV}, [][]any{{int64(1), " a"}},
+			"id,note\n1,\" a\"\n"},
+		{"no header", Options{Format: CSV, NoHeader: true}, [][]any{{int64(1), "a"}},
+			"1,a\n"},
+		{"quote all", Options{Format: CSV, Quoting: QuoteAll}, [][]any{{int64(1), nil}},
+			"\"id\",\"note\"\n\"1\",\n"},
+		{"quote non-numeric", Options{Format: CSV, Quoting: QuoteNonNumeric}, [][]any{{int64(1), "a"}, {1.5, nil}},
+			"\"id\",\"note\"\n1,\"a\"\n1.5,\n"},
+		{"tsv", Options{Format: TSV}, [][]any{{int64(1), "a,b"}, {int64(2), "a\tb"}},
+			"id\tnote\n1\ta,b\n2\t\"a\tb\"\n"},
+	}
+	for _, test := range tests {
+		if got := write(t, test.opts, columns, test.rows...); got != test.want {
+			t.Errorf("%s: wrote %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	columns := []string{"id", "note", "ratio"}
+	rows := [][]any{
+		{int64(1), "line\n\"two\"", 0.5},
+		{int64(2), nil, math.Inf(1)},
+	}
+
+	got := write(t, Options{Format: JSON}, columns, rows...)
+	want := "[\n" +
+		`  {"id":1,"note":"line\n\"two\"","ratio":0.5},` + "\n" +
+		`  {"id":2,"note":null,"ratio":"+Inf"}` + "\n" +
+		"]\n"
+	if got != want {
+		t.Errorf("JSON wrote %q, want %q", got, want)
+	}
+
+	got = write(t, Options{Format: NDJSON}, columns, rows...)
+	want = `{"id":1,"note":"line\n\"two\"","ratio":0.5}` + "\n" +
+		`{"id":2,"note":null,"ratio":"+Inf"}` + "\n"
+	if got != want {
+		t.Errorf("NDJSON wrote %q, want %q", got, want)
+	}
+
+	if got := write(t, Options{Format: JSON}, columns); got != "[]\n" {
+		t.Errorf("JSON with no rows wrote %q, want %q", got, "[]\n")
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	got := write(t, Options{Format: Markdown}, []string{"a|b", "note"},
+		[]any{nil, "x|y"},
+		[]any{int64(1), "one\r\ntwo\nthree"},
+		[]any{int64(2), `back\slash`},
+	)
+	want := "| a\\|b | note |\n" +
+		"| --- | --- |\n" +
+		"| *NULL* | x\\|y |\n" +
+		"| 1 | one<br>two<br>three |\n" +
+		"| 2 | back\\\\slash |\n"
+	if got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	tests := []struct {
+		dialect types.ConnectionType
+		table   string
+		want    string
+	}{
+		{types.PostgreSQL, `"orders"`, `INSERT INTO "orders" ("id", "user", "it""s") VALUES (1, NULL, 'O''Brien');` + "\n"},
+		{types.MySQL, "`orders`", "INSERT INTO `orders` (`id`, `user`, `it\"s`) VALUES (1, NULL, 'O''Brien');\n"},
+	}
+	for _, test := range tests {
+		opts := Options{Format: Insert, Table: test.table, Dialect: test.dialect}
+		got := write(t, opts, []string{"id", "user", `it"s`}, []any{int64(1), nil, "O'Brien"})
+		if got != test.want {
+			t.Errorf("%s: wrote %q, want %q", test.dialect, got, test.want)
+		}
+	}
+
+	w := NewWriter(&bytes.Buffer{}, Options{Format: Insert, Dialect: types.PostgreSQL})
+	if err := w.Columns([]string{"id"}); err == nil {
+		t.Error("INSERT statements were written without a table name")
+	}
+}
+
+func TestNoColumns(t *testing.T) {
+	for _, format := range Formats {
+		w := NewWriter(&bytes.Buffer{}, Options{Format: format, Table: "t"})
+		if err := w.Columns(nil); !errors.Is(err, errNoColumns) {
+			t.Errorf("%s: Columns(nil) returned %v, want errNoColumns", format, err)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		want Format
+	}{
+		{"csv", CSV},
+		{"CSV", CSV},
+		{".tsv", TSV},
+		{"json", JSON},
+		{"ndjson", NDJSON},
+		{"markdown", Markdown},
+		{"md", Markdown},
+		{".MD", Markdown},
+		{"sql", Insert},
+		{"insert", Insert},
+		{"SQL INSERT", Insert},
+	}
+	for _, test := range tests {
+		got, err := ParseFormat(test.name)
+		if err != nil || got != test.want {
+			t.Errorf("ParseFormat(%q) = %v, %v, want %v", test.name, got, err, test.want)
+		}
+	}
+
+	for _, name := range []string{"", "xlsx", "unknown"} {
+		if _, err := ParseFormat(name); err == nil {
+			t.Errorf("ParseFormat(%q) succeeded", name)
+		}
+	}
+}
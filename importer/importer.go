@@ -0,0 +1,134 @@
+// Package importer finds connections already described by the files other
+// database clients read, such as ~/.pgpass, pg_service.conf and ~/.my.cnf,
+// so they can be saved without typing them in again.
+package importer
+
+import (
+	"nectar/types"
+	"nectar/utils"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Source is the kind of file a connection was found in
+type Source int
+
+const (
+	PgPass Source = iota
+	PgService
+	MyCnf
+)
+
+func (s Source) String() string {
+	switch s {
+	case PgPass:
+		return "pgpass"
+	case PgService:
+		return "pg_service"
+	case MyCnf:
+		return "my.cnf"
+	default:
+		return "unknown"
+	}
+}
+
+// Candidate is a connection that could be imported
+type Candidate struct {
+	Connection types.Connection
+	Source     Source
+	// Path is the file it was found in
+	Path string
+}
+
+// Discover reads every file it knows of from its usual place and returns the
+// connections they describe. Files that are missing or can't be read are
+// skipped, as the clients that own them would.
+func Discover() []Candidate {
+	var candidates []Candidate
+
+	passwords, path := readPgPass()
+	candidates = append(candidates, passwords.candidates(path)...)
+	for _, path := range serviceFiles() {
+		candidates = append(candidates, readServices(path, passwords)...)
+	}
+	candidates = append(candidates, readMyCnf(myCnfFiles(), loginFile())...)
+	return candidates
+}
+
+// Pending leaves out the candidates that are saved already, meaning a saved
+// connection goes to the same place as the same user, and those another
+// file described first. A candidate whose name is taken by another
+// connection is renamed after its source.
+func Pending(candidates []Candidate, saved []types.Connection) []Candidate {
+	names := map[string]bool{}
+	for _, conn := range saved {
+		names[conn.Name] = true
+	}
+
+	taken := slices.Clone(saved)
+	var pending []Candidate
+	for _, candidate := range candidates {
+		if sameTarget(candidate.Connection, taken) {
+			continue
+		}
+		if names[candidate.Connection.Name] {
+			candidate.Connection.Name += " (" + candidate.Source.String() + ")"
+		}
+		if names[candidate.Connection.Name] {
+			continue
+		}
+		names[candidate.Connection.Name] = true
+		taken = append(taken, candidate.Connection)
+		pending = append(pending, candidate)
+	}
+	return pending
+}
+
+func sameTarget(conn types.Connection, connections []types.Connection) bool {
+	for _, s := range connections {
+		if s.Type == conn.Type && strings.EqualFold(s.Host, conn.Host) && s.Port == conn.Port &&
+			s.User == conn.User && s.Database == conn.Database {
+			return true
+		}
+	}
+	return false
+}
+
+// withDefaults fills in what a connection leaves out the way its client
+// would: the local host, and the default port of its type
+func withDefaults(conn types.Connection) types.Connection {
+	if conn.Host == "" {
+		conn.Host = "localhost"
+	}
+	if conn.Port == "" {
+		conn.Port = utils.DefaultPorts[conn.Type]
+	}
+	return conn
+}
+
+// name makes up a name for a connection the file doesn't name, such as
+// app@db.example.com/shop
+func name(conn types.Connection) string {
+	text := conn.Host
+	if conn.Port != "" && conn.Port != utils.DefaultPorts[conn.Type] {
+		text += ":" + conn.Port
+	}
+	if conn.User != "" {
+		text = conn.User + "@" + text
+	}
+	if conn.Database != "" {
+		text += "/" + conn.Database
+	}
+	return text
+}
+
+// home joins the path onto the home directory, or returns "" without one
+func home(path ...string) string {
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(append([]string{dir}, path...)...)
+}
@@ -0,0 +1,48 @@
+package importer
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// section is a [group] of an INI-style file, its keys in lower case
+type section struct {
+	name   string
+	values map[string]string
+}
+
+// parseINI reads the sections of the files pg_service.conf and my.cnf are
+// written in: [name] headers, key=value lines and # or ; comments. Keys
+// given without a value are kept with an empty one; directives such as
+// !include and lines before the first header are skipped.
+func parseINI(r io.Reader) ([]section, error) {
+	var sections []section
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", line[0] == '#', line[0] == ';', line[0] == '!':
+			continue
+		case line[0] == '[' && strings.HasSuffix(line, "]"):
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			sections = append(sections, section{name: name, values: map[string]string{}})
+			continue
+		case len(sections) == 0:
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		sections[len(sections)-1].values[key] = unquote(strings.TrimSpace(value))
+	}
+	return sections, scanner.Err()
+}
+
+// unquote strips the quotes around a value, if it has any
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
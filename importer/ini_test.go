@@ -0,0 +1,39 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseINI(t *testing.T) {
+	const file = `ignored=before any header
+# comment
+; another
+[ first ]
+Host = db.example.com
+password = "quoted value"
+single='quoted'
+skip-ssl
+!include /etc/other.cnf
+
+[second]
+empty=
+`
+	sections, err := parseINI(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []section{
+		{name: "first", values: map[string]string{
+			"host":     "db.example.com",
+			"password": "quoted value",
+			"single":   "quoted",
+			"skip-ssl": "",
+		}},
+		{name: "second", values: map[string]string{"empty": ""}},
+	}
+	if !reflect.DeepEqual(sections, want) {
+		t.Fatalf("parseINI =\n%+v\nwant\n%+v", sections, want)
+	}
+}
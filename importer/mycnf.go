@@ -0,0 +1,291 @@
+package importer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"nectar/types"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// baseGroups are read by every client. mysql picks out a login path by
+// reading the group of that name, or client<suffix> and mysql<suffix> for
+// --defaults-group-suffix, on top of them.
+var baseGroups = map[string]bool{
+	"client":         true,
+	"mysql":          true,
+	"client-server":  true,
+	"client-mariadb": true,
+}
+
+// Groups that configure the server or other tools rather than a login,
+// by name and by how their names start
+var (
+	toolGroups = map[string]bool{
+		"server": true, "embedded": true, "galera": true, "mariadb": true, "sst": true,
+	}
+	toolPrefixes = []string{
+		"mysqld", "mariadbd", "mariadb-", "mysqldump", "mysqladmin", "mysqlimport",
+		"mysqlshow", "mysqlcheck", "mysqlslap", "mysqlbinlog", "mysql_upgrade",
+		"mysqlhotcopy", "mysqltest",
+	}
+)
+
+// group is a [group] of an option file, with the file it was read from
+type group struct {
+	section
+	path string
+	// login is set for groups of the login path file, all of which are
+	// login paths
+	login bool
+}
+
+// parseMyCnf turns the groups of the MySQL option files, in the order they
+// were read, into candidates: one for the client groups themselves, if they
+// name somewhere to connect, and one for each login path, which inherits
+// what they set. Login paths are the groups of the login path file and the
+// client<suffix> and mysql<suffix> groups of the others; any other group
+// belongs to something else. As with mysql, a later file overrides an
+// earlier one and a group given in several files is read as one.
+func parseMyCnf(groups []group) []Candidate {
+	base := map[string]string{}
+	basePath := ""
+	var paths []group
+	index := map[string]int{}
+	for _, g := range groups {
+		switch name := strings.ToLower(g.name); {
+		case baseGroups[name]:
+			for key, value := range g.values {
+				base[optionKey(key)] = value
+			}
+			// The connection is credited to the file saying where it goes
+			if g.values["host"] != "" || g.values["user"] != "" {
+				basePath = g.path
+			}
+		case !g.login && (isTool(name) || !isSuffixed(name)):
+		default:
+			i, seen := index[name]
+			if !seen {
+				index[name] = len(paths)
+				paths = append(paths, group{section: section{name: g.name, values: map[string]string{}}})
+				i = len(paths) - 1
+			}
+			for key, value := range g.values {
+				paths[i].values[key] = value
+			}
+			paths[i].path = g.path
+		}
+	}
+
+	var candidates []Candidate
+	if (base["host"] != "" || base["user"] != "") && !socketOnly(base) {
+		conn := myCnfConnection(base)
+		conn.Name = name(conn)
+		candidates = append(candidates, Candidate{Connection: conn, Source: MyCnf, Path: basePath})
+	}
+	for _, path := range paths {
+		options := map[string]string{}
+		for key, value := range base {
+			options[key] = value
+		}
+		for key, value := range path.values {
+			options[optionKey(key)] = value
+		}
+		if socketOnly(options) {
+			continue
+		}
+		conn := myCnfConnection(options)
+		conn.Name = loginPath(path.name)
+		candidates = append(candidates, Candidate{Connection: conn, Source: MyCnf, Path: path.path})
+	}
+	return candidates
+}
+
+func myCnfConnection(options map[string]string) types.Connection {
+	conn := types.Connection{
+		Type:     types.MySQL,
+		Host:     options["host"],
+		Port:     options["port"],
+		User:     options["user"],
+		Password: options["password"],
+		Database: options["database"],
+		TLS: types.TLSConfig{
+			Mode:     sslMode(options),
+			CAFile:   options["ssl-ca"],
+			CertFile: options["ssl-cert"],
+			KeyFile:  options["ssl-key"],
+		},
+	}
+	if conn.User == "" {
+		conn.User = currentUser()
+	}
+	return withDefaults(conn)
+}
+
+// sslMode maps ssl-mode, or the older ssl and ssl-verify-server-cert
+// switches, onto a TLS mode. MySQL prefers TLS unless told otherwise.
+func sslMode(options map[string]string) types.TLSMode {
+	switch strings.ToUpper(options["ssl-mode"]) {
+	case "DISABLED":
+		return types.TLSDisable
+	case "REQUIRED":
+		return types.TLSRequire
+	case "VERIFY_CA":
+		return types.TLSVerifyCA
+	case "VERIFY_IDENTITY":
+		return types.TLSVerifyFull
+	}
+	if value, ok := options["ssl-verify-server-cert"]; ok && value != "0" && value != "false" {
+		return types.TLSVerifyFull
+	}
+	if value, ok := options["ssl"]; ok && (value == "0" || value == "false") {
+		return types.TLSDisable
+	}
+	return types.TLSPrefer
+}
+
+// optionKey spells an option the one way: MySQL takes - and _ alike
+func optionKey(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// socketOnly reports whether options connect through a Unix socket, which
+// nectar can't, rather than to a host
+func socketOnly(options map[string]string) bool {
+	return options["socket"] != "" && options["host"] == ""
+}
+
+// isSuffixed reports whether group is client or mysql with a suffix, as
+// --defaults-group-suffix and --login-path read
+func isSuffixed(group string) bool {
+	for _, prefix := range []string{"client", "mysql"} {
+		if rest, ok := strings.CutPrefix(group, prefix); ok && rest != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func isTool(group string) bool {
+	if toolGroups[group] {
+		return true
+	}
+	for _, prefix := range toolPrefixes {
+		if strings.HasPrefix(group, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// loginPath names a connection after its group, without the client or
+// mysql a suffixed group starts with
+func loginPath(group string) string {
+	for _, prefix := range []string{"client", "mysql"} {
+		if rest, ok := strings.CutPrefix(group, prefix); ok && rest != "" {
+			if trimmed := strings.TrimLeft(rest, "-_"); trimmed != "" {
+				return trimmed
+			}
+		}
+	}
+	return group
+}
+
+// myCnfFiles are the user's option files in the order mysql reads them:
+// my.cnf in $MYSQL_HOME, then ~/.my.cnf. The system-wide files configure the
+// server rather than the user's logins and are left out.
+func myCnfFiles() []string {
+	var files []string
+	if dir := os.Getenv("MYSQL_HOME"); dir != "" {
+		files = append(files, filepath.Join(dir, "my.cnf"))
+	}
+	if path := home(".my.cnf"); path != "" {
+		files = append(files, path)
+	}
+	return files
+}
+
+// loginFile is where mysql_config_editor keeps login paths:
+// $MYSQL_TEST_LOGIN_FILE, or else ~/.mylogin.cnf
+// (%APPDATA%\MySQL\.mylogin.cnf on Windows)
+func loginFile() string {
+	if path := os.Getenv("MYSQL_TEST_LOGIN_FILE"); path != "" {
+		return path
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "MySQL", ".mylogin.cnf")
+	}
+	return home(".mylogin.cnf")
+}
+
+// readMyCnf reads the connections of the option files and, after them, the
+// login path file, skipping any that can't be read
+func readMyCnf(paths []string, login string) []Candidate {
+	var groups []group
+	read := func(path string, data []byte, isLogin bool) {
+		sections, err := parseINI(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for _, s := range sections {
+			groups = append(groups, group{section: s, path: path, login: isLogin})
+		}
+	}
+	for _, path := range paths {
+		if data, err := os.ReadFile(path); err == nil {
+			read(path, data, false)
+		}
+	}
+	if data, err := os.ReadFile(login); err == nil {
+		if data, err = decryptLoginFile(data); err == nil {
+			read(login, data, true)
+		}
+	}
+	return parseMyCnf(groups)
+}
+
+// decryptLoginFile reads a file written by mysql_config_editor: 4 unused
+// bytes and a 20 byte key, then chunks of AES-128-ECB ciphertext each led
+// by its length. The AES key is the 20 byte one folded onto 16 by XOR.
+func decryptLoginFile(data []byte) ([]byte, error) {
+	const header = 4 + 20
+	if len(data) < header {
+		return nil, errors.New("login path file is too short")
+	}
+	var key [aes.BlockSize]byte
+	for i, b := range data[4:header] {
+		key[i%aes.BlockSize] ^= b
+	}
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var plain []byte
+	for rest := data[header:]; len(rest) > 0; {
+		if len(rest) < 4 {
+			return nil, errors.New("login path file is truncated")
+		}
+		length := int(binary.LittleEndian.Uint32(rest))
+		rest = rest[4:]
+		if length == 0 || length%aes.BlockSize != 0 || length > len(rest) {
+			return nil, errors.New("login path file is malformed")
+		}
+		chunk := make([]byte, length)
+		for i := 0; i < length; i += aes.BlockSize {
+			block.Decrypt(chunk[i:], rest[i:i+aes.BlockSize])
+		}
+		// Each chunk is padded as PKCS #7
+		padding := int(chunk[length-1])
+		if padding == 0 || padding > aes.BlockSize {
+			return nil, errors.New("login path file is malformed")
+		}
+		plain = append(plain, chunk[:length-padding]...)
+		rest = rest[length:]
+	}
+	return plain, nil
+}
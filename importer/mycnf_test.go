@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"nectar/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// encryptLoginFile writes plain the way mysql_config_editor does, one
+// chunk per line
+func encryptLoginFile(t *testing.T, plain string) []byte {
+	t.Helper()
+	key := []byte("0123456789abcdefghij")
+	var folded [aes.BlockSize]byte
+	for i, b := range key {
+		folded[i%aes.BlockSize] ^= b
+	}
+	block, err := aes.NewCipher(folded[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := append([]byte{0, 0, 0, 0}, key...)
+	for _, line := range bytes.SplitAfter([]byte(plain), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		padding := aes.BlockSize - len(line)%aes.BlockSize
+		chunk := append(bytes.Clone(line), bytes.Repeat([]byte{byte(padding)}, padding)...)
+		for i := 0; i < len(chunk); i += aes.BlockSize {
+			block.Encrypt(chunk[i:], chunk[i:i+aes.BlockSize])
+		}
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(chunk)))
+		data = append(data, chunk...)
+	}
+	return data
+}
+
+func TestDecryptLoginFile(t *testing.T) {
+	const plain = "[remote]\nhost = db.example.com\nuser = app\npassword = s3cret\n"
+	got, err := decryptLoginFile(encryptLoginFile(t, plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != plain {
+		t.Fatalf("decryptLoginFile = %q, want %q", got, plain)
+	}
+
+	encrypted := encryptLoginFile(t, plain)
+	for name, data := range map[string][]byte{
+		"too short":  encrypted[:10],
+		"truncated":  encrypted[:len(encrypted)-3],
+		"bad length": append(bytes.Clone(encrypted[:24]), 5, 0, 0, 0, 1, 2, 3, 4, 5),
+	} {
+		if _, err := decryptLoginFile(data); err == nil {
+			t.Errorf("%s: decryptLoginFile succeeded", name)
+		}
+	}
+}
+
+func TestParseMyCnf(t *testing.T) {
+	values := func(pairs ...string) map[string]string {
+		m := map[string]string{}
+		for i := 0; i < len(pairs); i += 2 {
+			m[pairs[i]] = pairs[i+1]
+		}
+		return m
+	}
+	groups := []group{
+		{section: section{name: "client", values: values("user", "me", "password", "base")}, path: "/etc/my.cnf"},
+		{section: section{name: "mysqld", values: values("port", "3307")}, path: "/etc/my.cnf"},
+		{section: section{name: "myapp", values: values("host", "app.example.com")}, path: "/home/me/.my.cnf"},
+		{section: section{name: "client-prod", values: values("host", "prod.example.com", "ssl_mode", "VERIFY_IDENTITY")}, path: "/home/me/.my.cnf"},
+		{section: section{name: "mysql_staging", values: values("host", "staging.example.com", "database", "shop")}, path: "/home/me/.my.cnf"},
+		{section: section{name: "mysqldump", values: values("host", "dump.example.com")}, path: "/home/me/.my.cnf"},
+		{section: section{name: "client-local", values: values("socket", "/tmp/mysql.sock")}, path: "/home/me/.my.cnf"},
+		{section: section{name: "remote", values: values("host", "remote.example.com", "password", "login")}, path: "/home/me/.mylogin.cnf", login: true},
+		{section: section{name: "client-prod", values: values("port", "3310")}, path: "/home/me/.mylogin.cnf", login: true},
+	}
+	candidates := parseMyCnf(groups)
+
+	type want struct {
+		name, host, port, user, password, database, path string
+		tls                                              types.TLSMode
+	}
+	wants := []want{
+		{"me@localhost", "localhost", "3306", "me", "base", "", "/etc/my.cnf", types.TLSPrefer},
+		{"prod", "prod.example.com", "3310", "me", "base", "", "/home/me/.mylogin.cnf", types.TLSVerifyFull},
+		{"staging", "staging.example.com", "3306", "me", "base", "shop", "/home/me/.my.cnf", types.TLSPrefer},
+		{"remote", "remote.example.com", "3306", "me", "login", "", "/home/me/.mylogin.cnf", types.TLSPrefer},
+	}
+	if len(candidates) != len(wants) {
+		t.Fatalf("got %d candidates, want %d", len(candidates), len(wants))
+	}
+	for i, w := range wants {
+		conn := candidates[i].Connection
+		got := want{conn.Name, conn.Host, conn.Port, conn.User, conn.Password, conn.Database, candidates[i].Path, conn.TLS.Mode}
+		if got != w {
+			t.Errorf("candidate %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestReadMyCnf(t *testing.T) {
+	dir := t.TempDir()
+	cnf := filepath.Join(dir, ".my.cnf")
+	login := filepath.Join(dir, ".mylogin.cnf")
+	if err := os.WriteFile(cnf, []byte("[client]\nuser=me\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(login, encryptLoginFile(t, "[backup]\nhost=backup.example.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	candidates := readMyCnf([]string{filepath.Join(dir, "missing.cnf"), cnf}, login)
+	if len(candidates) != 2 {
+		t.Fatalf("got %d candidates, want the client group and the login path", len(candidates))
+	}
+	if conn := candidates[1].Connection; conn.Name != "backup" || conn.Host != "backup.example.com" || conn.User != "me" {
+		t.Errorf("login path candidate = %+v", conn)
+	}
+}
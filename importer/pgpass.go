@@ -0,0 +1,163 @@
+package importer
+
+import (
+	"bufio"
+	"io"
+	"nectar/types"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// wildcard matches any value in a field of a password file line
+const wildcard = "*"
+
+// passEntry is a line of a PostgreSQL password file:
+// hostname:port:database:username:password. Any of the first four fields
+// may be * to match anything.
+type passEntry struct {
+	Host     string
+	Port     string
+	Database string
+	User     string
+	Password string
+}
+
+// passFile is a parsed password file. As with libpq, the first line that
+// matches a connection gives its password.
+type passFile []passEntry
+
+// parsePgPass reads a password file. Comment lines and lines with too few
+// fields are skipped; \: and \\ stand for a colon and a backslash.
+func parsePgPass(r io.Reader) (passFile, error) {
+	var entries passFile
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := splitPassLine(line)
+		if len(fields) < 5 {
+			continue
+		}
+		entries = append(entries, passEntry{
+			Host:     fields[0],
+			Port:     fields[1],
+			Database: fields[2],
+			User:     fields[3],
+			Password: fields[4],
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// splitPassLine splits a line at the colons that aren't escaped, leaving
+// the rest of the line to the last field so passwords may hold colons
+func splitPassLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ':' && len(fields) < 4:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+	return append(fields, field.String())
+}
+
+// password returns the password of the first line that matches a
+// connection, and whether there was one. localhost also matches
+// connections through a Unix socket, which have no host or a directory.
+func (p passFile) password(host, port, database, user string) (string, bool) {
+	for _, entry := range p {
+		hostMatches := matches(entry.Host, host) ||
+			entry.Host == "localhost" && (host == "" || strings.HasPrefix(host, "/"))
+		if hostMatches && matches(entry.Port, port) && matches(entry.Database, database) && matches(entry.User, user) {
+			return entry.Password, true
+		}
+	}
+	return "", false
+}
+
+func matches(pattern, value string) bool {
+	return pattern == wildcard || pattern == value
+}
+
+// candidates turns the lines naming a host into connections. Lines that
+// apply to any host only supply passwords. A wildcard port stands for the
+// default one, a wildcard database for none in particular and a wildcard
+// user for the current one.
+func (p passFile) candidates(path string) []Candidate {
+	var candidates []Candidate
+	for _, entry := range p {
+		if entry.Host == wildcard || entry.Host == "" {
+			continue
+		}
+		// libpq prefers TLS when nothing says otherwise
+		conn := types.Connection{Type: types.PostgreSQL, Host: entry.Host, TLS: types.TLSConfig{Mode: types.TLSPrefer}}
+		if entry.Port != wildcard {
+			conn.Port = entry.Port
+		}
+		if entry.Database != wildcard {
+			conn.Database = entry.Database
+		}
+		conn.User = entry.User
+		if conn.User == wildcard {
+			conn.User = currentUser()
+		}
+		conn = withDefaults(conn)
+		// An earlier line may match this one's connection too, and libpq
+		// would use its password
+		conn.Password, _ = p.password(conn.Host, conn.Port, conn.Database, conn.User)
+		conn.Name = name(conn)
+		candidates = append(candidates, Candidate{Connection: conn, Source: PgPass, Path: path})
+	}
+	return candidates
+}
+
+// readPgPass reads the password file libpq would: $PGPASSFILE, or else
+// ~/.pgpass (%APPDATA%\postgresql\pgpass.conf on Windows)
+func readPgPass() (passFile, string) {
+	path := os.Getenv("PGPASSFILE")
+	if path == "" && runtime.GOOS == "windows" {
+		path = filepath.Join(os.Getenv("APPDATA"), "postgresql", "pgpass.conf")
+	} else if path == "" {
+		path = home(".pgpass")
+	}
+	if path == "" {
+		return nil, ""
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, ""
+	}
+	defer f.Close()
+	entries, err := parsePgPass(f)
+	if err != nil {
+		return nil, ""
+	}
+	return entries, path
+}
+
+// currentUser is who libpq connects as when no user is given
+func currentUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	// Windows reports DOMAIN\user
+	return u.Username[strings.LastIndex(u.Username, `\`)+1:]
+}
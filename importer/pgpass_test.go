@@ -0,0 +1,87 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePgPass(t *testing.T) {
+	const file = `# comment
+db.example.com:5432:shop:app:s3cret
+*:*:*:admin:any\:host
+weird\:host:6543:d\\b:u:pass:with:colons
+too:few:fields
+
+localhost:*:*:me:local
+`
+	entries, err := parsePgPass(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := passFile{
+		{Host: "db.example.com", Port: "5432", Database: "shop", User: "app", Password: "s3cret"},
+		{Host: "*", Port: "*", Database: "*", User: "admin", Password: "any:host"},
+		{Host: "weird:host", Port: "6543", Database: `d\b`, User: "u", Password: "pass:with:colons"},
+		{Host: "localhost", Port: "*", Database: "*", User: "me", Password: "local"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("parsePgPass =\n%+v\nwant\n%+v", entries, want)
+	}
+}
+
+func TestPgPassPassword(t *testing.T) {
+	passwords := passFile{
+		{Host: "db.example.com", Port: "5432", Database: "shop", User: "app", Password: "exact"},
+		{Host: "*", Port: "*", Database: "*", User: "app", Password: "any host"},
+		{Host: "localhost", Port: "*", Database: "*", User: "me", Password: "socket"},
+	}
+	tests := []struct {
+		name                       string
+		host, port, database, user string
+		want                       string
+		found                      bool
+	}{
+		{"exact line", "db.example.com", "5432", "shop", "app", "exact", true},
+		{"wildcard line", "other.example.com", "5433", "crm", "app", "any host", true},
+		{"localhost matches no host", "", "5432", "postgres", "me", "socket", true},
+		{"localhost matches a socket directory", "/var/run/postgresql", "5432", "postgres", "me", "socket", true},
+		{"no match", "db.example.com", "5432", "shop", "nobody", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, found := passwords.password(test.host, test.port, test.database, test.user)
+			if got != test.want || found != test.found {
+				t.Fatalf("password = %q, %v, want %q, %v", got, found, test.want, test.found)
+			}
+		})
+	}
+}
+
+func TestPgPassCandidates(t *testing.T) {
+	passwords := passFile{
+		{Host: "*", Port: "*", Database: "*", User: "app", Password: "fallback"},
+		{Host: "db.example.com", Port: "*", Database: "*", User: "app", Password: "own"},
+		{Host: "db.example.com", Port: "6432", Database: "shop", User: "app", Password: "pooled"},
+	}
+	candidates := passwords.candidates("/home/me/.pgpass")
+	if len(candidates) != 2 {
+		t.Fatalf("got %d candidates, want the 2 lines naming a host", len(candidates))
+	}
+
+	// The wildcard line comes first, so libpq would use its password
+	first := candidates[0].Connection
+	if first.Host != "db.example.com" || first.Port != "5432" || first.Database != "" || first.Password != "fallback" {
+		t.Errorf("first candidate = %+v", first)
+	}
+	if first.Name != "app@db.example.com" {
+		t.Errorf("first candidate is named %q", first.Name)
+	}
+	second := candidates[1].Connection
+	if second.Port != "6432" || second.Database != "shop" || second.Name != "app@db.example.com:6432/shop" {
+		t.Errorf("second candidate = %+v", second)
+	}
+	if candidates[1].Source != PgPass || candidates[1].Path != "/home/me/.pgpass" {
+		t.Errorf("second candidate came from %v %s", candidates[1].Source, candidates[1].Path)
+	}
+}
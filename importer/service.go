@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"nectar/types"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// parseServices reads a PostgreSQL connection service file, each of whose
+// [sections] names a service, into connections. Passwords the file leaves
+// out are looked up in the password file, as libpq would.
+func parseServices(sections []section, passwords passFile) []types.Connection {
+	var connections []types.Connection
+	for _, s := range sections {
+		if s.name == "" {
+			continue
+		}
+		conn := types.Connection{Type: types.PostgreSQL, Name: s.name}
+		// Of several hosts, the first is the one tried first
+		conn.Host, _, _ = strings.Cut(s.values["host"], ",")
+		if conn.Host == "" {
+			conn.Host, _, _ = strings.Cut(s.values["hostaddr"], ",")
+		}
+		conn.Port, _, _ = strings.Cut(s.values["port"], ",")
+		conn.User = s.values["user"]
+		conn.Database = s.values["dbname"]
+		conn.Password = s.values["password"]
+		conn.TLS = types.TLSConfig{
+			Mode:     tlsMode(s.values["sslmode"]),
+			CAFile:   s.values["sslrootcert"],
+			CertFile: s.values["sslcert"],
+			KeyFile:  s.values["sslkey"],
+		}
+
+		if conn.User == "" {
+			conn.User = currentUser()
+		}
+		conn = withDefaults(conn)
+		if conn.Password == "" {
+			// libpq looks the database up under the user's name when none
+			// is given
+			database := conn.Database
+			if database == "" {
+				database = conn.User
+			}
+			conn.Password, _ = passwords.password(conn.Host, conn.Port, database, conn.User)
+		}
+		connections = append(connections, conn)
+	}
+	return connections
+}
+
+// tlsMode maps an sslmode onto the modes nectar has. libpq prefers TLS when
+// none is given, and allow is closest to prefer.
+func tlsMode(sslmode string) types.TLSMode {
+	switch sslmode {
+	case "", "allow":
+		return types.TLSPrefer
+	}
+	mode, err := types.ParseTLSMode(sslmode)
+	if err != nil {
+		return types.TLSPrefer
+	}
+	return mode
+}
+
+// serviceFiles lists the service files libpq reads, the user's first so
+// that its services win: $PGSERVICEFILE or ~/.pg_service.conf, then
+// pg_service.conf in $PGSYSCONFDIR or where distributions put it
+func serviceFiles() []string {
+	var files []string
+	if path := os.Getenv("PGSERVICEFILE"); path != "" {
+		files = append(files, path)
+	} else if path := home(".pg_service.conf"); path != "" {
+		files = append(files, path)
+	}
+	if dir := os.Getenv("PGSYSCONFDIR"); dir != "" {
+		files = append(files, filepath.Join(dir, "pg_service.conf"))
+	} else {
+		files = append(files, "/etc/postgresql-common/pg_service.conf", "/etc/pg_service.conf")
+	}
+	return files
+}
+
+// readServices reads the services of one service file, skipping it when it
+// can't be read
+func readServices(path string, passwords passFile) []Candidate {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	sections, err := parseINI(f)
+	if err != nil {
+		return nil
+	}
+
+	var candidates []Candidate
+	for _, conn := range parseServices(sections, passwords) {
+		candidates = append(candidates, Candidate{Connection: conn, Source: PgService, Path: path})
+	}
+	return candidates
+}
@@ -0,0 +1,81 @@
+package importer
+
+import (
+	"nectar/types"
+	"strings"
+	"testing"
+)
+
+func TestParseServices(t *testing.T) {
+	const file = `[shop]
+host=db1.example.com,db2.example.com
+port=6432,5432
+user=app
+dbname=shop
+sslmode=verify-full
+sslrootcert=/etc/ssl/ca.pem
+
+[reports]
+hostaddr=10.0.0.5
+user=reporter
+password=inline
+sslmode=allow
+
+[local]
+user=me
+`
+	sections, err := parseINI(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwords := passFile{
+		{Host: "db1.example.com", Port: "6432", Database: "shop", User: "app", Password: "from pgpass"},
+		{Host: "*", Port: "*", Database: "*", User: "reporter", Password: "not used"},
+		// With no dbname, libpq looks up the database named after the user
+		{Host: "localhost", Port: "5432", Database: "me", User: "me", Password: "mine"},
+	}
+	connections := parseServices(sections, passwords)
+
+	want := []types.Connection{
+		{
+			Type: types.PostgreSQL, Name: "shop", Host: "db1.example.com", Port: "6432",
+			User: "app", Database: "shop", Password: "from pgpass",
+			TLS: types.TLSConfig{Mode: types.TLSVerifyFull, CAFile: "/etc/ssl/ca.pem"},
+		},
+		{
+			Type: types.PostgreSQL, Name: "reports", Host: "10.0.0.5", Port: "5432",
+			User: "reporter", Password: "inline",
+			TLS: types.TLSConfig{Mode: types.TLSPrefer},
+		},
+		{
+			Type: types.PostgreSQL, Name: "local", Host: "localhost", Port: "5432",
+			User: "me", Password: "mine",
+			TLS: types.TLSConfig{Mode: types.TLSPrefer},
+		},
+	}
+	if len(connections) != len(want) {
+		t.Fatalf("got %d services, want %d", len(connections), len(want))
+	}
+	for i := range want {
+		if connections[i] != want[i] {
+			t.Errorf("service %d =\n%+v\nwant\n%+v", i, connections[i], want[i])
+		}
+	}
+}
+
+func TestTLSMode(t *testing.T) {
+	tests := map[string]types.TLSMode{
+		"":            types.TLSPrefer,
+		"disable":     types.TLSDisable,
+		"allow":       types.TLSPrefer,
+		"require":     types.TLSRequire,
+		"verify-ca":   types.TLSVerifyCA,
+		"verify-full": types.TLSVerifyFull,
+		"bogus":       types.TLSPrefer,
+	}
+	for sslmode, want := range tests {
+		if got := tlsMode(sslmode); got != want {
+			t.Errorf("tlsMode(%q) = %v, want %v", sslmode, got, want)
+		}
+	}
+}
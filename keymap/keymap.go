@@ -0,0 +1,229 @@
+// Package keymap names the keys nectar answers to. Each part of a screen
+// has a map of its own; screens give the focused part the first claim on a
+// key and fall back to the global bindings only for keys it leaves alone.
+// The status bar is written from the same bindings, so the hints shown are
+// always the keys that work.
+package keymap
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// GlobalKeys apply on every screen, once the focused part has passed on a key
+type GlobalKeys struct {
+	Quit      key.Binding
+	ForceQuit key.Binding
+	New       key.Binding
+	NextPane  key.Binding
+	Back      key.Binding
+	Sidebar   key.Binding
+	Resize    key.Binding
+}
+
+var Global = GlobalKeys{
+	Quit:      key.NewBinding(key.WithKeys("q"), key.WithHelp("q", "quit")),
+	ForceQuit: key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("^c", "quit")),
+	New:       key.NewBinding(key.WithKeys("ctrl+n"), key.WithHelp("^n", "new")),
+	NextPane:  key.NewBinding(key.WithKeys("tab"), key.WithHelp("↹", "next pane")),
+	Back:      key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+	Sidebar:   key.NewBinding(key.WithKeys("ctrl+b"), key.WithHelp("^b", "sidebar")),
+	Resize:    key.NewBinding(key.WithKeys("<", ">"), key.WithHelp("</>", "resize sidebar")),
+}
+
+// SidebarKeys drive the list of saved connections
+type SidebarKeys struct {
+	Up      key.Binding
+	Down    key.Binding
+	Connect key.Binding
+	Edit    key.Binding
+	Delete  key.Binding
+	Browse  key.Binding
+	Import  key.Binding
+}
+
+var Sidebar = SidebarKeys{
+	Up:      key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
+	Down:    key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "down")),
+	Connect: key.NewBinding(key.WithKeys("enter"), key.WithHelp("↵", "connect")),
+	Edit:    key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "edit")),
+	Delete:  key.NewBinding(key.WithKeys("ctrl+d"), key.WithHelp("^d", "delete")),
+	Browse:  key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "schema/connections")),
+	Import:  key.NewBinding(key.WithKeys("enter", "i"), key.WithHelp("↵", "import")),
+}
+
+// TreeKeys drive the schema tree of the open session
+type TreeKeys struct {
+	Up       key.Binding
+	Down     key.Binding
+	Top      key.Binding
+	Bottom   key.Binding
+	Expand   key.Binding
+	Collapse key.Binding
+	Filter   key.Binding
+	Reload   key.Binding
+	Open     key.Binding
+	DDL      key.Binding
+	Copy     key.Binding
+}
+
+var Tree = TreeKeys{
+	Up:       key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
+	Down:     key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "down")),
+	Top:      key.NewBinding(key.WithKeys("g", "home"), key.WithHelp("g", "top")),
+	Bottom:   key.NewBinding(key.WithKeys("G", "end"), key.WithHelp("G", "bottom")),
+	Expand:   key.NewBinding(key.WithKeys("enter", "right", "l", " "), key.WithHelp("↵/→", "expand")),
+	Collapse: key.NewBinding(key.WithKeys("left", "h"), key.WithHelp("←", "collapse")),
+	Filter:   key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "filter")),
+	Reload:   key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "reload")),
+	Open:     key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "open rows")),
+	DDL:      key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "DDL")),
+	Copy:     key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "copy name")),
+}
+
+// FormKeys drive the connection form while no field is being typed into
+type FormKeys struct {
+	Next    key.Binding
+	Prev    key.Binding
+	Edit    key.Binding
+	Change  key.Binding
+	Save    key.Binding
+	Test    key.Binding
+	URL     key.Binding
+	CopyURL key.Binding
+}
+
+var Form = FormKeys{
+	Next:    key.NewBinding(key.WithKeys("tab"), key.WithHelp("↹", "next")),
+	Prev:    key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("⇧↹", "prev")),
+	Edit:    key.NewBinding(key.WithKeys("enter"), key.WithHelp("↵", "edit")),
+	Change:  key.NewBinding(key.WithKeys("left", "right"), key.WithHelp("←/→", "change")),
+	Save:    key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("^s", "save")),
+	Test:    key.NewBinding(key.WithKeys("ctrl+t"), key.WithHelp("^t", "test")),
+	URL:     key.NewBinding(key.WithKeys("ctrl+u"), key.WithHelp("^u", "url")),
+	CopyURL: key.NewBinding(key.WithKeys("ctrl+y"), key.WithHelp("^y", "copy url")),
+}
+
+// FieldKeys apply while a field of a form is being typed into
+type FieldKeys struct {
+	Done   key.Binding
+	Cancel key.Binding
+}
+
+var Field = FieldKeys{
+	Done:   key.NewBinding(key.WithKeys("enter"), key.WithHelp("↵", "done")),
+	Cancel: key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "cancel")),
+}
+
+// SessionKeys drive the card of the open session
+type SessionKeys struct {
+	Editor    key.Binding
+	Databases key.Binding
+}
+
+var Session = SessionKeys{
+	Editor:    key.NewBinding(key.WithKeys("enter"), key.WithHelp("↵", "query editor")),
+	Databases: key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "databases")),
+}
+
+// QueryKeys drive the query editor and its result
+type QueryKeys struct {
+	Run      key.Binding
+	RunAll   key.Binding
+	Focus    key.Binding
+	Export   key.Binding
+	Complete key.Binding
+	History  key.Binding
+	Refresh  key.Binding
+	Select   key.Binding
+	Undo     key.Binding
+	Cancel   key.Binding
+}
+
+var Query = QueryKeys{
+	Run:      key.NewBinding(key.WithKeys("ctrl+e"), key.WithHelp("^e", "run statement")),
+	RunAll:   key.NewBinding(key.WithKeys("f5"), key.WithHelp("F5", "run all")),
+	Focus:    key.NewBinding(key.WithKeys("f6"), key.WithHelp("F6", "editor/results")),
+	Export:   key.NewBinding(key.WithKeys("ctrl+x"), key.WithHelp("^x", "export")),
+	Complete: key.NewBinding(key.WithKeys("ctrl+@"), key.WithHelp("^space", "complete")),
+	History:  key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("^r", "history")),
+	Refresh:  key.NewBinding(key.WithKeys("f9"), key.WithHelp("F9", "refresh schema")),
+	// Selecting and undoing are the editor's own; these only describe them
+	Select: key.NewBinding(key.WithKeys("shift+left", "shift+right", "shift+up", "shift+down"), key.WithHelp("⇧+arrows", "select")),
+	Undo:   key.NewBinding(key.WithKeys("ctrl+z", "ctrl+y"), key.WithHelp("^z/^y", "undo/redo")),
+	Cancel: key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "cancel")),
+}
+
+// DataKeys drive the table data viewer
+type DataKeys struct {
+	NextPage     key.Binding
+	PrevPage     key.Binding
+	Sort         key.Binding
+	Filter       key.Binding
+	ClearFilter  key.Binding
+	ClearFilters key.Binding
+	Reload       key.Binding
+	EditCell     key.Binding
+	AddRow       key.Binding
+	DeleteRow    key.Binding
+	RevertRow    key.Binding
+	RevertAll    key.Binding
+	Commit       key.Binding
+	EditSQL      key.Binding
+	Export       key.Binding
+	Cancel       key.Binding
+}
+
+var Data = DataKeys{
+	NextPage:     key.NewBinding(key.WithKeys("]", "n"), key.WithHelp("]", "next page")),
+	PrevPage:     key.NewBinding(key.WithKeys("[", "p"), key.WithHelp("[", "prev page")),
+	Sort:         key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "sort")),
+	Filter:       key.NewBinding(key.WithKeys("f"), key.WithHelp("f", "filter")),
+	ClearFilter:  key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "clear filter")),
+	ClearFilters: key.NewBinding(key.WithKeys("X"), key.WithHelp("X", "clear all")),
+	Reload:       key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "reload")),
+	EditCell:     key.NewBinding(key.WithKeys("enter", "i"), key.WithHelp("↵", "edit cell")),
+	AddRow:       key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "add row")),
+	DeleteRow:    key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "delete row")),
+	RevertRow:    key.NewBinding(key.WithKeys("u"), key.WithHelp("u", "revert row")),
+	RevertAll:    key.NewBinding(key.WithKeys("U"), key.WithHelp("U", "revert all")),
+	Commit:       key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("^s", "review & commit")),
+	EditSQL:      key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "edit SQL")),
+	Export:       key.NewBinding(key.WithKeys("ctrl+x"), key.WithHelp("^x", "export")),
+	Cancel:       key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "cancel")),
+}
+
+// BusyKeys apply while a session is being opened
+type BusyKeys struct {
+	Cancel key.Binding
+	Quit   key.Binding
+}
+
+var Busy = BusyKeys{
+	Cancel: key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "cancel")),
+	Quit:   key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("^c", "quit")),
+}
+
+// When returns b switched on or off, leaving the shared binding untouched.
+// Bindings that are off match no key and are left out of the help.
+func When(b key.Binding, on bool) key.Binding {
+	b.SetEnabled(on)
+	return b
+}
+
+// Claims reports whether a key is one of the bindings
+func Claims(msg tea.KeyMsg, bindings []key.Binding) bool {
+	return key.Matches(msg, bindings...)
+}
+
+// Help writes the hints for the bindings that are on, e.g. "^s: save"
+func Help(bindings ...key.Binding) []string {
+	var hints []string
+	for _, b := range bindings {
+		if !b.Enabled() || b.Help().Key == "" {
+			continue
+		}
+		hints = append(hints, b.Help().Key+": "+b.Help().Desc)
+	}
+	return hints
+}
@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"nectar/cli"
 	"nectar/screens"
 	"os"
+	"os/signal"
+	"syscall"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
+	// With a command, run it in the terminal rather than opening the screens
+	if len(os.Args) > 1 {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		code := cli.Run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+		stop()
+		os.Exit(code)
+	}
+
 	program := tea.NewProgram(screens.Start(), tea.WithAltScreen())
 	if _, err := program.Run(); err != nil {
 		fmt.Println("Error running program:", err)
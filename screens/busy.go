@@ -0,0 +1,79 @@
+package screens
+
+import (
+	"context"
+	"nectar/components/root"
+	"nectar/components/shared"
+	"nectar/keymap"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// busyScreen covers the connections screen while a session opens, saying
+// for how long it has been trying. esc gives up, cancelling the attempt.
+type busyScreen struct {
+	back   *rootScreen
+	label  string
+	busy   shared.BusyModel
+	cancel context.CancelFunc
+}
+
+func _busy(back *rootScreen, label string, cancel context.CancelFunc) tea.Model {
+	return &busyScreen{
+		back:   back,
+		label:  label,
+		busy:   shared.NewBusy(),
+		cancel: cancel,
+	}
+}
+
+func (b *busyScreen) Init() tea.Cmd {
+	var cmd tea.Cmd
+	b.busy, cmd = b.busy.Start(b.label, nil)
+	return cmd
+}
+
+func (b *busyScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keymap.Busy.Quit):
+			b.cancel()
+			b.back.mainArea.CloseSession()
+			return b, tea.Quit
+		case key.Matches(msg, keymap.Busy.Cancel):
+			b.cancel()
+			return b, switchScreen(b.back)
+		}
+		return b, nil
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		b.busy, cmd = b.busy.Update(msg)
+		return b, cmd
+	}
+
+	// The connections screen keeps up with everything else, and is back in
+	// front once the attempt has come to something
+	_, cmd := b.back.Update(msg)
+	switch msg.(type) {
+	case root.SessionOpenedMsg, root.SessionFailedMsg, root.UnlockVaultMsg, root.PasswordRequiredMsg:
+		b.cancel()
+		return b, tea.Batch(cmd, switchScreen(b.back))
+	}
+	return b, cmd
+}
+
+// bindings lists the keys that work while the attempt runs
+func (b *busyScreen) bindings() []key.Binding {
+	return []key.Binding{keymap.Busy.Cancel, keymap.Busy.Quit}
+}
+
+func (b *busyScreen) View() string {
+	view := b.back.render(b.bindings())
+	if !b.busy.Shown() {
+		return view
+	}
+	return shared.Center(view, b.busy.View(globals.Width), globals.Width, globals.Height)
+}
@@ -0,0 +1,80 @@
+package screens
+
+import (
+	"nectar/components/data"
+	"nectar/components/root"
+	"nectar/keymap"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// dataScreen pages through a table of the session opened on back, the
+// connections screen it returns to
+type dataScreen struct {
+	back *rootScreen
+	data data.DataModel
+}
+
+func _data(back *rootScreen, msg root.OpenTableMsg) tea.Model {
+	return &dataScreen{
+		back: back,
+		data: data.NewData(msg.Connection, msg.Driver, msg.Schema, msg.Object, history),
+	}
+}
+
+func (d *dataScreen) Init() tea.Cmd {
+	d.resize()
+	var cmd tea.Cmd
+	d.data, cmd = d.data.Load()
+	return cmd
+}
+
+func (d *dataScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	d.resize()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keymap.Global.ForceQuit):
+			d.data.Close()
+			d.back.mainArea.CloseSession()
+			return d, tea.Quit
+		case key.Matches(msg, keymap.Global.Back):
+			if !d.data.Capturing() {
+				return d, switchScreen(d.back)
+			}
+		}
+	case root.OpenEditorMsg:
+		d.data.Close()
+		return d, switchScreen(_query(d.back, msg))
+	}
+
+	var cmd tea.Cmd
+	d.data, cmd = d.data.Update(msg)
+	return d, cmd
+}
+
+// bindings lists the keys that work right now, the viewer's first
+func (d *dataScreen) bindings() []key.Binding {
+	return append(d.data.Bindings(),
+		keymap.When(keymap.Global.Back, !d.data.Capturing()),
+		keymap.Global.ForceQuit,
+	)
+}
+
+// The rows fill everything between the title and the status bar
+func (d *dataScreen) resize() {
+	d.data = d.data.SetSize(globals.Width, globals.Height-2)
+}
+
+func (d *dataScreen) View() string {
+	conn, object := d.data.Connection(), d.data.Object()
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		sessionTitle(conn, conn.Type.String()+" · "+object.Schema+"."+object.Name),
+		d.data.View(),
+		root.StatusBar(&globals, d.bindings()...),
+	)
+}
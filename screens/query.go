@@ -0,0 +1,114 @@
+package screens
+
+import (
+	"nectar/components/query"
+	"nectar/components/root"
+	"nectar/components/shared"
+	"nectar/keymap"
+	"nectar/types"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// queryScreen edits and runs SQL against the session opened on back, the
+// connections screen it returns to
+type queryScreen struct {
+	back  *rootScreen
+	query query.QueryModel
+	run   bool
+}
+
+func _query(back *rootScreen, msg root.OpenEditorMsg) tea.Model {
+	q := &queryScreen{
+		back:  back,
+		query: query.NewQuery(msg.Connection, msg.Driver, msg.Schema, history),
+		run:   msg.Run,
+	}
+	if msg.Query != "" {
+		q.query = q.query.SetScript(msg.Query)
+	}
+	return q
+}
+
+func (q *queryScreen) Init() tea.Cmd {
+	q.resize()
+	if q.run {
+		q.run = false
+		var cmd tea.Cmd
+		q.query, cmd = q.query.RunAll()
+		return tea.Batch(q.query.Init(), cmd)
+	}
+	return q.query.Init()
+}
+
+func (q *queryScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	q.resize()
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, keymap.Global.ForceQuit):
+			q.query.Close()
+			q.back.mainArea.CloseSession()
+			return q, tea.Quit
+		case key.Matches(msg, keymap.Global.Back):
+			if !q.query.Capturing() {
+				return q, switchScreen(q.back)
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	q.query, cmd = q.query.Update(msg)
+	return q, cmd
+}
+
+// bindings lists the keys that work right now, the editor's first
+func (q *queryScreen) bindings() []key.Binding {
+	return append(q.query.Bindings(),
+		keymap.When(keymap.Global.Back, !q.query.Capturing()),
+		keymap.Global.ForceQuit,
+	)
+}
+
+// The editor fills everything between the title and the status bar
+func (q *queryScreen) resize() {
+	q.query = q.query.SetSize(globals.Width, globals.Height-2)
+}
+
+func (q *queryScreen) View() string {
+	conn := q.query.Connection()
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		sessionTitle(conn, conn.Type.String()),
+		q.query.View(),
+		root.StatusBar(&globals, q.bindings()...),
+	)
+}
+
+// sessionTitle is the line naming the connection at the top of the screens
+// that work on a session
+func sessionTitle(conn types.Connection, detail string) string {
+	title := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Text().Hex,
+			Dark:  catppuccin.Mocha.Text().Hex,
+		}).
+		Bold(true).
+		Render(" " + conn.Name)
+
+	marker := lipgloss.NewStyle().
+		Foreground(shared.ConnectionColors[shared.ColorIndex(conn.Color)].Color).
+		Render(" ●")
+
+	engine := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		}).
+		Render("  " + detail)
+
+	return marker + title + engine
+}
@@ -1,50 +1,186 @@
 package screens
 
 import (
+	"context"
 	"nectar/components/root"
+	"nectar/keymap"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 type rootScreen struct {
+	sidebar  root.SidebarModel
 	mainArea root.MainAreaModel
 }
 
 func _root() tea.Model {
 	return &rootScreen{
-		mainArea: root.NewMainArea(),
+		sidebar:  root.NewSidebar(connections),
+		mainArea: root.NewMainArea(connections),
 	}
 }
 
 func (r *rootScreen) Init() tea.Cmd {
-	return r.mainArea.Init()
+	r.resize()
+	return tea.Batch(r.sidebar.Init(), r.mainArea.Init())
 }
 
+// Update handles msg, then fits the panes to the layout that leaves, since
+// moving focus changes it as much as resizing the terminal does
 func (r *rootScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := r.update(msg)
+	r.resize()
+	return model, cmd
+}
+
+func (r *rootScreen) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-		globals.Width, globals.Height = msg.Width, msg.Height
+		return r, nil
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c", "q":
+		if key.Matches(msg, keymap.Global.ForceQuit) {
+			r.mainArea.CloseSession()
 			return r, tea.Quit
 		}
+		return r.handleFocusKeys(msg)
+	case root.OpenEditorMsg:
+		return r, switchScreen(_query(r, msg))
+	case root.OpenTableMsg:
+		return r, switchScreen(_data(r, msg))
+	case root.EditConnectionMsg:
+		r.sidebar = r.sidebar.Blur()
+		return r.updateMainArea(msg)
+	case root.SessionOpenedMsg:
+		// Move focus to the session so it can be used straight away
+		r.sidebar = r.sidebar.Blur()
+	case root.UnlockVaultMsg, root.PasswordRequiredMsg:
+		// The prompt lives in the main area, so it needs the keys
+		r.sidebar = r.sidebar.Blur()
+	case root.ConnectMsg:
+		var cmd tea.Cmd
+		r.sidebar, cmd = r.sidebar.Update(msg)
+		// The busy screen is up before the session can come back to it
+		ctx, cancel := context.WithCancel(context.Background())
+		busy := _busy(r, "Connecting to "+msg.Connection.Name, cancel)
+		return r, tea.Batch(cmd, tea.Sequence(switchScreen(busy), root.OpenSession(ctx, connections, msg.Connection)))
 	}
 
+	// Everything that isn't a key press is of interest to both panes
+	var sidebarCmd, mainCmd tea.Cmd
+	r.sidebar, sidebarCmd = r.sidebar.Update(msg)
+	r.mainArea, mainCmd = r.mainArea.Update(msg)
+	return r, tea.Batch(sidebarCmd, mainCmd)
+}
+
+// Route key presses to the focused pane, which has the first claim on
+// them. Keys it leaves alone fall to the global bindings: tab leaves the
+// sidebar for the form and esc returns to it.
+func (r *rootScreen) handleFocusKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if r.sidebar.Focused() {
+		if r.sidebar.Capturing() || keymap.Claims(msg, r.sidebar.Bindings()) {
+			var cmd tea.Cmd
+			r.sidebar, cmd = r.sidebar.Update(msg)
+			return r, cmd
+		}
+		if key.Matches(msg, keymap.Global.NextPane) {
+			r.sidebar = r.sidebar.Blur()
+			return r, nil
+		}
+		return r.handleGlobalKeys(msg)
+	}
+
+	if r.mainArea.Capturing() || keymap.Claims(msg, r.mainArea.Bindings()) {
+		return r.updateMainArea(msg)
+	}
+	if key.Matches(msg, keymap.Global.Back) {
+		r.sidebar = r.sidebar.Focus()
+		return r, nil
+	}
+	return r.handleGlobalKeys(msg)
+}
+
+// handleGlobalKeys acts on the keys no pane claimed
+func (r *rootScreen) handleGlobalKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keymap.Global.Quit):
+		r.mainArea.CloseSession()
+		return r, tea.Quit
+	case key.Matches(msg, keymap.Global.New):
+		r.sidebar = r.sidebar.Blur()
+		return r.updateMainArea(root.NewConnectionMsg{})
+	case key.Matches(msg, keymap.Global.Sidebar):
+		// Show a hidden sidebar, or hide the one shown
+		if root.NewLayout(&globals, r.sidebar).Sidebar == 0 {
+			r.sidebar = r.sidebar.Focus()
+		} else {
+			r.sidebar = r.sidebar.Collapse()
+		}
+	case key.Matches(msg, keymap.Global.Resize):
+		if !root.NewLayout(&globals, r.sidebar).Narrow() {
+			step := root.SidebarStep
+			if msg.String() == "<" {
+				step = -step
+			}
+			r.sidebar = r.sidebar.Resize(step, globals.Width)
+		}
+	}
+	return r, nil
+}
+
+// resize fits the main area to the columns the layout leaves it
+func (r *rootScreen) resize() {
+	layout := root.NewLayout(&globals, r.sidebar)
+	r.mainArea = r.mainArea.SetSize(layout.Main, layout.Height)
+}
+
+// bindings lists the keys that work right now, the focused pane's first
+func (r *rootScreen) bindings() []key.Binding {
+	sideBySide := !root.NewLayout(&globals, r.sidebar).Narrow()
+	if r.sidebar.Focused() {
+		capturing := r.sidebar.Capturing()
+		return append(r.sidebar.Bindings(),
+			keymap.When(keymap.Global.NextPane, !capturing),
+			keymap.When(keymap.Global.New, !capturing),
+			keymap.When(keymap.Global.Sidebar, !capturing),
+			keymap.When(keymap.Global.Resize, !capturing && sideBySide),
+			keymap.When(keymap.Global.Quit, !capturing),
+			keymap.When(keymap.Global.ForceQuit, capturing),
+		)
+	}
+	capturing := r.mainArea.Capturing()
+	return append(r.mainArea.Bindings(),
+		keymap.When(keymap.Global.Back, !capturing),
+		keymap.When(keymap.Global.New, !capturing),
+		keymap.When(keymap.Global.Sidebar, !capturing),
+		keymap.When(keymap.Global.Resize, !capturing && sideBySide),
+		keymap.When(keymap.Global.Quit, !capturing),
+		keymap.When(keymap.Global.ForceQuit, capturing),
+	)
+}
+
+func (r *rootScreen) updateMainArea(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	r.mainArea, cmd = r.mainArea.Update(msg)
 	return r, cmd
 }
 
 func (r *rootScreen) View() string {
+	return r.render(r.bindings())
+}
+
+// render draws the screen with hints for the given bindings, so a screen
+// covering it can show its own keys in the status bar
+func (r *rootScreen) render(bindings []key.Binding) string {
+	layout := root.NewLayout(&globals, r.sidebar)
 	return lipgloss.JoinVertical(
 		lipgloss.Top,
 		lipgloss.JoinHorizontal(
 			lipgloss.Left,
-			root.Sidebar(&globals),
-			root.MainArea(&globals, r.mainArea),
+			root.Sidebar(layout, r.sidebar),
+			root.MainArea(layout, r.mainArea),
 		),
-		root.StatusBar(&globals),
+		root.StatusBar(&globals, bindings...),
 	)
 }
@@ -1,14 +1,26 @@
 package screens
 
 import (
+	"fmt"
 	"nectar/build"
+	"nectar/store"
 	"nectar/types"
+	"nectar/utils"
 	"os"
 
+	catppuccin "github.com/catppuccin/go"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"golang.org/x/term"
 )
 
+// The smallest terminal the screens are drawn in; below it they make way
+// for a notice asking for more room
+const (
+	minWidth  = 40
+	minHeight = 12
+)
+
 type SwitchMsg struct {
 	Screen tea.Model
 }
@@ -17,20 +29,33 @@ type ScreenManager struct {
 	currentScreen tea.Model
 }
 
-var globals types.Globals
+var (
+	globals     types.Globals
+	connections *store.Store
+	history     *store.History
+)
 
 func (sm *ScreenManager) Init() tea.Cmd {
-	return sm.currentScreen.Init()
+	return tea.Batch(sm.currentScreen.Init(), pruneHistory)
+}
+
+// pruneHistory trims the query history to its limits at startup. It is
+// best effort: a history that can't be pruned just grows until next time.
+func pruneHistory() tea.Msg {
+	_ = history.Prune()
+	return nil
 }
 
 func (sm *ScreenManager) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch m := msg.(type) {
 	case tea.WindowSizeMsg:
+		// Screens lay themselves out from globals, and get the message too
+		// for anything else that depends on the size
 		globals.Width, globals.Height = m.Width, m.Height
-		return sm, nil
 	case SwitchMsg:
+		// The screen may have last been drawn at another size
 		sm.currentScreen = m.Screen
-		return sm, sm.currentScreen.Init()
+		return sm, tea.Batch(sm.currentScreen.Init(), resize)
 	}
 
 	updatedScreen, cmd := sm.currentScreen.Update(msg)
@@ -39,9 +64,46 @@ func (sm *ScreenManager) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (sm *ScreenManager) View() string {
+	if globals.Width < minWidth || globals.Height < minHeight {
+		return tooSmall()
+	}
 	return sm.currentScreen.View()
 }
 
+// resize tells the screen the terminal's current size
+func resize() tea.Msg {
+	return tea.WindowSizeMsg{Width: globals.Width, Height: globals.Height}
+}
+
+// tooSmall fills the terminal with a notice of how much room is needed
+func tooSmall() string {
+	warningStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Red().Hex,
+			Dark:  catppuccin.Mocha.Red().Hex,
+		}).
+		Bold(true)
+
+	mutedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		})
+
+	notice := lipgloss.JoinVertical(
+		lipgloss.Center,
+		warningStyle.Render("Terminal too small"),
+		mutedStyle.Render(fmt.Sprintf("%d×%d, needs %d×%d", globals.Width, globals.Height, minWidth, minHeight)),
+	)
+	return lipgloss.Place(
+		globals.Width,
+		globals.Height,
+		lipgloss.Center,
+		lipgloss.Center,
+		lipgloss.NewStyle().MaxWidth(globals.Width).Render(notice),
+	)
+}
+
 func switchScreen(screen tea.Model) tea.Cmd {
 	return func() tea.Msg {
 		return SwitchMsg{Screen: screen}
@@ -62,5 +124,17 @@ func Start() tea.Model {
 		Version:   build.Version,
 	}
 
+	configDir, err := utils.ConfigDir()
+	if err != nil {
+		configDir = "."
+	}
+	connections = store.New(configDir)
+
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		dataDir = configDir
+	}
+	history = store.NewHistory(dataDir)
+
 	return &ScreenManager{currentScreen: _root()}
 }
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"nectar/types"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// HistoryFileName is the name of the query history inside the data directory
+const HistoryFileName = "history.jsonl"
+
+// How much history is kept unless told otherwise: the newest entries up to
+// the limit, none older than the age
+const (
+	DefaultHistoryLimit  = 10000
+	DefaultHistoryMaxAge = 180 * 24 * time.Hour
+)
+
+// HistoryEntry is one statement as it was run
+type HistoryEntry struct {
+	Connection string        `json:"connection"`
+	Query      string        `json:"query"`
+	Time       time.Time     `json:"time"`
+	Duration   time.Duration `json:"duration"`
+	// Rows is how many rows the statement returned or changed
+	Rows  int64  `json:"rows"`
+	Error string `json:"error,omitempty"`
+}
+
+// History keeps the statements run through nectar in a file of one JSON
+// entry per line, so recording one is a single append. Limit and MaxAge
+// bound what Prune keeps; zero leaves that side unbounded.
+type History struct {
+	path   string
+	Limit  int
+	MaxAge time.Duration
+	mu     sync.Mutex
+}
+
+// NewHistory returns the query history kept inside dir
+func NewHistory(dir string) *History {
+	return &History{
+		path:   filepath.Join(dir, HistoryFileName),
+		Limit:  DefaultHistoryLimit,
+		MaxAge: DefaultHistoryMaxAge,
+	}
+}
+
+// Path returns the location of the history file
+func (h *History) Path() string {
+	return h.path
+}
+
+// Record appends statements run against conn. Nothing is kept for a
+// connection flagged sensitive.
+func (h *History) Record(conn types.Connection, entries ...HistoryEntry) error {
+	if conn.Sensitive || len(entries) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		entry.Connection = conn.Name
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := lockFile(h.path+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load returns every entry, oldest first
+func (h *History) Load() ([]HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	unlock, err := lockFile(h.path+".lock", false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, _, err := h.read()
+	return entries, err
+}
+
+// Prune drops the entries past the limits, and any lines left unreadable
+// by an append that was cut short
+func (h *History) Prune() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	unlock, err := lockFile(h.path+".lock", true)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer unlock()
+
+	entries, damaged, err := h.read()
+	if err != nil {
+		return err
+	}
+
+	kept := entries
+	if h.MaxAge > 0 {
+		cutoff := time.Now().Add(-h.MaxAge)
+		for len(kept) > 0 && kept[0].Time.Before(cutoff) {
+			kept = kept[1:]
+		}
+	}
+	if h.Limit > 0 && len(kept) > h.Limit {
+		kept = kept[len(kept)-h.Limit:]
+	}
+	if len(kept) == len(entries) && !damaged {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range kept {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return writeFile(h.path, buf.Bytes())
+}
+
+// read parses the history file, skipping lines that don't parse and
+// reporting whether there were any
+func (h *History) read() ([]HistoryEntry, bool, error) {
+	f, err := os.Open(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	damaged := false
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			damaged = true
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, damaged, scanner.Err()
+}
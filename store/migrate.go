@@ -0,0 +1,40 @@
+package store
+
+import "encoding/json"
+
+// migrations upgrade a single saved connection from the version given by
+// the key to the next one
+var migrations = map[int]func(conn map[string]any){
+	1: migrateEnableSSL,
+}
+
+// migrate brings raw connections written by an older version up to date
+func migrate(version int, raw []json.RawMessage) ([]json.RawMessage, error) {
+	for ; version < FormatVersion; version++ {
+		upgrade, ok := migrations[version]
+		if !ok {
+			continue
+		}
+		for i, data := range raw {
+			var conn map[string]any
+			if err := json.Unmarshal(data, &conn); err != nil {
+				return nil, err
+			}
+			upgrade(conn)
+			migrated, err := json.Marshal(conn)
+			if err != nil {
+				return nil, err
+			}
+			raw[i] = migrated
+		}
+	}
+	return raw, nil
+}
+
+// Version 1 only had an SSL on/off switch, which meant sslmode=require
+func migrateEnableSSL(conn map[string]any) {
+	if enabled, _ := conn["enable_ssl"].(bool); enabled {
+		conn["tls"] = map[string]any{"mode": "require"}
+	}
+	delete(conn, "enable_ssl")
+}
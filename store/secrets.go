@@ -0,0 +1,188 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"nectar/types"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// CommandTimeout bounds how long a password command may run. It is generous
+// because password managers may wait for a touch or biometric prompt.
+const CommandTimeout = 60 * time.Second
+
+// ErrPasswordRequired is returned by Resolve for connections whose password
+// is asked for on every connect
+var ErrPasswordRequired = errors.New("password required")
+
+// Resolve fills in the passwords of a saved connection from wherever its
+// password storage keeps them. Passwords already set on conn are kept, so a
+// prompted password can be passed back in.
+func (s *Store) Resolve(ctx context.Context, conn types.Connection) (types.Connection, error) {
+	needVaultPassword := conn.PasswordStorage == types.PasswordVault && conn.Password == ""
+	needSSHPassword := conn.SSH.Enabled && conn.SSH.Password == ""
+	if conn.InVault && (needVaultPassword || needSSHPassword) {
+		secret, err := s.vault.Get(conn.Name)
+		if err != nil {
+			return conn, err
+		}
+		if needVaultPassword {
+			conn.Password = secret.Password
+		}
+		if needSSHPassword {
+			conn.SSH.Password = secret.SSHPassword
+		}
+	}
+
+	if conn.Password != "" {
+		return conn, nil
+	}
+	switch conn.PasswordStorage {
+	case types.PasswordPrompt:
+		return conn, ErrPasswordRequired
+	case types.PasswordCommand:
+		password, err := RunPasswordCommand(ctx, conn)
+		if err != nil {
+			return conn, err
+		}
+		conn.Password = password
+	}
+	return conn, nil
+}
+
+// RunPasswordCommand runs conn's password command through the shell and
+// returns the first line it prints, which is where pass and friends put the
+// password. The connection's details are passed in NECTAR_* environment
+// variables so one command can serve several connections.
+func RunPasswordCommand(ctx context.Context, conn types.Connection) (string, error) {
+	command := strings.TrimSpace(conn.PasswordCommand)
+	if command == "" {
+		return "", errors.New("no password command set")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(),
+		"NECTAR_CONNECTION="+conn.Name,
+		"NECTAR_HOST="+conn.Host,
+		"NECTAR_PORT="+conn.Port,
+		"NECTAR_USER="+conn.User,
+		"NECTAR_DATABASE="+conn.Database,
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("password command timed out after %s", CommandTimeout)
+		}
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return "", fmt.Errorf("password command failed: %s", detail)
+		}
+		return "", fmt.Errorf("password command failed: %w", err)
+	}
+
+	password, _, _ := strings.Cut(stdout.String(), "\n")
+	password = strings.TrimSuffix(password, "\r")
+	if password == "" {
+		return "", errors.New("password command printed nothing")
+	}
+	return password, nil
+}
+
+// seal moves conn's passwords into the vault under its name and strips them
+// from the record that goes to disk. previous is the record conn replaces,
+// if any; its vault entry is carried over for passwords conn leaves empty.
+func (s *Store) seal(conn types.Connection, previous *types.Connection) (types.Connection, error) {
+	secret := Secret{SSHPassword: conn.SSH.Password}
+	if conn.PasswordStorage == types.PasswordVault {
+		secret.Password = conn.Password
+	}
+	conn.Password, conn.SSH.Password = "", ""
+	carried := previous != nil && previous.InVault
+
+	if !s.vault.Unlocked() {
+		// Nothing new to store and the entry keeps its name, so the vault
+		// doesn't need to be opened
+		if secret == (Secret{}) && (!carried || previous.Name == conn.Name) {
+			conn.InVault = carried
+			return conn, nil
+		}
+		return conn, ErrVaultLocked
+	}
+
+	err := s.vault.update(func(secrets map[string]Secret) {
+		var entry Secret
+		if carried {
+			entry = secrets[previous.Name]
+			delete(secrets, previous.Name)
+		}
+		if secret.Password != "" || conn.PasswordStorage != types.PasswordVault {
+			entry.Password = secret.Password
+		}
+		if secret.SSHPassword != "" {
+			entry.SSHPassword = secret.SSHPassword
+		}
+
+		conn.InVault = entry != (Secret{})
+		if conn.InVault {
+			secrets[conn.Name] = entry
+		} else {
+			delete(secrets, conn.Name)
+		}
+	})
+	return conn, err
+}
+
+// tidyVault runs once the vault is unlocked: passwords left in plain text
+// by older versions are moved into it, and entries for connections that
+// were deleted while it was locked are dropped
+func (s *Store) tidyVault(doc *document) error {
+	if !s.vault.Unlocked() {
+		return nil
+	}
+
+	kept := map[string]bool{}
+	for i, conn := range doc.Connections {
+		if conn.Password != "" || conn.SSH.Password != "" {
+			sealed, err := s.seal(conn, &conn)
+			if err != nil {
+				return err
+			}
+			doc.Connections[i] = sealed
+		}
+		if doc.Connections[i].InVault {
+			kept[conn.Name] = true
+		}
+	}
+
+	return s.vault.update(func(secrets map[string]Secret) {
+		for name := range secrets {
+			if !kept[name] {
+				delete(secrets, name)
+			}
+		}
+	})
+}
+
+// Unlock opens the vault with the master password, creating it if needed,
+// and moves any passwords still kept in plain text into it
+func (s *Store) Unlock(master string) error {
+	if err := s.vault.Unlock(master); err != nil {
+		return err
+	}
+	return s.modify(func(doc *document) error { return nil })
+}
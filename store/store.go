@@ -0,0 +1,311 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nectar/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FormatVersion is the current version of the on-disk connections file
+const FormatVersion = 2
+
+// FileName is the name of the connections file inside the config directory
+const FileName = "connections.json"
+
+var (
+	ErrNotFound    = errors.New("connection not found")
+	ErrExists      = errors.New("a connection with this name already exists")
+	ErrEmptyName   = errors.New("connection name is required")
+	ErrNewerFormat = errors.New("connections file was written by a newer version of nectar")
+)
+
+// document is the on-disk representation of the connections file
+type document struct {
+	Version     int                `json:"version"`
+	Connections []types.Connection `json:"connections"`
+}
+
+// Store persists saved connections to a single JSON file. Every operation
+// re-reads the file under a lock so that several nectar processes can share it.
+type Store struct {
+	path  string
+	vault *Vault
+	mu    sync.Mutex
+}
+
+// New returns a store backed by the connections file and credential vault
+// inside dir
+func New(dir string) *Store {
+	return &Store{path: filepath.Join(dir, FileName), vault: NewVault(dir)}
+}
+
+// Vault returns the credential vault holding the saved passwords
+func (s *Store) Vault() *Vault {
+	return s.vault
+}
+
+// Path returns the location of the connections file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Load returns all saved connections in the order they were added
+func (s *Store) Load() ([]types.Connection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockFile(s.path+".lock", false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	doc, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return doc.Connections, nil
+}
+
+// Get returns the saved connection with the given name
+func (s *Store) Get(name string) (types.Connection, error) {
+	connections, err := s.Load()
+	if err != nil {
+		return types.Connection{}, err
+	}
+	if i := indexOf(connections, name); i >= 0 {
+		return connections[i], nil
+	}
+	return types.Connection{}, ErrNotFound
+}
+
+// Save adds a new connection. It fails with ErrExists if the name is taken,
+// and with ErrVaultLocked if it has passwords to store while the vault is
+// locked.
+func (s *Store) Save(conn types.Connection) error {
+	conn.Name = strings.TrimSpace(conn.Name)
+	if conn.Name == "" {
+		return ErrEmptyName
+	}
+
+	return s.modify(func(doc *document) error {
+		if indexOf(doc.Connections, conn.Name) >= 0 {
+			return ErrExists
+		}
+		sealed, err := s.seal(conn, nil)
+		if err != nil {
+			return err
+		}
+		doc.Connections = append(doc.Connections, sealed)
+		return nil
+	})
+}
+
+// SaveCopy adds conn as a copy of the connection saved under from. Passwords
+// conn leaves empty are copied from the original's vault entry and an empty
+// password command from the original, since the edit form never shows them.
+// Copying a vault entry fails with ErrVaultLocked while the vault is locked.
+func (s *Store) SaveCopy(from string, conn types.Connection) error {
+	conn.Name = strings.TrimSpace(conn.Name)
+	if conn.Name == "" {
+		return ErrEmptyName
+	}
+
+	return s.modify(func(doc *document) error {
+		i := indexOf(doc.Connections, from)
+		if i < 0 {
+			return ErrNotFound
+		}
+		if indexOf(doc.Connections, conn.Name) >= 0 {
+			return ErrExists
+		}
+
+		original := doc.Connections[i]
+		if conn.PasswordStorage == types.PasswordCommand && strings.TrimSpace(conn.PasswordCommand) == "" {
+			conn.PasswordCommand = original.PasswordCommand
+		}
+		copyPassword := conn.PasswordStorage == types.PasswordVault && conn.Password == "" &&
+			original.PasswordStorage == types.PasswordVault
+		copySSHPassword := conn.SSH.Enabled && conn.SSH.Password == ""
+		if original.InVault && (copyPassword || copySSHPassword) {
+			secret, err := s.vault.Get(original.Name)
+			if err != nil {
+				return err
+			}
+			if copyPassword {
+				conn.Password = secret.Password
+			}
+			if copySSHPassword {
+				conn.SSH.Password = secret.SSHPassword
+			}
+		}
+
+		sealed, err := s.seal(conn, nil)
+		if err != nil {
+			return err
+		}
+		doc.Connections = append(doc.Connections, sealed)
+		return nil
+	})
+}
+
+// Update replaces the connection saved under name with conn, which may
+// carry a different name to rename it. Passwords left empty keep the ones
+// already in the vault.
+func (s *Store) Update(name string, conn types.Connection) error {
+	conn.Name = strings.TrimSpace(conn.Name)
+	if conn.Name == "" {
+		return ErrEmptyName
+	}
+
+	return s.modify(func(doc *document) error {
+		i := indexOf(doc.Connections, name)
+		if i < 0 {
+			return ErrNotFound
+		}
+		if j := indexOf(doc.Connections, conn.Name); j >= 0 && j != i {
+			return ErrExists
+		}
+		sealed, err := s.seal(conn, &doc.Connections[i])
+		if err != nil {
+			return err
+		}
+		doc.Connections[i] = sealed
+		return nil
+	})
+}
+
+// Delete removes the connection saved under name. Its vault entry goes
+// with it, or the next time the vault is unlocked.
+func (s *Store) Delete(name string) error {
+	return s.modify(func(doc *document) error {
+		i := indexOf(doc.Connections, name)
+		if i < 0 {
+			return ErrNotFound
+		}
+		doc.Connections = append(doc.Connections[:i], doc.Connections[i+1:]...)
+		return nil
+	})
+}
+
+// modify runs fn against the current file contents and writes the result
+// back, holding an exclusive lock for the whole read-modify-write cycle
+func (s *Store) modify(fn func(doc *document) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(s.path+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	doc, err := s.read()
+	if err != nil {
+		return err
+	}
+	if err := fn(&doc); err != nil {
+		return err
+	}
+	if err := s.tidyVault(&doc); err != nil {
+		return err
+	}
+	return s.write(doc)
+}
+
+func (s *Store) read() (document, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return document{Version: FormatVersion}, nil
+	}
+	if err != nil {
+		return document{}, err
+	}
+
+	var raw struct {
+		Version     int               `json:"version"`
+		Connections []json.RawMessage `json:"connections"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return document{}, fmt.Errorf("parsing %s: %w", s.path, err)
+	}
+	connections, err := DecodeConnections(raw.Version, raw.Connections)
+	if err != nil {
+		return document{}, fmt.Errorf("reading %s: %w", s.path, err)
+	}
+	return document{Version: FormatVersion, Connections: connections}, nil
+}
+
+// DecodeConnections parses connections written in the given format
+// version, migrating them to the current one first
+func DecodeConnections(version int, raw []json.RawMessage) ([]types.Connection, error) {
+	if version > FormatVersion {
+		return nil, ErrNewerFormat
+	}
+	raw, err := migrate(version, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	connections := make([]types.Connection, len(raw))
+	for i, data := range raw {
+		if err := json.Unmarshal(data, &connections[i]); err != nil {
+			return nil, err
+		}
+	}
+	return connections, nil
+}
+
+// write replaces the connections file with doc
+func (s *Store) write(doc document) error {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(s.path, append(data, '\n'))
+}
+
+// writeFile replaces path atomically by writing to a temporary file in the
+// same directory and renaming it over the original
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func indexOf(connections []types.Connection, name string) int {
+	for i, conn := range connections {
+		if conn.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"nectar/types"
+	"testing"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s := New(t.TempDir())
+	if err := s.Unlock("master"); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSaveCopyCarriesVaultPasswords(t *testing.T) {
+	s := newTestStore(t)
+	original := types.Connection{
+		Name:     "prod",
+		Type:     types.PostgreSQL,
+		Host:     "db.example.com",
+		Password: "secret",
+		SSH:      types.SSHTunnel{Enabled: true, Host: "bastion", Password: "ssh-secret"},
+	}
+	if err := s.Save(original); err != nil {
+		t.Fatal(err)
+	}
+
+	// The edit form leaves both passwords empty when they are in the vault
+	saved, err := s.Get("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved.Name = "prod (copy)"
+	if err := s.SaveCopy("prod", saved); err != nil {
+		t.Fatal(err)
+	}
+
+	copied, err := s.Get("prod (copy)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := s.Resolve(context.Background(), copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Password != "secret" || resolved.SSH.Password != "ssh-secret" {
+		t.Fatalf("copy resolved to passwords %q and %q", resolved.Password, resolved.SSH.Password)
+	}
+
+	// The original keeps its own entry
+	if saved, err = s.Get("prod"); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err = s.Resolve(context.Background(), saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Password != "secret" {
+		t.Fatalf("original resolved to password %q after copying", resolved.Password)
+	}
+}
+
+func TestSaveCopyKeepsNewPassword(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.Save(types.Connection{Name: "prod", Type: types.MySQL, Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveCopy("prod", types.Connection{Name: "staging", Type: types.MySQL, Password: "other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	copied, err := s.Get("staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := s.Resolve(context.Background(), copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Password != "other" {
+		t.Fatalf("copy resolved to password %q, want %q", resolved.Password, "other")
+	}
+}
+
+func TestSaveCopyCarriesPasswordCommand(t *testing.T) {
+	s := newTestStore(t)
+	original := types.Connection{
+		Name:            "prod",
+		Type:            types.PostgreSQL,
+		PasswordStorage: types.PasswordCommand,
+		PasswordCommand: "pass show prod",
+	}
+	if err := s.Save(original); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveCopy("prod", types.Connection{Name: "copy", Type: types.PostgreSQL, PasswordStorage: types.PasswordCommand}); err != nil {
+		t.Fatal(err)
+	}
+
+	copied, err := s.Get("copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied.PasswordCommand != original.PasswordCommand {
+		t.Fatalf("copy has password command %q, want %q", copied.PasswordCommand, original.PasswordCommand)
+	}
+}
+
+func TestSaveCopyNeedsUnlockedVault(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+	if err := s.Unlock("master"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(types.Connection{Name: "prod", Type: types.MySQL, Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	locked := New(dir)
+	err := locked.SaveCopy("prod", types.Connection{Name: "copy", Type: types.MySQL})
+	if !errors.Is(err, ErrVaultLocked) {
+		t.Fatalf("SaveCopy with a locked vault returned %v, want ErrVaultLocked", err)
+	}
+	if _, err := locked.Get("copy"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("refused copy was saved anyway: %v", err)
+	}
+}
+
+func TestDecodeConnectionsMigrates(t *testing.T) {
+	raw := []json.RawMessage{json.RawMessage(`{"name":"prod","type":"postgresql","enable_ssl":true}`)}
+	connections, err := DecodeConnections(1, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(connections) != 1 || connections[0].TLS.Mode != types.TLSRequire {
+		t.Fatalf("DecodeConnections = %+v, want prod with TLS required", connections)
+	}
+
+	if _, err := DecodeConnections(FormatVersion+1, raw); !errors.Is(err, ErrNewerFormat) {
+		t.Fatalf("DecodeConnections of a newer version returned %v, want ErrNewerFormat", err)
+	}
+}
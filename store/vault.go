@@ -0,0 +1,268 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// VaultFileName is the name of the credential vault inside the config directory
+const VaultFileName = "vault.json"
+
+// vaultVersion is the current version of the on-disk vault format
+const vaultVersion = 1
+
+var (
+	ErrVaultLocked         = errors.New("the credential vault is locked")
+	ErrWrongMasterPassword = errors.New("wrong master password")
+	ErrEmptyMasterPassword = errors.New("master password is required")
+)
+
+// Secret holds the passwords the vault keeps for one connection
+type Secret struct {
+	Password    string `json:"password,omitempty"`
+	SSHPassword string `json:"ssh_password,omitempty"`
+}
+
+// kdfParams records how the vault key is derived from the master password,
+// so the cost can be raised later without breaking existing vaults
+type kdfParams struct {
+	Name    string `json:"name"`
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// Argon2id settings for new vaults, following the RFC 9106 recommendation
+// for memory-constrained environments
+var defaultKDF = kdfParams{Name: "argon2id", Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// vaultFile is the on-disk representation of the vault. Data is the JSON
+// map of secrets by connection name, sealed with XChaCha20-Poly1305.
+type vaultFile struct {
+	Version int       `json:"version"`
+	KDF     kdfParams `json:"kdf"`
+	Nonce   []byte    `json:"nonce"`
+	Data    []byte    `json:"data"`
+}
+
+// Vault keeps connection passwords encrypted under a key derived from a
+// master password. The key is held in memory once unlocked; the file is
+// re-read under a lock on every operation, like the connections file.
+type Vault struct {
+	path string
+	mu   sync.Mutex
+	kdf  kdfParams
+	key  []byte
+}
+
+// NewVault returns a vault backed by the vault file inside dir
+func NewVault(dir string) *Vault {
+	return &Vault{path: filepath.Join(dir, VaultFileName)}
+}
+
+// Exists reports whether a vault has been created yet
+func (v *Vault) Exists() bool {
+	_, err := os.Stat(v.path)
+	return err == nil
+}
+
+// Unlocked reports whether the master password has been entered
+func (v *Vault) Unlocked() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.key != nil
+}
+
+// Unlock derives the vault key from the master password and checks it
+// against the vault. When there is no vault yet, one is created with it.
+func (v *Vault) Unlock(master string) error {
+	if master == "" {
+		return ErrEmptyMasterPassword
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(v.path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := lockFile(v.path+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := v.readFile()
+	if errors.Is(err, os.ErrNotExist) {
+		kdf := defaultKDF
+		kdf.Salt = make([]byte, 16)
+		if _, err := rand.Read(kdf.Salt); err != nil {
+			return err
+		}
+		v.kdf, v.key = kdf, deriveKey(master, kdf)
+		return v.write(map[string]Secret{})
+	}
+	if err != nil {
+		return err
+	}
+
+	key := deriveKey(master, file.KDF)
+	if _, err := open(key, file); err != nil {
+		return ErrWrongMasterPassword
+	}
+	v.kdf, v.key = file.KDF, key
+	return nil
+}
+
+// Lock forgets the vault key
+func (v *Vault) Lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	clear(v.key)
+	v.key = nil
+}
+
+// Get returns the secrets kept for the named connection
+func (v *Vault) Get(name string) (Secret, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return Secret{}, ErrVaultLocked
+	}
+	unlock, err := lockFile(v.path+".lock", false)
+	if err != nil {
+		return Secret{}, err
+	}
+	defer unlock()
+
+	secrets, err := v.read()
+	if err != nil {
+		return Secret{}, err
+	}
+	return secrets[name], nil
+}
+
+// update runs fn against the decrypted secrets and writes them back,
+// holding an exclusive lock for the whole cycle
+func (v *Vault) update(fn func(secrets map[string]Secret)) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return ErrVaultLocked
+	}
+	unlock, err := lockFile(v.path+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	secrets, err := v.read()
+	if errors.Is(err, os.ErrNotExist) {
+		secrets, err = map[string]Secret{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	fn(secrets)
+	return v.write(secrets)
+}
+
+func (v *Vault) readFile() (vaultFile, error) {
+	data, err := os.ReadFile(v.path)
+	if err != nil {
+		return vaultFile{}, err
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return vaultFile{}, fmt.Errorf("parsing %s: %w", v.path, err)
+	}
+	if file.Version > vaultVersion {
+		return vaultFile{}, fmt.Errorf("%s was written by a newer version of nectar", v.path)
+	}
+	if file.KDF.Name != "argon2id" {
+		return vaultFile{}, fmt.Errorf("%s: unsupported key derivation %q", v.path, file.KDF.Name)
+	}
+	return file, nil
+}
+
+// read decrypts the vault with the key already held
+func (v *Vault) read() (map[string]Secret, error) {
+	file, err := v.readFile()
+	if err != nil {
+		return nil, err
+	}
+	// Another process replaced the vault with a new master password
+	if string(file.KDF.Salt) != string(v.kdf.Salt) {
+		return nil, ErrVaultLocked
+	}
+
+	plaintext, err := open(v.key, file)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", v.path, err)
+	}
+	secrets := map[string]Secret{}
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", v.path, err)
+	}
+	return secrets, nil
+}
+
+// write seals secrets under a fresh nonce and replaces the vault file
+func (v *Vault) write(secrets map[string]Secret) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	aead, err := chacha20poly1305.NewX(v.key)
+	if err != nil {
+		return err
+	}
+	file := vaultFile{Version: vaultVersion, KDF: v.kdf, Nonce: make([]byte, aead.NonceSize())}
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = aead.Seal(nil, file.Nonce, plaintext, additionalData(file))
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(v.path, append(data, '\n'))
+}
+
+func deriveKey(master string, kdf kdfParams) []byte {
+	return argon2.IDKey([]byte(master), kdf.Salt, kdf.Time, kdf.Memory, kdf.Threads, chacha20poly1305.KeySize)
+}
+
+// open decrypts the vault contents, failing if the key is wrong or the file
+// has been tampered with
+func open(key []byte, file vaultFile) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, errors.New("malformed nonce")
+	}
+	return aead.Open(nil, file.Nonce, file.Data, additionalData(file))
+}
+
+// Bind the ciphertext to the format version and key derivation settings so
+// neither can be altered without detection
+func additionalData(file vaultFile) []byte {
+	kdf, _ := json.Marshal(file.KDF)
+	return append([]byte(fmt.Sprintf("nectar vault v%d:", file.Version)), kdf...)
+}
@@ -0,0 +1,90 @@
+package styles
+
+import (
+	"nectar/syntax"
+
+	catppuccin "github.com/catppuccin/go"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// SQL highlighting, one style per kind of token
+var (
+	SQLText = BaseStyle.
+		Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Text().Hex,
+			Dark:  catppuccin.Mocha.Text().Hex,
+		})
+	SQLKeyword = BaseStyle.
+			Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Mauve().Hex,
+			Dark:  catppuccin.Mocha.Mauve().Hex,
+		}).
+		Bold(true)
+	SQLQuotedIdentifier = BaseStyle.
+				Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Yellow().Hex,
+			Dark:  catppuccin.Mocha.Yellow().Hex,
+		})
+	SQLString = BaseStyle.
+			Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Green().Hex,
+			Dark:  catppuccin.Mocha.Green().Hex,
+		})
+	SQLDollarString = BaseStyle.
+			Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Teal().Hex,
+			Dark:  catppuccin.Mocha.Teal().Hex,
+		})
+	SQLNumber = BaseStyle.
+			Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Peach().Hex,
+			Dark:  catppuccin.Mocha.Peach().Hex,
+		})
+	SQLComment = BaseStyle.
+			Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay1().Hex,
+			Dark:  catppuccin.Mocha.Overlay1().Hex,
+		}).
+		Italic(true)
+	SQLParameter = BaseStyle.
+			Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Pink().Hex,
+			Dark:  catppuccin.Mocha.Pink().Hex,
+		})
+	SQLOperator = BaseStyle.
+			Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Sky().Hex,
+			Dark:  catppuccin.Mocha.Sky().Hex,
+		})
+	SQLPunctuation = BaseStyle.
+			Foreground(lipgloss.AdaptiveColor{
+			Light: catppuccin.Latte.Overlay2().Hex,
+			Dark:  catppuccin.Mocha.Overlay2().Hex,
+		})
+)
+
+// SQLToken returns the highlighting for a kind of token
+func SQLToken(kind syntax.TokenKind) lipgloss.Style {
+	switch kind {
+	case syntax.TokenKeyword:
+		return SQLKeyword
+	case syntax.TokenQuotedIdentifier:
+		return SQLQuotedIdentifier
+	case syntax.TokenString:
+		return SQLString
+	case syntax.TokenDollarString:
+		return SQLDollarString
+	case syntax.TokenNumber:
+		return SQLNumber
+	case syntax.TokenComment:
+		return SQLComment
+	case syntax.TokenParameter:
+		return SQLParameter
+	case syntax.TokenOperator:
+		return SQLOperator
+	case syntax.TokenPunctuation:
+		return SQLPunctuation
+	default:
+		return SQLText
+	}
+}
@@ -0,0 +1,240 @@
+package syntax
+
+import (
+	"nectar/types"
+	"strings"
+	"unicode"
+)
+
+// ContextKind is what may be typed at a point in a script
+type ContextKind int
+
+const (
+	// Inside a string or comment, where nothing is completed
+	ContextNone ContextKind = iota
+	// Keywords, functions and columns of the statement's tables
+	ContextGeneral
+	// A table name, after FROM, JOIN, UPDATE, INTO and the like
+	ContextTable
+	// Whatever belongs to the name before a dot: columns of a table or
+	// alias, tables of a schema
+	ContextMember
+)
+
+// Keywords followed by a table name
+var tableKeywords = map[string]bool{
+	"FROM":     true,
+	"JOIN":     true,
+	"UPDATE":   true,
+	"INTO":     true,
+	"TABLE":    true,
+	"TRUNCATE": true,
+	"DESCRIBE": true,
+}
+
+// TableRef is a table named in a statement and the alias it goes by, if any
+type TableRef struct {
+	Schema string
+	Name   string
+	Alias  string
+}
+
+// Completion describes the point where completion was asked for
+type Completion struct {
+	Kind ContextKind
+	// Prefix is the part of the word before the cursor that a completion
+	// replaces
+	Prefix string
+	// Qualifier is the name before the dot, for ContextMember
+	Qualifier string
+	// Tables are those the statement under the cursor refers to
+	Tables []TableRef
+}
+
+// CompletionAt works out what could be typed at the rune offset, looking
+// only at the statement it falls in
+func CompletionAt(script string, offset int, dialect types.ConnectionType) Completion {
+	tokens := Tokenize(script, dialect)
+
+	// Narrow down to the statement around the offset
+	from, to := 0, len(tokens)
+	for i, token := range tokens {
+		if token.Kind != TokenPunctuation || token.Text != ";" {
+			continue
+		}
+		if token.End <= offset {
+			from = i + 1
+		} else {
+			to = i
+			break
+		}
+	}
+	tokens = tokens[from:to]
+
+	completion := Completion{Kind: ContextGeneral}
+	prefixStart := offset
+	for _, token := range tokens {
+		if token.Start >= offset || token.End < offset {
+			continue
+		}
+		switch token.Kind {
+		case TokenComment:
+			// Line comments run up to, not including, the line break
+			if token.End > offset || token.Unterminated || !strings.HasPrefix(token.Text, "/*") {
+				return Completion{Kind: ContextNone}
+			}
+		case TokenString, TokenDollarString, TokenQuotedIdentifier:
+			if token.End > offset || token.Unterminated {
+				return Completion{Kind: ContextNone}
+			}
+		case TokenIdentifier, TokenKeyword:
+			prefixStart = token.Start
+			completion.Prefix = string([]rune(token.Text)[:offset-token.Start])
+		}
+	}
+
+	// Everything before the word being typed, minus whitespace and comments
+	var before, all []Token
+	for _, token := range tokens {
+		if token.Kind == TokenSpace || token.Kind == TokenComment {
+			continue
+		}
+		all = append(all, token)
+		if token.End <= prefixStart {
+			before = append(before, token)
+		}
+	}
+	completion.Tables = tableRefs(all)
+
+	last := len(before) - 1
+	switch {
+	case last < 0:
+	case isPunctuation(before[last], "."):
+		if last > 0 && isName(before[last-1]) {
+			completion.Kind = ContextMember
+			completion.Qualifier = Unquote(before[last-1].Text)
+		}
+	case before[last].Kind == TokenKeyword && tableKeywords[strings.ToUpper(before[last].Text)]:
+		completion.Kind = ContextTable
+	case isPunctuation(before[last], ","):
+		// Another table in a FROM list, or just another column
+		for i := last - 1; i >= 0; i-- {
+			token := before[i]
+			if token.Kind == TokenKeyword && !strings.EqualFold(token.Text, "AS") {
+				if strings.EqualFold(token.Text, "FROM") {
+					completion.Kind = ContextTable
+				}
+				break
+			}
+			if !isName(token) && !isPunctuation(token, ".") && !isPunctuation(token, ",") {
+				break
+			}
+		}
+	}
+	return completion
+}
+
+// tableRefs collects the tables a statement names after FROM, JOIN,
+// UPDATE and INTO, and in FROM lists, with their aliases
+func tableRefs(tokens []Token) []TableRef {
+	var refs []TableRef
+	inFrom := false
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case token.Kind == TokenKeyword && tableKeywords[strings.ToUpper(token.Text)]:
+			inFrom = strings.EqualFold(token.Text, "FROM")
+		case token.Kind == TokenKeyword:
+			inFrom = false
+			continue
+		case inFrom && isPunctuation(token, ","):
+		default:
+			continue
+		}
+
+		ref, n := tableRef(tokens[i+1:])
+		if n > 0 {
+			refs = append(refs, ref)
+			i += n
+		}
+	}
+	return refs
+}
+
+// tableRef reads [schema.]table [[AS] alias] off the front of tokens and
+// returns how many tokens it used, none when they don't start with a name
+func tableRef(tokens []Token) (TableRef, int) {
+	if len(tokens) == 0 || !isName(tokens[0]) {
+		return TableRef{}, 0
+	}
+	ref, n := TableRef{Name: Unquote(tokens[0].Text)}, 1
+	if len(tokens) > 2 && isPunctuation(tokens[1], ".") && isName(tokens[2]) {
+		ref.Schema, ref.Name = ref.Name, Unquote(tokens[2].Text)
+		n = 3
+	}
+
+	if n < len(tokens) && tokens[n].Kind == TokenKeyword && strings.EqualFold(tokens[n].Text, "AS") {
+		n++
+	}
+	if n < len(tokens) && isName(tokens[n]) {
+		ref.Alias = Unquote(tokens[n].Text)
+		n++
+	}
+	return ref, n
+}
+
+func isName(token Token) bool {
+	return token.Kind == TokenIdentifier || token.Kind == TokenQuotedIdentifier
+}
+
+func isPunctuation(token Token, text string) bool {
+	return token.Kind == TokenPunctuation && token.Text == text
+}
+
+// Unquote strips the quotes from a quoted identifier, undoubling any
+// escaped quotes inside it
+func Unquote(name string) string {
+	if len(name) < 2 {
+		return name
+	}
+	switch open, end := name[0], name[len(name)-1]; {
+	case open == '"' && end == '"', open == '`' && end == '`':
+		quote := string(open)
+		return strings.ReplaceAll(name[1:len(name)-1], quote+quote, quote)
+	case open == '[' && end == ']':
+		return strings.ReplaceAll(name[1:len(name)-1], "]]", "]")
+	}
+	return name
+}
+
+// Quote returns name the way a person would type it: as is when it is a
+// plain word, quoted otherwise. PostgreSQL folds unquoted names to lower
+// case, so mixed case names are quoted there too. It goes by the
+// highlighter's keywords rather than each dialect's reserved words, so it
+// is only for text put in front of the user, such as completions; SQL that
+// nectar runs itself uses QuoteIdentifier.
+func Quote(name string, dialect types.ConnectionType) string {
+	plain := name != "" && !IsKeyword(name, dialect)
+	for i, r := range name {
+		if !isWordStart(r) && (i == 0 || !unicode.IsDigit(r) && r != '$') {
+			plain = false
+		}
+	}
+	if dialect == types.PostgreSQL && strings.ToLower(name) != name {
+		plain = false
+	}
+	if plain {
+		return name
+	}
+	return QuoteIdentifier(name, dialect)
+}
+
+// QuoteIdentifier always quotes name as an identifier: in backticks for
+// MySQL and double quotes otherwise, doubling any inside it
+func QuoteIdentifier(name string, dialect types.ConnectionType) string {
+	quote := `"`
+	if dialect == types.MySQL {
+		quote = "`"
+	}
+	return quote + strings.ReplaceAll(name, quote, quote+quote) + quote
+}
@@ -0,0 +1,46 @@
+package syntax
+
+import (
+	"nectar/types"
+	"testing"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect types.ConnectionType
+		want    string
+	}{
+		{"user", types.PostgreSQL, `"user"`},
+		{"range", types.MySQL, "`range`"},
+		{"orders", types.SQLite, `"orders"`},
+		{`say "hi"`, types.PostgreSQL, `"say ""hi"""`},
+		{"back`tick", types.MySQL, "`back``tick`"},
+		{"", types.SQLite, `""`},
+	}
+	for _, test := range tests {
+		if got := QuoteIdentifier(test.name, test.dialect); got != test.want {
+			t.Errorf("QuoteIdentifier(%q, %v) = %s, want %s", test.name, test.dialect, got, test.want)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect types.ConnectionType
+		want    string
+	}{
+		{"orders", types.PostgreSQL, "orders"},
+		{"Orders", types.PostgreSQL, `"Orders"`},
+		{"Orders", types.MySQL, "Orders"},
+		{"select", types.MySQL, "`select`"},
+		{"order items", types.SQLite, `"order items"`},
+		{"2fa", types.PostgreSQL, `"2fa"`},
+	}
+	for _, test := range tests {
+		if got := Quote(test.name, test.dialect); got != test.want {
+			t.Errorf("Quote(%q, %v) = %s, want %s", test.name, test.dialect, got, test.want)
+		}
+	}
+}
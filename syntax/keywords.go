@@ -0,0 +1,60 @@
+package syntax
+
+import (
+	"nectar/types"
+	"slices"
+	"strings"
+)
+
+// Keywords every supported engine shares
+var commonKeywords = []string{
+	"ADD", "ALL", "ALTER", "AND", "ANY", "AS", "ASC", "BEGIN", "BETWEEN",
+	"BY", "CASCADE", "CASE", "CAST", "CHECK", "COLLATE", "COLUMN", "COMMIT",
+	"CONSTRAINT", "CREATE", "CROSS", "CURRENT_DATE", "CURRENT_TIME",
+	"CURRENT_TIMESTAMP", "DATABASE", "DEFAULT", "DELETE", "DESC", "DISTINCT",
+	"DROP", "ELSE", "END", "ESCAPE", "EXCEPT", "EXISTS", "EXPLAIN", "FALSE",
+	"FOREIGN", "FROM", "FULL", "GROUP", "HAVING", "IF", "IN", "INDEX",
+	"INNER", "INSERT", "INTERSECT", "INTO", "IS", "JOIN", "KEY", "LEFT",
+	"LIKE", "LIMIT", "NATURAL", "NOT", "NULL", "OFFSET", "ON", "OR", "ORDER",
+	"OUTER", "OVER", "PARTITION", "PRIMARY", "RECURSIVE", "REFERENCES",
+	"RENAME", "REPLACE", "RIGHT", "ROLLBACK", "SAVEPOINT", "SELECT", "SET",
+	"TABLE", "TEMPORARY", "THEN", "TO", "TRANSACTION", "TRIGGER", "TRUE",
+	"UNION", "UNIQUE", "UPDATE", "USING", "VALUES", "VIEW", "WHEN", "WHERE",
+	"WINDOW", "WITH",
+}
+
+// Keywords particular to one engine
+var dialectKeywords = map[types.ConnectionType][]string{
+	types.PostgreSQL: {
+		"ANALYZE", "ARRAY", "CONFLICT", "COPY", "DO", "EXTENSION", "FETCH",
+		"FILTER", "FUNCTION", "GRANT", "ILIKE", "LANGUAGE", "LATERAL",
+		"MATERIALIZED", "NOTHING", "NULLS", "ONLY", "RETURNING", "RETURNS",
+		"REVOKE", "ROLE", "SCHEMA", "SEQUENCE", "SIMILAR", "TRUNCATE", "TYPE",
+		"VACUUM",
+	},
+	types.MySQL: {
+		"AUTO_INCREMENT", "CHANGE", "CHARSET", "DATABASES", "DESCRIBE",
+		"DUPLICATE", "ENGINE", "FUNCTION", "GRANT", "IGNORE", "INTERVAL",
+		"KILL", "MODIFY", "PROCEDURE", "REGEXP", "REVOKE", "SCHEMA", "SHOW",
+		"STRAIGHT_JOIN", "TABLES", "TRUNCATE", "UNSIGNED", "USE", "ZEROFILL",
+	},
+	types.SQLite: {
+		"ABORT", "ATTACH", "AUTOINCREMENT", "CONFLICT", "DETACH", "GLOB",
+		"IMMEDIATE", "INDEXED", "NOTHING", "PRAGMA", "RAISE", "REINDEX",
+		"RETURNING", "ROWID", "STRICT", "VACUUM", "VIRTUAL", "WITHOUT",
+	},
+}
+
+// Keywords returns the keywords of a dialect in alphabetical order
+func Keywords(dialect types.ConnectionType) []string {
+	keywords := slices.Concat(commonKeywords, dialectKeywords[dialect])
+	slices.Sort(keywords)
+	return slices.Compact(keywords)
+}
+
+// IsKeyword reports whether word is a keyword of the dialect, in any case
+func IsKeyword(word string, dialect types.ConnectionType) bool {
+	word = strings.ToUpper(word)
+	_, found := slices.BinarySearch(commonKeywords, word)
+	return found || slices.Contains(dialectKeywords[dialect], word)
+}
@@ -0,0 +1,220 @@
+package syntax
+
+import (
+	"nectar/types"
+	"strings"
+	"unicode"
+)
+
+// Statements that produce a result set, by their first keyword
+var rowKeywords = map[string]bool{
+	"SELECT":   true,
+	"WITH":     true,
+	"VALUES":   true,
+	"TABLE":    true,
+	"SHOW":     true,
+	"EXPLAIN":  true,
+	"DESCRIBE": true,
+	"DESC":     true,
+	"PRAGMA":   true,
+}
+
+// Statements that only read, by their first keyword. WITH is left out as
+// its statements may write.
+var readKeywords = map[string]bool{
+	"SELECT":   true,
+	"VALUES":   true,
+	"TABLE":    true,
+	"SHOW":     true,
+	"EXPLAIN":  true,
+	"DESCRIBE": true,
+	"DESC":     true,
+}
+
+// Words that make a reading statement, or a WITH query, change something:
+// a data-modifying CTE, SELECT INTO, or EXPLAIN ANALYZE running what it
+// explains
+var writeKeywords = map[string]bool{
+	"INSERT":    true,
+	"UPDATE":    true,
+	"DELETE":    true,
+	"MERGE":     true,
+	"RETURNING": true,
+	"INTO":      true,
+	"ANALYZE":   true,
+}
+
+// ReturnsRows guesses whether a statement produces a result set, so it can
+// be run as a query rather than an exec. Data-modifying statements count
+// when they have a RETURNING clause.
+func ReturnsRows(statement string) bool {
+	words := strings.FieldsFunc(stripComments(statement), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '_'
+	})
+	if len(words) == 0 {
+		return false
+	}
+	if rowKeywords[strings.ToUpper(words[0])] {
+		return true
+	}
+	for _, word := range words[1:] {
+		if strings.EqualFold(word, "RETURNING") {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadOnly reports whether a statement reads rather than writes, going by
+// its keywords. It errs on the side of false: any word that could be a write
+// rules the statement out, even inside a string. Functions it calls may
+// still write, which PlainSelect rules out.
+func ReadOnly(statement string) bool {
+	words := strings.FieldsFunc(stripComments(statement), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '_'
+	})
+	if len(words) == 0 {
+		return false
+	}
+	first := strings.ToUpper(words[0])
+	if !readKeywords[first] && first != "WITH" {
+		return false
+	}
+	for _, word := range words[1:] {
+		if writeKeywords[strings.ToUpper(word)] {
+			return false
+		}
+	}
+	return true
+}
+
+// Words a parenthesis may follow without making it a function call
+var groupingWords = map[string]bool{
+	"SELECT": true, "FROM": true, "JOIN": true, "ON": true, "USING": true,
+	"WHERE": true, "AND": true, "OR": true, "NOT": true, "IN": true,
+	"EXISTS": true, "ANY": true, "ALL": true, "SOME": true, "AS": true,
+	"UNION": true, "EXCEPT": true, "INTERSECT": true, "LATERAL": true,
+	"OVER": true, "FILTER": true, "DISTINCT": true, "THEN": true,
+	"ELSE": true, "WHEN": true,
+}
+
+// Built-in functions that only compute a value: aggregates, window
+// functions, casts and the common scalar functions. Anything else called
+// may write, as nextval() does, so it is not taken to be safe.
+var readFunctions = map[string]bool{
+	// Aggregates
+	"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true,
+	"STRING_AGG": true, "GROUP_CONCAT": true, "ARRAY_AGG": true,
+	"JSON_AGG": true, "JSONB_AGG": true, "JSON_ARRAYAGG": true,
+	"JSON_OBJECTAGG": true, "BOOL_AND": true, "BOOL_OR": true,
+	"STDDEV": true, "VARIANCE": true, "TOTAL": true,
+	// Window functions
+	"ROW_NUMBER": true, "RANK": true, "DENSE_RANK": true, "NTILE": true,
+	"LAG": true, "LEAD": true, "FIRST_VALUE": true, "LAST_VALUE": true,
+	// Casts and conditionals
+	"CAST": true, "CONVERT": true, "COALESCE": true, "NULLIF": true,
+	"IFNULL": true, "IIF": true, "GREATEST": true, "LEAST": true,
+	// Strings
+	"LOWER": true, "UPPER": true, "LENGTH": true, "CHAR_LENGTH": true,
+	"SUBSTRING": true, "SUBSTR": true, "TRIM": true, "LTRIM": true,
+	"RTRIM": true, "CONCAT": true, "CONCAT_WS": true, "REPLACE": true,
+	"LEFT": true, "RIGHT": true, "POSITION": true,
+	// Numbers
+	"ABS": true, "ROUND": true, "FLOOR": true, "CEIL": true, "CEILING": true,
+	"MOD": true,
+	// Dates
+	"NOW": true, "EXTRACT": true, "DATE": true, "DATE_TRUNC": true,
+	"DATE_FORMAT": true, "TO_CHAR": true, "STRFTIME": true,
+}
+
+// Words that make a SELECT lock or write what it reads: FOR UPDATE, FOR
+// SHARE and MySQL's LOCK IN SHARE MODE. Among a function's arguments FOR
+// is part of an expression instead, as in SUBSTRING(name FROM 1 FOR 3).
+var lockingWords = map[string]bool{
+	"FOR":  true,
+	"LOCK": true,
+}
+
+// PlainSelect reports whether a statement is a SELECT ... FROM that takes
+// no locks and calls no functions but the read-only built-ins, on top of
+// what ReadOnly checks. Such a statement can be run again without side
+// effects, which a function like nextval() or one the user wrote could
+// have. It errs on the side of false: a function it doesn't know, even a
+// harmless one, rules the statement out.
+func PlainSelect(statement string, dialect types.ConnectionType) bool {
+	if !ReadOnly(statement) {
+		return false
+	}
+
+	var tokens []Token
+	for _, token := range Tokenize(statement, dialect) {
+		switch {
+		case token.Unterminated:
+			return false
+		case token.Kind != TokenSpace && token.Kind != TokenComment:
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 || !strings.EqualFold(tokens[0].Text, "SELECT") {
+		return false
+	}
+
+	from := false
+	// arguments records, for each parenthesis open, whether it holds a
+	// function's arguments rather than a subquery or an expression
+	var arguments []bool
+	for i, token := range tokens {
+		word := strings.ToUpper(token.Text)
+		called := i+1 < len(tokens) && tokens[i+1].Text == "("
+		switch token.Kind {
+		case TokenKeyword, TokenIdentifier:
+			if lockingWords[word] && (len(arguments) == 0 || !arguments[len(arguments)-1]) {
+				return false
+			}
+			from = from || word == "FROM"
+			// A schema-qualified name is never the built-in
+			qualified := i > 0 && tokens[i-1].Text == "."
+			if called && !groupingWords[word] && (qualified || !readFunctions[word]) {
+				return false
+			}
+		case TokenQuotedIdentifier:
+			if called {
+				return false
+			}
+		case TokenPunctuation:
+			switch token.Text {
+			case "(":
+				function := i > 0 && readFunctions[strings.ToUpper(tokens[i-1].Text)]
+				arguments = append(arguments, function)
+			case ")":
+				if len(arguments) > 0 {
+					arguments = arguments[:len(arguments)-1]
+				}
+			}
+		}
+	}
+	return from
+}
+
+// stripComments drops leading line and block comments
+func stripComments(statement string) string {
+	for {
+		statement = strings.TrimLeftFunc(statement, unicode.IsSpace)
+		switch {
+		case strings.HasPrefix(statement, "--"), strings.HasPrefix(statement, "#"):
+			_, rest, found := strings.Cut(statement, "\n")
+			if !found {
+				return ""
+			}
+			statement = rest
+		case strings.HasPrefix(statement, "/*"):
+			_, rest, found := strings.Cut(statement, "*/")
+			if !found {
+				return ""
+			}
+			statement = rest
+		default:
+			return statement
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package syntax
+
+import (
+	"nectar/types"
+	"testing"
+)
+
+func TestPlainSelect(t *testing.T) {
+	tests := []struct {
+		statement string
+		dialect   types.ConnectionType
+		want      bool
+	}{
+		{"SELECT * FROM orders", types.PostgreSQL, true},
+		{"select id, name from users where id in (select user_id from orders)", types.PostgreSQL, true},
+		{"SELECT count(*) FROM orders", types.PostgreSQL, true},
+		{"SELECT status, COUNT(*), max(total) FROM orders GROUP BY status", types.MySQL, true},
+		{"SELECT coalesce(name, '') , lower(email) FROM users", types.SQLite, true},
+		{"SELECT CAST(total AS text) FROM orders", types.PostgreSQL, true},
+		{"SELECT substring(name FROM 1 FOR 3) FROM users", types.PostgreSQL, true},
+		{"SELECT * FROM orders WHERE note = 'nextval('", types.PostgreSQL, true},
+		{"SELECT * FROM orders FOR UPDATE", types.PostgreSQL, false},
+		{"SELECT * FROM orders FOR SHARE", types.PostgreSQL, false},
+		{"SELECT * FROM orders LOCK IN SHARE MODE", types.MySQL, false},
+		{"SELECT * FROM (SELECT * FROM orders FOR UPDATE) o", types.PostgreSQL, false},
+		{"SELECT nextval('order_ids')", types.PostgreSQL, false},
+		{"SELECT nextval('order_ids') FROM orders", types.PostgreSQL, false},
+		{"SELECT audit_read(id) FROM orders", types.PostgreSQL, false},
+		{"SELECT public.count(id) FROM orders", types.PostgreSQL, false},
+		{`SELECT "count"(id) FROM orders`, types.PostgreSQL, false},
+		{"SELECT 1", types.PostgreSQL, false},
+		{"SELECT * INTO archive FROM orders", types.PostgreSQL, false},
+		{"WITH recent AS (SELECT * FROM orders) SELECT * FROM recent", types.PostgreSQL, false},
+		{"DELETE FROM orders", types.PostgreSQL, false},
+		{"SELECT * FROM orders WHERE note = 'open", types.PostgreSQL, false},
+	}
+	for _, test := range tests {
+		if got := PlainSelect(test.statement, test.dialect); got != test.want {
+			t.Errorf("PlainSelect(%q) = %v, want %v", test.statement, got, test.want)
+		}
+	}
+}
+
+func TestReturnsRows(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      bool
+	}{
+		{"SELECT 1", true},
+		{"  with x as (select 1) select * from x", true},
+		{"VALUES (1), (2)", true},
+		{"SHOW TABLES", true},
+		{"EXPLAIN SELECT 1", true},
+		{"PRAGMA table_info(users)", true},
+		{"-- latest first\n/* all of them */ SELECT * FROM orders", true},
+		{"INSERT INTO users (name) VALUES ('a') RETURNING id", true},
+		{"INSERT INTO users (name) VALUES ('a')", false},
+		{"UPDATE users SET name = 'b'", false},
+		{"CREATE TABLE t (id int)", false},
+		{"-- only a comment", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := ReturnsRows(test.statement); got != test.want {
+			t.Errorf("ReturnsRows(%q) = %v, want %v", test.statement, got, test.want)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      bool
+	}{
+		{"SELECT * FROM orders", true},
+		{"/* report */ select count(*) from orders", true},
+		{"WITH recent AS (SELECT * FROM orders) SELECT * FROM recent", true},
+		{"EXPLAIN SELECT * FROM orders", true},
+		{"SHOW search_path", true},
+		{"EXPLAIN ANALYZE DELETE FROM orders", false},
+		{"WITH gone AS (DELETE FROM orders RETURNING *) SELECT * FROM gone", false},
+		{"SELECT * INTO archive FROM orders", false},
+		// Erring on the side of false, even inside a string
+		{"SELECT * FROM orders WHERE note = 'update'", false},
+		{"PRAGMA journal_mode = WAL", false},
+		{"UPDATE orders SET total = 0", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := ReadOnly(test.statement); got != test.want {
+			t.Errorf("ReadOnly(%q) = %v, want %v", test.statement, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package syntax understands just enough SQL to split, highlight and
+// complete it without a full parser.
+package syntax
+
+import (
+	"nectar/types"
+	"unicode"
+)
+
+// Statement is one statement of a script. Start and End are the rune
+// offsets of its trimmed text, End excluding any terminating semicolon.
+type Statement struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// Split breaks a script into statements at semicolons that aren't inside
+// strings, quoted identifiers, comments or dollar-quoted bodies, following
+// the quoting rules of the given dialect. Blank statements are dropped.
+func Split(script string, dialect types.ConnectionType) []Statement {
+	runes := []rune(script)
+	var statements []Statement
+
+	start := 0
+	emit := func(end int) {
+		from, to := start, end
+		for from < to && unicode.IsSpace(runes[from]) {
+			from++
+		}
+		for to > from && unicode.IsSpace(runes[to-1]) {
+			to--
+		}
+		if from < to {
+			statements = append(statements, Statement{Text: string(runes[from:to]), Start: from, End: to})
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '\'' || r == '"' || r == '`' && dialect != types.PostgreSQL:
+			i = skipQuoted(runes, i, r, dialect == types.MySQL)
+		case r == '-' && peek(runes, i+1) == '-', r == '#' && dialect == types.MySQL:
+			i = skipLine(runes, i)
+		case r == '/' && peek(runes, i+1) == '*':
+			i = skipBlockComment(runes, i)
+		case r == '$' && dialect == types.PostgreSQL:
+			if tag, ok := dollarTag(runes, i); ok {
+				i = skipDollarQuoted(runes, i, tag)
+			}
+		case r == ';':
+			emit(i)
+			start = i + 1
+		}
+	}
+	emit(len(runes))
+	return statements
+}
+
+// StatementAt returns the statement under the rune offset. Between two
+// statements it prefers the one ending on the same line, so a cursor left
+// just after a semicolon still picks the statement it terminates.
+func StatementAt(script string, offset int, dialect types.ConnectionType) (Statement, bool) {
+	statements := Split(script, dialect)
+	if len(statements) == 0 {
+		return Statement{}, false
+	}
+
+	runes := []rune(script)
+	for i, stmt := range statements {
+		if offset > stmt.End {
+			continue
+		}
+		if offset >= stmt.Start || i == 0 {
+			return stmt, true
+		}
+		if sameLine(runes, statements[i-1].End, offset) {
+			return statements[i-1], true
+		}
+		return stmt, true
+	}
+
+	// Past the end there is nothing after it to prefer
+	return statements[len(statements)-1], true
+}
+
+// sameLine reports whether no line break separates the two offsets
+func sameLine(runes []rune, from, to int) bool {
+	for _, r := range runes[min(from, len(runes)):min(to, len(runes))] {
+		if r == '\n' {
+			return false
+		}
+	}
+	return true
+}
+
+func peek(runes []rune, i int) rune {
+	if i < len(runes) {
+		return runes[i]
+	}
+	return 0
+}
+
+// skipQuoted returns the index of the closing quote. A doubled quote is an
+// escape everywhere; MySQL also escapes with a backslash.
+func skipQuoted(runes []rune, i int, quote rune, backslash bool) int {
+	for i++; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if backslash && quote != '`' {
+				i++
+			}
+		case quote:
+			if peek(runes, i+1) != quote {
+				return i
+			}
+			i++
+		}
+	}
+	return len(runes)
+}
+
+func skipLine(runes []rune, i int) int {
+	for ; i < len(runes); i++ {
+		if runes[i] == '\n' {
+			return i
+		}
+	}
+	return len(runes)
+}
+
+func skipBlockComment(runes []rune, i int) int {
+	for i += 2; i < len(runes); i++ {
+		if runes[i] == '*' && peek(runes, i+1) == '/' {
+			return i + 1
+		}
+	}
+	return len(runes)
+}
+
+// dollarTag recognises the opening of a PostgreSQL dollar-quoted string,
+// $$ or $tag$, and returns the full delimiter
+func dollarTag(runes []rune, i int) (string, bool) {
+	for j := i + 1; j < len(runes); j++ {
+		r := runes[j]
+		switch {
+		case r == '$':
+			return string(runes[i : j+1]), true
+		case r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && j > i+1:
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func skipDollarQuoted(runes []rune, i int, tag string) int {
+	delimiter := []rune(tag)
+	for j := i + len(delimiter); j+len(delimiter) <= len(runes); j++ {
+		if string(runes[j:j+len(delimiter)]) == tag {
+			return j + len(delimiter) - 1
+		}
+	}
+	return len(runes)
+}
@@ -0,0 +1,84 @@
+package syntax
+
+import (
+	"nectar/types"
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		dialect types.ConnectionType
+		want    []string
+	}{
+		{"plain", "SELECT 1; SELECT 2", types.PostgreSQL, []string{"SELECT 1", "SELECT 2"}},
+		{"blank statements", " ;\n;SELECT 1;; ", types.PostgreSQL, []string{"SELECT 1"}},
+		{"string", "SELECT ';'; SELECT 2", types.PostgreSQL, []string{"SELECT ';'", "SELECT 2"}},
+		{"doubled quote", "SELECT 'it''s;'; SELECT 2", types.SQLite, []string{"SELECT 'it''s;'", "SELECT 2"}},
+		{"quoted identifier", `SELECT "a;b" FROM t; SELECT 2`, types.PostgreSQL, []string{`SELECT "a;b" FROM t`, "SELECT 2"}},
+		{"backtick", "SELECT `a;b` FROM t; SELECT 2", types.MySQL, []string{"SELECT `a;b` FROM t", "SELECT 2"}},
+		{"mysql backslash", `SELECT 'a\';b'; SELECT 2`, types.MySQL, []string{`SELECT 'a\';b'`, "SELECT 2"}},
+		// PostgreSQL strings end at the first quote, backslash or not
+		{"postgres backslash", `SELECT 'a\'; SELECT 2`, types.PostgreSQL, []string{`SELECT 'a\'`, "SELECT 2"}},
+		{"line comment", "SELECT 1 -- one; two\n; SELECT 2", types.PostgreSQL, []string{"SELECT 1 -- one; two", "SELECT 2"}},
+		{"hash comment", "SELECT 1 # one; two\n; SELECT 2", types.MySQL, []string{"SELECT 1 # one; two", "SELECT 2"}},
+		{"block comment", "SELECT /* ; */ 1; SELECT 2", types.SQLite, []string{"SELECT /* ; */ 1", "SELECT 2"}},
+		{"dollar quoted", "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql; SELECT 2", types.PostgreSQL,
+			[]string{"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql", "SELECT 2"}},
+		{"tagged dollar quoted", "DO $body$ BEGIN PERFORM '$$;'; END $body$; SELECT 2", types.PostgreSQL,
+			[]string{"DO $body$ BEGIN PERFORM '$$;'; END $body$", "SELECT 2"}},
+		{"positional parameter", "SELECT $1; SELECT $2", types.PostgreSQL, []string{"SELECT $1", "SELECT $2"}},
+		{"unterminated string", "SELECT 'open; SELECT 2", types.PostgreSQL, []string{"SELECT 'open; SELECT 2"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, stmt := range Split(test.script, test.dialect) {
+			got = append(got, stmt.Text)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Split(%q) = %q, want %q", test.name, test.script, got, test.want)
+		}
+	}
+}
+
+func TestSplitOffsets(t *testing.T) {
+	script := "  SELECT 'é';\n\tSELECT 2  "
+	statements := Split(script, types.PostgreSQL)
+	if len(statements) != 2 {
+		t.Fatalf("Split returned %d statements, want 2", len(statements))
+	}
+	runes := []rune(script)
+	for _, stmt := range statements {
+		if got := string(runes[stmt.Start:stmt.End]); got != stmt.Text {
+			t.Errorf("runes %d:%d are %q, want %q", stmt.Start, stmt.End, got, stmt.Text)
+		}
+	}
+}
+
+func TestStatementAt(t *testing.T) {
+	script := "SELECT 1;\nSELECT 2;   \n\nSELECT 3"
+	tests := []struct {
+		offset int
+		want   string
+	}{
+		{0, "SELECT 1"},
+		{9, "SELECT 1"},
+		{10, "SELECT 2"},
+		// Just after a semicolon, the statement it ends
+		{20, "SELECT 2"},
+		// On a line of its own, the statement that follows
+		{23, "SELECT 3"},
+		{100, "SELECT 3"},
+	}
+	for _, test := range tests {
+		stmt, ok := StatementAt(script, test.offset, types.PostgreSQL)
+		if !ok || stmt.Text != test.want {
+			t.Errorf("StatementAt(%d) = %q, %v, want %q", test.offset, stmt.Text, ok, test.want)
+		}
+	}
+	if _, ok := StatementAt(" ; ", 0, types.PostgreSQL); ok {
+		t.Error("StatementAt found a statement in a blank script")
+	}
+}
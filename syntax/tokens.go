@@ -0,0 +1,187 @@
+package syntax
+
+import (
+	"nectar/types"
+	"strings"
+	"unicode"
+)
+
+// TokenKind is what a run of SQL text is, as far as highlighting goes
+type TokenKind int
+
+const (
+	TokenSpace TokenKind = iota
+	TokenKeyword
+	TokenIdentifier
+	// Identifiers in double quotes, or backticks and brackets outside
+	// PostgreSQL
+	TokenQuotedIdentifier
+	TokenString
+	// PostgreSQL $$ and $tag$ bodies
+	TokenDollarString
+	TokenNumber
+	TokenComment
+	// Placeholders and variables: $1, ?, :name, @var
+	TokenParameter
+	TokenOperator
+	// Parentheses, commas, dots and semicolons
+	TokenPunctuation
+)
+
+// Token is a run of the script. Start and End are rune offsets, End
+// exclusive. Unterminated is set on strings, quoted identifiers and
+// comments still open at the end of the script.
+type Token struct {
+	Kind         TokenKind
+	Text         string
+	Start        int
+	End          int
+	Unterminated bool
+}
+
+// Characters that chain into a single operator token
+const operatorRunes = "+-*/<>=~!%^&|:"
+
+// Tokenize breaks a script into tokens following the quoting rules of the
+// given dialect, the same ones Split obeys. Every rune belongs to exactly
+// one token, whitespace included.
+func Tokenize(script string, dialect types.ConnectionType) []Token {
+	runes := []rune(script)
+	var tokens []Token
+
+	for i := 0; i < len(runes); {
+		start, r := i, runes[i]
+		kind, unterminated := TokenOperator, false
+
+		// closing returns the offset after a delimiter found at end, noting
+		// when the search ran off the end of the script instead
+		closing := func(end int) int {
+			if end >= len(runes) {
+				unterminated = true
+				return len(runes)
+			}
+			return end + 1
+		}
+
+		switch {
+		case unicode.IsSpace(r):
+			kind = TokenSpace
+			for i < len(runes) && unicode.IsSpace(runes[i]) {
+				i++
+			}
+		case r == '-' && peek(runes, i+1) == '-', r == '#' && dialect == types.MySQL:
+			kind = TokenComment
+			i = skipLine(runes, i)
+		case r == '/' && peek(runes, i+1) == '*':
+			kind = TokenComment
+			i = closing(skipBlockComment(runes, i))
+		case r == '\'':
+			kind = TokenString
+			i = closing(skipQuoted(runes, i, r, dialect == types.MySQL))
+		case (r == 'E' || r == 'e') && peek(runes, i+1) == '\'' && dialect == types.PostgreSQL:
+			// Escape strings are the one place PostgreSQL honours backslashes
+			kind = TokenString
+			i = closing(skipQuoted(runes, i+1, '\'', true))
+		case r == '"':
+			kind = TokenQuotedIdentifier
+			if dialect == types.MySQL {
+				kind = TokenString
+			}
+			i = closing(skipQuoted(runes, i, r, dialect == types.MySQL))
+		case r == '`' && dialect != types.PostgreSQL:
+			kind = TokenQuotedIdentifier
+			i = closing(skipQuoted(runes, i, r, false))
+		case r == '[' && dialect == types.SQLite:
+			kind = TokenQuotedIdentifier
+			i = closing(skipQuoted(runes, i, ']', false))
+		case r == '$' && dialect == types.PostgreSQL:
+			if tag, ok := dollarTag(runes, i); ok {
+				kind = TokenDollarString
+				i = closing(skipDollarQuoted(runes, i, tag))
+				break
+			}
+			kind = TokenParameter
+			for i++; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+			}
+		case unicode.IsDigit(r), r == '.' && unicode.IsDigit(peek(runes, i+1)):
+			kind = TokenNumber
+			i = skipNumber(runes, i)
+		case isWordStart(r):
+			i = skipWord(runes, i)
+			kind = TokenIdentifier
+			if IsKeyword(string(runes[start:i]), dialect) {
+				kind = TokenKeyword
+			}
+		case r == '?':
+			kind = TokenParameter
+			i++
+		case r == ':' && isWordStart(peek(runes, i+1)), r == '@' && dialect != types.PostgreSQL:
+			kind = TokenParameter
+			for i++; i < len(runes) && runes[i] == '@'; i++ {
+			}
+			i = skipWord(runes, i)
+		case strings.ContainsRune("(),;.[]", r):
+			kind = TokenPunctuation
+			i++
+		default:
+			// Chain operator characters, stopping short of a comment
+			for i++; strings.ContainsRune(operatorRunes, r) && i < len(runes) && strings.ContainsRune(operatorRunes, runes[i]); i++ {
+				if next := peek(runes, i+1); runes[i] == '-' && next == '-' || runes[i] == '/' && next == '*' {
+					break
+				}
+			}
+		}
+
+		tokens = append(tokens, Token{
+			Kind:         kind,
+			Text:         string(runes[start:i]),
+			Start:        start,
+			End:          i,
+			Unterminated: unterminated,
+		})
+	}
+	return tokens
+}
+
+func isWordStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// skipWord returns the offset after an unquoted identifier or keyword
+func skipWord(runes []rune, i int) int {
+	for ; i < len(runes); i++ {
+		r := runes[i]
+		if !isWordStart(r) && !unicode.IsDigit(r) && r != '$' {
+			return i
+		}
+	}
+	return len(runes)
+}
+
+// skipNumber returns the offset after a numeric literal: integers,
+// decimals, exponents and 0x hex
+func skipNumber(runes []rune, i int) int {
+	if runes[i] == '0' && (peek(runes, i+1) == 'x' || peek(runes, i+1) == 'X') {
+		for i += 2; i < len(runes) && strings.ContainsRune("0123456789abcdefABCDEF", runes[i]); i++ {
+		}
+		return i
+	}
+
+	for ; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+	}
+	if peek(runes, i) == '.' {
+		for i++; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+		}
+	}
+	if r := peek(runes, i); r == 'e' || r == 'E' {
+		j := i + 1
+		if s := peek(runes, j); s == '+' || s == '-' {
+			j++
+		}
+		if unicode.IsDigit(peek(runes, j)) {
+			for i = j; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+			}
+		}
+	}
+	return i
+}
@@ -0,0 +1,109 @@
+package syntax
+
+import (
+	"nectar/types"
+	"strings"
+	"testing"
+)
+
+// kinds tokenizes a script, leaving out the whitespace
+func kinds(script string, dialect types.ConnectionType) ([]string, []TokenKind) {
+	var texts []string
+	var kinds []TokenKind
+	for _, token := range Tokenize(script, dialect) {
+		if token.Kind != TokenSpace {
+			texts = append(texts, token.Text)
+			kinds = append(kinds, token.Kind)
+		}
+	}
+	return texts, kinds
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		dialect types.ConnectionType
+		texts   []string
+		kinds   []TokenKind
+	}{
+		{"select", "SELECT id FROM users", types.PostgreSQL,
+			[]string{"SELECT", "id", "FROM", "users"},
+			[]TokenKind{TokenKeyword, TokenIdentifier, TokenKeyword, TokenIdentifier}},
+		{"strings", `'it''s' E'a\'b'`, types.PostgreSQL,
+			[]string{`'it''s'`, `E'a\'b'`},
+			[]TokenKind{TokenString, TokenString}},
+		{"double quotes", `"Order" "x"`, types.PostgreSQL,
+			[]string{`"Order"`, `"x"`},
+			[]TokenKind{TokenQuotedIdentifier, TokenQuotedIdentifier}},
+		{"mysql double quotes", "\"text\" `name`", types.MySQL,
+			[]string{`"text"`, "`name`"},
+			[]TokenKind{TokenString, TokenQuotedIdentifier}},
+		{"sqlite brackets", "[order]", types.SQLite,
+			[]string{"[order]"},
+			[]TokenKind{TokenQuotedIdentifier}},
+		{"dollar quoted", "$$ a; 'b' $$ $fn$ $$ $fn$ $1", types.PostgreSQL,
+			[]string{"$$ a; 'b' $$", "$fn$ $$ $fn$", "$1"},
+			[]TokenKind{TokenDollarString, TokenDollarString, TokenParameter}},
+		{"comments", "-- line\n/* block */ # hash", types.MySQL,
+			[]string{"-- line", "/* block */", "# hash"},
+			[]TokenKind{TokenComment, TokenComment, TokenComment}},
+		{"hash outside mysql", "a # b", types.PostgreSQL,
+			[]string{"a", "#", "b"},
+			[]TokenKind{TokenIdentifier, TokenOperator, TokenIdentifier}},
+		{"numbers", "1 2.5 .5 1e10 0xFF", types.MySQL,
+			[]string{"1", "2.5", ".5", "1e10", "0xFF"},
+			[]TokenKind{TokenNumber, TokenNumber, TokenNumber, TokenNumber, TokenNumber}},
+		{"parameters", "? :name @var @@version", types.MySQL,
+			[]string{"?", ":name", "@var", "@@version"},
+			[]TokenKind{TokenParameter, TokenParameter, TokenParameter, TokenParameter}},
+		{"operators and punctuation", "a.b>=1;c::int", types.PostgreSQL,
+			[]string{"a", ".", "b", ">=", "1", ";", "c", "::", "int"},
+			[]TokenKind{TokenIdentifier, TokenPunctuation, TokenIdentifier, TokenOperator, TokenNumber,
+				TokenPunctuation, TokenIdentifier, TokenOperator, TokenIdentifier}},
+		{"operator before comment", "1+-- note", types.PostgreSQL,
+			[]string{"1", "+", "-- note"},
+			[]TokenKind{TokenNumber, TokenOperator, TokenComment}},
+	}
+	for _, test := range tests {
+		texts, kinds := kinds(test.script, test.dialect)
+		if strings.Join(texts, "|") != strings.Join(test.texts, "|") {
+			t.Errorf("%s: Tokenize(%q) = %q, want %q", test.name, test.script, texts, test.texts)
+			continue
+		}
+		for i := range kinds {
+			if kinds[i] != test.kinds[i] {
+				t.Errorf("%s: token %q is kind %d, want %d", test.name, texts[i], kinds[i], test.kinds[i])
+			}
+		}
+	}
+}
+
+func TestTokenizeCoversScript(t *testing.T) {
+	script := "SELECT 'é', \"x\"\n  FROM t -- done"
+	var rebuilt strings.Builder
+	end := 0
+	for _, token := range Tokenize(script, types.PostgreSQL) {
+		if token.Start != end {
+			t.Fatalf("token %q starts at %d, want %d", token.Text, token.Start, end)
+		}
+		rebuilt.WriteString(token.Text)
+		end = token.End
+	}
+	if rebuilt.String() != script {
+		t.Fatalf("tokens rebuild %q, want %q", rebuilt.String(), script)
+	}
+}
+
+func TestTokenizeUnterminated(t *testing.T) {
+	tests := []string{"'open", `"open`, "/* open", "$$ open"}
+	for _, script := range tests {
+		tokens := Tokenize(script, types.PostgreSQL)
+		if len(tokens) != 1 || !tokens[0].Unterminated {
+			t.Errorf("Tokenize(%q) = %+v, want one unterminated token", script, tokens)
+		}
+	}
+	if tokens := Tokenize("'closed'", types.PostgreSQL); tokens[0].Unterminated {
+		t.Error("a closed string is marked unterminated")
+	}
+}
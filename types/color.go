@@ -0,0 +1,23 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultColor is the color of connections saved without one
+const DefaultColor = "Red"
+
+// ConnectionColors names the colors a connection can be shown in, in the
+// order the connection form offers them
+var ConnectionColors = []string{DefaultColor, "Green", "Blue", "Yellow", "Mauve", "Teal"}
+
+// ParseColor resolves a connection color from its name, ignoring case
+func ParseColor(name string) (string, error) {
+	for _, color := range ConnectionColors {
+		if strings.EqualFold(name, color) {
+			return color, nil
+		}
+	}
+	return "", fmt.Errorf("unknown color %q", name)
+}
@@ -1,5 +1,10 @@
 package types
 
+import (
+	"fmt"
+	"strings"
+)
+
 type ConnectionType int
 
 const (
@@ -21,15 +26,53 @@ func (ct ConnectionType) String() string {
 	}
 }
 
+// MarshalText stores connection types by name so the on-disk format does not
+// depend on the order of the constants above
+func (ct ConnectionType) MarshalText() ([]byte, error) {
+	if ct < PostgreSQL || ct > SQLite {
+		return nil, fmt.Errorf("unknown connection type %d", int(ct))
+	}
+	return []byte(strings.ToLower(ct.String())), nil
+}
+
+func (ct *ConnectionType) UnmarshalText(text []byte) error {
+	parsed, err := ParseConnectionType(string(text))
+	if err != nil {
+		return err
+	}
+	*ct = parsed
+	return nil
+}
+
+// ParseConnectionType resolves a connection type from its name, ignoring case
+func ParseConnectionType(name string) (ConnectionType, error) {
+	for _, ct := range []ConnectionType{PostgreSQL, MySQL, SQLite} {
+		if strings.EqualFold(name, ct.String()) {
+			return ct, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown connection type %q", name)
+}
+
+// Connection is a saved connection. Password and SSH.Password are only
+// filled in memory; the store keeps them in the credential vault.
 type Connection struct {
-	Name         string
-	Type         ConnectionType
-	Host         string
-	Port         string
-	User         string
-	Password     string
-	Database     string
-	DatabaseFile string
-	EnableSSL    bool
-	Color        string
+	Name            string          `json:"name"`
+	Type            ConnectionType  `json:"type"`
+	Host            string          `json:"host,omitempty"`
+	Port            string          `json:"port,omitempty"`
+	User            string          `json:"user,omitempty"`
+	Password        string          `json:"password,omitempty"`
+	PasswordStorage PasswordStorage `json:"password_storage,omitzero"`
+	PasswordCommand string          `json:"password_command,omitempty"`
+	Database        string          `json:"database,omitempty"`
+	DatabaseFile    string          `json:"database_file,omitempty"`
+	TLS             TLSConfig       `json:"tls,omitzero"`
+	SSH             SSHTunnel       `json:"ssh,omitzero"`
+	Color           string          `json:"color,omitempty"`
+	// Sensitive keeps the connection's statements out of the query history
+	Sensitive bool `json:"sensitive,omitempty"`
+	// InVault records that the vault holds secrets for this connection, so
+	// connecting only asks for the master password when it has to
+	InVault bool `json:"in_vault,omitempty"`
 }
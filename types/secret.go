@@ -0,0 +1,58 @@
+package types
+
+import "fmt"
+
+// PasswordStorage decides where a saved connection's password comes from.
+// Passwords are never written to the connections file itself.
+type PasswordStorage int
+
+const (
+	// PasswordVault keeps the password in the encrypted credential vault
+	PasswordVault PasswordStorage = iota
+	// PasswordPrompt never stores the password and asks for it on connect
+	PasswordPrompt
+	// PasswordCommand reads the password from an external command such as
+	// pass, op or vault
+	PasswordCommand
+)
+
+var PasswordStorages = []PasswordStorage{PasswordVault, PasswordPrompt, PasswordCommand}
+
+func (s PasswordStorage) String() string {
+	switch s {
+	case PasswordVault:
+		return "vault"
+	case PasswordPrompt:
+		return "prompt"
+	case PasswordCommand:
+		return "command"
+	default:
+		return "unknown"
+	}
+}
+
+func (s PasswordStorage) MarshalText() ([]byte, error) {
+	if s < PasswordVault || s > PasswordCommand {
+		return nil, fmt.Errorf("unknown password storage %d", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+func (s *PasswordStorage) UnmarshalText(text []byte) error {
+	parsed, err := ParsePasswordStorage(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// ParsePasswordStorage resolves a password storage from its name
+func ParsePasswordStorage(name string) (PasswordStorage, error) {
+	for _, storage := range PasswordStorages {
+		if name == storage.String() {
+			return storage, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown password storage %q", name)
+}
@@ -0,0 +1,20 @@
+package types
+
+// SSHTunnel describes a bastion host the database is reached through. The
+// password doubles as the passphrase when KeyFile is encrypted.
+type SSHTunnel struct {
+	Enabled  bool   `json:"enabled,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Port     string `json:"port,omitempty"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// UseAgent authenticates with the keys held by the running ssh-agent
+	UseAgent bool `json:"use_agent,omitempty"`
+	// ForwardAgent offers the running ssh-agent to the bastion. It is refused
+	// while SkipHostKeyCheck is set, so keys never reach an unverified host.
+	ForwardAgent bool `json:"forward_agent,omitempty"`
+	// KnownHostsFile defaults to ~/.ssh/known_hosts
+	KnownHostsFile   string `json:"known_hosts_file,omitempty"`
+	SkipHostKeyCheck bool   `json:"skip_host_key_check,omitempty"`
+}
@@ -0,0 +1,69 @@
+package types
+
+import "fmt"
+
+// TLSMode follows libpq's sslmode names, which MySQL's ssl-mode maps onto
+type TLSMode int
+
+const (
+	TLSDisable TLSMode = iota
+	TLSPrefer
+	TLSRequire
+	TLSVerifyCA
+	TLSVerifyFull
+)
+
+var TLSModes = []TLSMode{TLSDisable, TLSPrefer, TLSRequire, TLSVerifyCA, TLSVerifyFull}
+
+func (m TLSMode) String() string {
+	switch m {
+	case TLSDisable:
+		return "disable"
+	case TLSPrefer:
+		return "prefer"
+	case TLSRequire:
+		return "require"
+	case TLSVerifyCA:
+		return "verify-ca"
+	case TLSVerifyFull:
+		return "verify-full"
+	default:
+		return "unknown"
+	}
+}
+
+func (m TLSMode) MarshalText() ([]byte, error) {
+	if m < TLSDisable || m > TLSVerifyFull {
+		return nil, fmt.Errorf("unknown TLS mode %d", int(m))
+	}
+	return []byte(m.String()), nil
+}
+
+func (m *TLSMode) UnmarshalText(text []byte) error {
+	parsed, err := ParseTLSMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// ParseTLSMode resolves a TLS mode from its sslmode name
+func ParseTLSMode(name string) (TLSMode, error) {
+	for _, mode := range TLSModes {
+		if name == mode.String() {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown TLS mode %q", name)
+}
+
+// TLSConfig describes how a server connection negotiates TLS. Paths point to
+// PEM files; ServerName overrides the host name used for verification.
+type TLSConfig struct {
+	Mode       TLSMode `json:"mode"`
+	CAFile     string  `json:"ca_file,omitempty"`
+	CertFile   string  `json:"cert_file,omitempty"`
+	KeyFile    string  `json:"key_file,omitempty"`
+	ServerName string  `json:"server_name,omitempty"`
+}
@@ -7,11 +7,14 @@ const (
 	FieldConnectionType = iota
 	FieldHost
 	FieldPort
-	FieldSSL
+	FieldTLS
+	FieldSSH
 	FieldUser
 	FieldPassword
+	FieldDatabase
 	FieldConnectionName
 	FieldColor
+	FieldHistory
 )
 
 // SQLite-specific field indices (redefine to match the layout)
@@ -20,6 +23,7 @@ const (
 	SQLiteFieldDatabaseFile          // 1: Database File
 	SQLiteFieldConnectionName        // 2: Connection Name
 	SQLiteFieldColor                 // 3: Color
+	SQLiteFieldHistory               // 4: History
 )
 
 // Input field indices using iota
@@ -28,9 +32,17 @@ const (
 	InputPort
 	InputUser
 	InputPassword
+	InputDatabase
 	InputConnectionName
+	InputPasswordCommand
+
+	// InputCount is the number of text inputs in the connection form
+	InputCount
 )
 
+// DefaultSSHPort is used when an SSH tunnel doesn't specify a port
+const DefaultSSHPort = "22"
+
 // Database connection defaults
 var (
 	DefaultPorts = map[types.ConnectionType]string{
@@ -47,9 +59,9 @@ var (
 
 	// Total field counts for each database type
 	FieldCounts = map[types.ConnectionType]int{
-		types.SQLite:     4, // Connection Type, Database File, Connection Name, Color
-		types.PostgreSQL: 8, // Connection Type, Host, Port, SSL, User, Password, Connection Name, Color
-		types.MySQL:      8, // Same as PostgreSQL
+		types.SQLite:     5,  // Connection Type, Database File, Connection Name, Color, History
+		types.PostgreSQL: 11, // Connection Type, Host, Port, TLS, SSH, User, Password, Database, Connection Name, Color, History
+		types.MySQL:      11, // Same as PostgreSQL
 	}
 )
 
@@ -64,6 +76,7 @@ var NonSQLiteFieldMapping = map[int]int{
 	FieldPort:           InputPort,
 	FieldUser:           InputUser,
 	FieldPassword:       InputPassword,
+	FieldDatabase:       InputDatabase,
 	FieldConnectionName: InputConnectionName,
 }
 
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// AppName is the directory name used under the user's config and data dirs
+const AppName = "nectar"
+
+// ConfigDir returns the directory nectar keeps its configuration in,
+// following the XDG base directory spec ($XDG_CONFIG_HOME/nectar, falling
+// back to ~/.config/nectar). Windows uses the roaming AppData directory.
+func ConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, AppName), nil
+	}
+
+	if runtime.GOOS == "windows" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, AppName), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", AppName), nil
+}
+
+// DataDir returns the directory nectar keeps its data in, such as the query
+// history, following the XDG base directory spec ($XDG_DATA_HOME/nectar,
+// falling back to ~/.local/share/nectar). Windows uses the local AppData
+// directory.
+func DataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, AppName), nil
+	}
+
+	if runtime.GOOS == "windows" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, AppName), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", AppName), nil
+}